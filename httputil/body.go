@@ -0,0 +1,36 @@
+package httputil
+
+import (
+	"io"
+	"net/http"
+)
+
+// maxDrainBytes is the limit on the number of bytes read when
+// discarding the remainder of a body. Draining the body allows the
+// connection to be reused but a larger remainder is not worth reading.
+const maxDrainBytes = 256 << 10 // 256 KiB
+
+// drain discards the remainder of r, reading at most maxDrainBytes.
+func drain(r io.Reader) {
+	io.CopyN(io.Discard, r, maxDrainBytes) //nolint:errcheck
+}
+
+// drainBody drains and closes the response body so that the connection
+// can be reused.
+func drainBody(body io.ReadCloser) {
+	drain(body)
+	body.Close() //nolint:errcheck
+}
+
+// limitBody returns a reader which fails with the same error as
+// http.MaxBytesReader once more than n bytes are read from body. body is
+// returned as is if n is zero or negative.
+func limitBody(body io.ReadCloser, n int64) io.ReadCloser {
+	if n <= 0 {
+		return body
+	}
+
+	// The ResponseWriter is not available here. MaxBytesReader only uses
+	// it to signal the server to close the connection.
+	return http.MaxBytesReader(nil, body, n)
+}
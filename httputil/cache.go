@@ -0,0 +1,602 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// Defaults used by CachingTransport for the zero values of
+// CacheOptions.
+const (
+	DefaultCacheMaxEntries        = 1000
+	DefaultCacheMaxBodySize       = 1 << 20 // 1 MiB
+	DefaultCacheRevalidateTimeout = 30 * time.Second
+)
+
+// cacheStatusName identifies the cache in the Cache-Status header.
+const cacheStatusName = "httputil"
+
+// CacheOptions configures the middleware returned by CachingTransport.
+type CacheOptions struct {
+	// Storage stores the cached responses. A LRUCacheStorage with
+	// DefaultCacheMaxEntries entries is used if nil.
+	Storage CacheStorage
+
+	// MaxBodySize is the size of the largest response body which is
+	// cached. DefaultCacheMaxBodySize is used if zero.
+	MaxBodySize int64
+
+	// RevalidateTimeout is the time limit for revalidating a stale
+	// response in the background as allowed by stale-while-revalidate.
+	// DefaultCacheRevalidateTimeout is used if zero.
+	RevalidateTimeout time.Duration
+
+	// ErrObservers are notified of the errors returned by the Storage.
+	// The errors do not fail the request. Optional.
+	ErrObservers []ErrorObserverFunc
+
+	// Clock is used to determine the age and the freshness of the cached
+	// responses. The system clock is used if nil.
+	Clock Clock
+}
+
+// CachingTransport returns a middleware for http.RoundTripper which
+// caches the responses as per RFC 9111, behaving as a private cache.
+//
+// Responses to GET requests are stored if they carry explicit
+// freshness information, using the Cache-Control max-age directive or
+// the Expires header, or a validator, the ETag or the Last-Modified
+// header. A fresh response is served from the cache. A stale response
+// is revalidated using a conditional request and served from the cache
+// if the server responds with 304 Not Modified. Responses without
+// explicit freshness information are considered fresh for 10% of the
+// time since they were last modified.
+//
+// The Vary header is honoured by storing the values of the request
+// headers it lists along with the response. A response is served from
+// the cache only if the values match those of the request. A single
+// variant is stored for each URL.
+//
+// The stale-while-revalidate directive allows a stale response to be
+// served while it is revalidated in the background. The
+// stale-if-error directive allows a stale response to be served if the
+// server responds with a 5xx status or cannot be reached.
+//
+// The no-cache, no-store, max-age, max-stale, min-fresh and
+// only-if-cached request directives are supported. Requests with a
+// Range header or conditional headers bypass the cache. Successful
+// responses to unsafe requests, such as POST, invalidate the cached
+// response for the URL.
+//
+// The responses served by the cache have the Age header and a
+// Cache-Status header, as defined in RFC 9211, describing how the cache
+// handled the request.
+//
+//	client := &http.Client{
+//		Transport: httputil.CachingTransport(httputil.CacheOptions{})(http.DefaultTransport),
+//	}
+func CachingTransport(opts CacheOptions) func(http.RoundTripper) http.RoundTripper {
+	if opts.Storage == nil {
+		opts.Storage = NewLRUCacheStorage(DefaultCacheMaxEntries)
+	}
+	if opts.MaxBodySize == 0 {
+		opts.MaxBodySize = DefaultCacheMaxBodySize
+	}
+	if opts.RevalidateTimeout == 0 {
+		opts.RevalidateTimeout = DefaultCacheRevalidateTimeout
+	}
+	opts.Clock = clockOrDefault(opts.Clock)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &cacheTransport{next: next, opts: opts, revalidating: make(map[string]struct{})}
+	}
+}
+
+type cacheTransport struct {
+	next http.RoundTripper
+	opts CacheOptions
+
+	mu           sync.Mutex
+	revalidating map[string]struct{}
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.URL.String()
+
+	switch {
+	case req.Method == http.MethodHead, req.Method == http.MethodOptions, req.Method == http.MethodTrace:
+		return t.next.RoundTrip(req)
+
+	case req.Method != http.MethodGet:
+		resp, err := t.next.RoundTrip(req)
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			t.delete(req, key)
+		}
+		return resp, err
+
+	case hasConditionals(req.Header) || req.Header.Get("Range") != "":
+		return t.next.RoundTrip(req)
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	e, fwd := t.load(key, req)
+	if e == nil {
+		if reqCC.has("only-if-cached") {
+			return gatewayTimeout(req), nil
+		}
+		return t.fetch(req, key, fwd)
+	}
+
+	now := t.opts.Clock.Now()
+	age, lifetime := e.age(now), e.lifetime()
+	resCC := parseCacheControl(e.Header)
+
+	if reqCC.has("only-if-cached") || e.usable(reqCC, resCC, age, lifetime) {
+		return e.response(req, age, cacheHit(age, lifetime, "")), nil
+	}
+
+	if reqCC.has("no-cache") {
+		return t.revalidate(req, key, e, "request")
+	}
+
+	if swr, ok := resCC.seconds("stale-while-revalidate"); ok && age < lifetime+swr && !resCC.has("must-revalidate") && !resCC.has("no-cache") {
+		t.revalidateAsync(req, key, e)
+		return e.response(req, age, cacheHit(age, lifetime, "stale-while-revalidate")), nil
+	}
+
+	return t.revalidate(req, key, e, "stale")
+}
+
+// load returns the stored response for the request. If there is none,
+// it returns the reason for the Cache-Status header.
+func (t *cacheTransport) load(key string, req *http.Request) (*cacheEntry, string) {
+	b, ok, err := t.opts.Storage.Get(req.Context(), key)
+	if err != nil {
+		t.observeError(req, "get cached response", err)
+		return nil, "uri-miss"
+	}
+	if !ok {
+		return nil, "uri-miss"
+	}
+
+	var e cacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		t.delete(req, key)
+		return nil, "uri-miss"
+	}
+
+	if !e.matches(req) {
+		return nil, "vary-miss"
+	}
+	return &e, ""
+}
+
+// fetch forwards the request and stores the response if possible.
+func (t *cacheTransport) fetch(req *http.Request, key, fwd string) (*http.Response, error) {
+	reqTime := t.opts.Clock.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.store(req, key, resp, reqTime, fwd)
+}
+
+// revalidate sends a conditional request for the stored response. The
+// stored response is served if it has not been modified or, if allowed
+// by stale-if-error, if the server could not respond.
+func (t *cacheTransport) revalidate(req *http.Request, key string, e *cacheEntry, fwd string) (*http.Response, error) {
+	creq := req.Clone(req.Context())
+	if etag := e.Header.Get("ETag"); etag != "" {
+		creq.Header.Set("If-None-Match", etag)
+	}
+	if lm := e.Header.Get("Last-Modified"); lm != "" {
+		creq.Header.Set("If-Modified-Since", lm)
+	}
+
+	reqTime := t.opts.Clock.Now()
+	resp, err := t.next.RoundTrip(creq)
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		now := t.opts.Clock.Now()
+		if age, lifetime := e.age(now), e.lifetime(); e.staleIfError(parseCacheControl(req.Header), age, lifetime) {
+			if resp != nil {
+				drainBody(resp.Body)
+			}
+			return e.response(req, age, cacheHit(age, lifetime, "stale-if-error")), nil
+		}
+		return resp, err
+	}
+
+	if resp.StatusCode != http.StatusNotModified {
+		return t.store(req, key, resp, reqTime, fwd)
+	}
+	drainBody(resp.Body)
+
+	// Update the stored response with the headers of the 304 response
+	// as per RFC 9111 section 4.3.4.
+	for k, vv := range resp.Header {
+		if k != "Content-Length" {
+			e.Header[k] = vv
+		}
+	}
+	e.RequestTime, e.ResponseTime = reqTime, t.opts.Clock.Now()
+	if b, err := json.Marshal(e); err == nil {
+		t.set(req, key, b)
+	}
+
+	status := fmt.Sprintf("%s; fwd=%s; fwd-status=%d", cacheStatusName, fwd, resp.StatusCode)
+	return e.response(req, e.age(e.ResponseTime), status), nil
+}
+
+// revalidateAsync revalidates the stored response in the background
+// unless a revalidation is already in progress.
+func (t *cacheTransport) revalidateAsync(req *http.Request, key string, e *cacheEntry) {
+	t.mu.Lock()
+	if _, ok := t.revalidating[key]; ok {
+		t.mu.Unlock()
+		return
+	}
+	t.revalidating[key] = struct{}{}
+	t.mu.Unlock()
+
+	// The request context is canceled once the stale response is
+	// consumed, so the revalidation must not depend on it.
+	ctx, cancel := context.WithTimeout(context.Background(), t.opts.RevalidateTimeout)
+	r := req.Clone(ctx)
+	go func() {
+		defer func() {
+			cancel()
+			t.mu.Lock()
+			delete(t.revalidating, key)
+			t.mu.Unlock()
+		}()
+
+		if resp, err := t.revalidate(r, key, e, "stale"); err == nil {
+			drainBody(resp.Body)
+		}
+	}()
+}
+
+// store stores the response if it is cacheable. The response is
+// returned with the Cache-Status header and the body replaced if it
+// was read. If the response cannot be stored, the previously stored
+// response, which it replaces, is deleted.
+func (t *cacheTransport) store(req *http.Request, key string, resp *http.Response, reqTime time.Time, fwd string) (*http.Response, error) {
+	status := fmt.Sprintf("%s; fwd=%s; fwd-status=%d", cacheStatusName, fwd, resp.StatusCode)
+	if !storable(req, resp) || resp.ContentLength > t.opts.MaxBodySize {
+		t.delete(req, key)
+		resp.Header.Add("Cache-Status", status)
+		return resp, nil
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, t.opts.MaxBodySize+1))
+	if err != nil {
+		resp.Body.Close() //nolint:errcheck
+		return nil, err
+	}
+	if int64(len(b)) > t.opts.MaxBodySize {
+		t.delete(req, key)
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), resp.Body), resp.Body}
+		resp.Header.Add("Cache-Status", status)
+		return resp, nil
+	}
+	resp.Body.Close() //nolint:errcheck
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+
+	e := cacheEntry{
+		Status:       resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         b,
+		RequestTime:  reqTime,
+		ResponseTime: t.opts.Clock.Now(),
+		Vary:         varyValues(req, resp.Header),
+	}
+	if enc, err := json.Marshal(e); err == nil && t.set(req, key, enc) {
+		status += "; stored"
+	}
+
+	resp.Header.Add("Cache-Status", status)
+	return resp, nil
+}
+
+// set stores the value for the key and reports whether it succeeded.
+func (t *cacheTransport) set(req *http.Request, key string, value []byte) bool {
+	if err := t.opts.Storage.Set(req.Context(), key, value); err != nil {
+		t.observeError(req, "store response", err)
+		return false
+	}
+	return true
+}
+
+func (t *cacheTransport) delete(req *http.Request, key string) {
+	if err := t.opts.Storage.Delete(req.Context(), key); err != nil {
+		t.observeError(req, "delete cached response", err)
+	}
+}
+
+func (t *cacheTransport) observeError(req *http.Request, text string, err error) {
+	err = errors.E(errors.WithOp("httputil.CachingTransport"), errors.WithText(text), errors.WithErr(err))
+	for _, f := range t.opts.ErrObservers {
+		f(req, err)
+	}
+}
+
+// cacheEntry is a stored response.
+type cacheEntry struct {
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	RequestTime  time.Time   `json:"request_time"`
+	ResponseTime time.Time   `json:"response_time"`
+
+	// Vary is the values of the request headers listed in the Vary
+	// header of the response.
+	Vary http.Header `json:"vary,omitempty"`
+}
+
+// matches reports whether the stored response can be used for the
+// request as per the Vary header.
+func (e *cacheEntry) matches(req *http.Request) bool {
+	for k, vv := range e.Vary {
+		if strings.Join(req.Header.Values(k), ", ") != strings.Join(vv, ", ") {
+			return false
+		}
+	}
+	return true
+}
+
+// usable reports whether the stored response can be served without
+// revalidation as per RFC 9111 section 4.2.
+func (e *cacheEntry) usable(reqCC, resCC cacheControl, age, lifetime time.Duration) bool {
+	if reqCC.has("no-cache") || resCC.has("no-cache") {
+		return false
+	}
+
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+	minFresh, _ := reqCC.seconds("min-fresh")
+	if lifetime-age > minFresh {
+		return true
+	}
+
+	if !reqCC.has("max-stale") || resCC.has("must-revalidate") {
+		return false
+	}
+	maxStale, ok := reqCC.seconds("max-stale")
+	return !ok || age-lifetime <= maxStale
+}
+
+// staleIfError reports whether the stored response can be served when
+// the server could not respond as per RFC 5861.
+func (e *cacheEntry) staleIfError(reqCC cacheControl, age, lifetime time.Duration) bool {
+	resCC := parseCacheControl(e.Header)
+	if resCC.has("must-revalidate") || resCC.has("no-cache") {
+		return false
+	}
+
+	d, ok := reqCC.seconds("stale-if-error")
+	if !ok {
+		d, ok = resCC.seconds("stale-if-error")
+	}
+	return ok && age < lifetime+d
+}
+
+// lifetime returns the freshness lifetime of the stored response as
+// per RFC 9111 section 4.2.1.
+func (e *cacheEntry) lifetime() time.Duration {
+	return freshnessLifetime(e.Status, e.Header, e.date())
+}
+
+// age returns the current age of the stored response as per RFC 9111
+// section 4.2.3.
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	apparentAge := e.ResponseTime.Sub(e.date())
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+
+	ageValue, _ := parseSeconds(e.Header.Get("Age"))
+	correctedAge := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	if correctedAge < apparentAge {
+		correctedAge = apparentAge
+	}
+
+	return correctedAge + now.Sub(e.ResponseTime)
+}
+
+func (e *cacheEntry) date() time.Time {
+	if d, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return d
+	}
+	return e.ResponseTime
+}
+
+// response returns the stored response for the request.
+func (e *cacheEntry) response(req *http.Request, age time.Duration, status string) *http.Response {
+	h := e.Header.Clone()
+	h.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	h.Add("Cache-Status", status)
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// freshnessLifetime returns the freshness lifetime of the response,
+// falling back to the heuristic freshness if the response has no
+// explicit expiration time.
+func freshnessLifetime(status int, h http.Header, date time.Time) time.Duration {
+	if maxAge, ok := parseCacheControl(h).seconds("max-age"); ok {
+		return maxAge
+	}
+
+	if v := h.Get("Expires"); v != "" {
+		// An invalid date, such as "0", represents a time in the past.
+		expires, err := http.ParseTime(v)
+		if err != nil || expires.Before(date) {
+			return 0
+		}
+		return expires.Sub(date)
+	}
+
+	if !heuristicallyCacheable(status) {
+		return 0
+	}
+	if lm, err := http.ParseTime(h.Get("Last-Modified")); err == nil && lm.Before(date) {
+		return date.Sub(lm) / 10
+	}
+	return 0
+}
+
+// storable reports whether the response to the request can be stored
+// as per RFC 9111 section 3.
+func storable(req *http.Request, resp *http.Response) bool {
+	if parseCacheControl(req.Header).has("no-store") {
+		return false
+	}
+
+	resCC := parseCacheControl(resp.Header)
+	if resCC.has("no-store") || resp.Header.Get("Vary") == "*" {
+		return false
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent, http.StatusNotModified:
+		return false
+	}
+	explicit := resCC.has("max-age") || resp.Header.Get("Expires") != ""
+	if !explicit && !heuristicallyCacheable(resp.StatusCode) {
+		return false
+	}
+
+	return explicit || resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+// heuristicallyCacheable reports whether the status is heuristically
+// cacheable as per RFC 9110 section 15.1.
+func heuristicallyCacheable(status int) bool {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusMethodNotAllowed,
+		http.StatusGone, http.StatusRequestURITooLong, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+func hasConditionals(h http.Header) bool {
+	for _, k := range []string{"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "If-Range"} {
+		if h.Get(k) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// varyValues returns the values of the request headers listed in the
+// Vary header of the response.
+func varyValues(req *http.Request, h http.Header) http.Header {
+	var vary http.Header
+	for _, v := range h.Values("Vary") {
+		for _, k := range strings.Split(v, ",") {
+			k = http.CanonicalHeaderKey(strings.TrimSpace(k))
+			if k == "" {
+				continue
+			}
+			if vary == nil {
+				vary = make(http.Header)
+			}
+			vary[k] = req.Header.Values(k)
+		}
+	}
+	return vary
+}
+
+// cacheHit returns the Cache-Status header value for a response served
+// from the cache.
+func cacheHit(age, lifetime time.Duration, detail string) string {
+	status := fmt.Sprintf("%s; hit; ttl=%d", cacheStatusName, int64((lifetime-age)/time.Second))
+	if detail != "" {
+		status += "; detail=" + detail
+	}
+	return status
+}
+
+// gatewayTimeout returns the response for an only-if-cached request
+// which cannot be served from the cache as per RFC 9111 section
+// 5.2.1.7.
+func gatewayTimeout(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", http.StatusGatewayTimeout, http.StatusText(http.StatusGatewayTimeout)),
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Cache-Status": {cacheStatusName + "; fwd=miss; detail=only-if-cached"}},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+// cacheControl is the set of directives in the Cache-Control header.
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, v := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "" {
+				cc[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+			}
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// seconds returns the argument of the directive as a duration. It
+// reports false if the directive is absent or its argument is invalid.
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	return parseSeconds(cc[name])
+}
+
+// parseSeconds parses a non-negative number of seconds, capping it at
+// 2^31 as per RFC 9111 section 1.2.2.
+func parseSeconds(v string) (time.Duration, bool) {
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, false
+	}
+	if err != nil || n > 1<<31 {
+		n = 1 << 31
+	}
+	return time.Duration(n) * time.Second, true
+}
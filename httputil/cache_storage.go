@@ -0,0 +1,111 @@
+package httputil
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// CacheStorage stores the responses cached by CachingTransport. The
+// responses are stored in an opaque encoding and are keyed by the
+// request URL. Implementations must be safe for concurrent use.
+//
+// CacheStorage can be implemented on top of a shared cache, such as
+// Redis or Memcached, to share the cached responses between processes.
+// The context is that of the request being served by CachingTransport.
+// A failure to get a value is treated as a cache miss while failures to
+// set or delete a value are reported to CacheOptions.ErrObservers.
+type CacheStorage interface {
+	// Get returns the stored value for the key and reports whether it
+	// was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores the value for the key.
+	Set(ctx context.Context, key string, value []byte) error
+
+	// Delete removes the value for the key. It is not an error if there
+	// is no value for the key.
+	Delete(ctx context.Context, key string) error
+}
+
+// LRUCacheStorage is an in-memory CacheStorage which evicts the least
+// recently used entry once the maximum number of entries is reached.
+//
+// LRUCacheStorage is safe for concurrent use.
+type LRUCacheStorage struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	value []byte
+}
+
+// NewLRUCacheStorage creates a LRUCacheStorage which holds up to
+// maxEntries entries. The number of entries is not limited if
+// maxEntries is zero.
+func NewLRUCacheStorage(maxEntries int) *LRUCacheStorage {
+	return &LRUCacheStorage{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get returns the stored value for the key and marks it as the most
+// recently used.
+func (s *LRUCacheStorage) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	s.ll.MoveToFront(el)
+	return el.Value.(*lruItem).value, true, nil
+}
+
+// Set stores the value for the key, evicting the least recently used
+// entry if the storage is full.
+func (s *LRUCacheStorage) Set(_ context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		el.Value.(*lruItem).value = value
+		s.ll.MoveToFront(el)
+		return nil
+	}
+
+	s.items[key] = s.ll.PushFront(&lruItem{key: key, value: value})
+	if s.maxEntries > 0 && s.ll.Len() > s.maxEntries {
+		el := s.ll.Back()
+		s.ll.Remove(el)
+		delete(s.items, el.Value.(*lruItem).key)
+	}
+	return nil
+}
+
+// Delete removes the value for the key.
+func (s *LRUCacheStorage) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		s.ll.Remove(el)
+		delete(s.items, key)
+	}
+	return nil
+}
+
+// Len returns the number of stored entries.
+func (s *LRUCacheStorage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ll.Len()
+}
@@ -0,0 +1,499 @@
+package httputil_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+// cacheServer responds with the JSON encoded value, generating the ETag
+// from the response body, and counts the requests.
+type cacheServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	header   http.Header
+	value    string
+	requests int
+}
+
+func newCacheServer(t *testing.T, clock *fakeClock, header http.Header) *cacheServer {
+	s := cacheServer{status: http.StatusOK, header: header, value: "v1"}
+	responder := httputil.JSONResponder{ETag: httputil.StrongETag}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		status, value := s.status, s.value
+		for k, vv := range s.header {
+			w.Header()[k] = vv
+		}
+		s.mu.Unlock()
+
+		w.Header().Set("Date", clock.Now().Format(http.TimeFormat))
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		responder.Respond(r, w, value)
+	}))
+	t.Cleanup(s.Close)
+	return &s
+}
+
+func (s *cacheServer) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+func (s *cacheServer) set(status int, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status != 0 {
+		s.status = status
+	}
+	if value != "" {
+		s.value = value
+	}
+}
+
+func TestCachingTransport(t *testing.T) {
+	type step struct {
+		advance      time.Duration
+		method       string
+		header       http.Header
+		serverStatus int
+		serverValue  string
+
+		wantStatus      int
+		wantBody        string
+		wantCacheStatus string
+		wantRequests    int
+	}
+
+	const lastModified = "Sun, 21 Mar 2021 10:00:00 GMT"
+
+	cases := []struct {
+		name   string
+		header http.Header
+		steps  []step
+	}{
+		{
+			name:   "Fresh",
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{advance: 30 * time.Second, wantBody: "v1", wantCacheStatus: "httputil; hit; ttl=30", wantRequests: 1},
+			},
+		},
+		{
+			name:   "Expires",
+			header: http.Header{"Expires": {"Wed, 31 Mar 2021 10:01:00 GMT"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{advance: 45 * time.Second, wantBody: "v1", wantCacheStatus: "httputil; hit; ttl=15", wantRequests: 1},
+			},
+		},
+		{
+			name:   "HeuristicFreshness",
+			header: http.Header{"Last-Modified": {lastModified}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				// Fresh for 10% of the 10 days since the last modification.
+				{advance: 23 * time.Hour, wantBody: "v1", wantCacheStatus: "httputil; hit; ttl=3600", wantRequests: 1},
+				{advance: 2 * time.Hour, wantBody: "v1", wantCacheStatus: "httputil; fwd=stale; fwd-status=304", wantRequests: 2},
+			},
+		},
+		{
+			name:   "NotModified",
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{advance: 90 * time.Second, wantBody: "v1", wantCacheStatus: "httputil; fwd=stale; fwd-status=304", wantRequests: 2},
+				{advance: 30 * time.Second, wantBody: "v1", wantCacheStatus: "httputil; hit; ttl=30", wantRequests: 2},
+			},
+		},
+		{
+			name:   "Modified",
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{advance: 90 * time.Second, serverValue: "v2", wantBody: "v2", wantCacheStatus: "httputil; fwd=stale; fwd-status=200; stored", wantRequests: 2},
+				{wantBody: "v2", wantCacheStatus: "httputil; hit; ttl=60", wantRequests: 2},
+			},
+		},
+		{
+			name:   "NoStore",
+			header: http.Header{"Cache-Control": {"no-store"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200", wantRequests: 1},
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200", wantRequests: 2},
+			},
+		},
+		{
+			name:   "ResponseNoCache",
+			header: http.Header{"Cache-Control": {"no-cache"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=stale; fwd-status=304", wantRequests: 2},
+			},
+		},
+		{
+			name:   "RequestNoCache",
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{
+					header:          http.Header{"Cache-Control": {"no-cache"}},
+					wantBody:        "v1",
+					wantCacheStatus: "httputil; fwd=request; fwd-status=304",
+					wantRequests:    2,
+				},
+			},
+		},
+		{
+			name:   "RequestMaxAge",
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{
+					advance:         20 * time.Second,
+					header:          http.Header{"Cache-Control": {"max-age=10"}},
+					wantBody:        "v1",
+					wantCacheStatus: "httputil; fwd=stale; fwd-status=304",
+					wantRequests:    2,
+				},
+			},
+		},
+		{
+			name:   "RequestMaxStale",
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{
+					advance:         90 * time.Second,
+					header:          http.Header{"Cache-Control": {"max-stale=60"}},
+					wantBody:        "v1",
+					wantCacheStatus: "httputil; hit; ttl=-30",
+					wantRequests:    1,
+				},
+			},
+		},
+		{
+			name:   "OnlyIfCached",
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			steps: []step{
+				{
+					header:          http.Header{"Cache-Control": {"only-if-cached"}},
+					wantStatus:      http.StatusGatewayTimeout,
+					wantCacheStatus: "httputil; fwd=miss; detail=only-if-cached",
+				},
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{
+					advance:         90 * time.Second,
+					header:          http.Header{"Cache-Control": {"only-if-cached"}},
+					wantBody:        "v1",
+					wantCacheStatus: "httputil; hit; ttl=-30",
+					wantRequests:    1,
+				},
+			},
+		},
+		{
+			name:   "Vary",
+			header: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Language"}},
+			steps: []step{
+				{
+					header:          http.Header{"Accept-Language": {"en"}},
+					wantBody:        "v1",
+					wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored",
+					wantRequests:    1,
+				},
+				{
+					header:          http.Header{"Accept-Language": {"en"}},
+					wantBody:        "v1",
+					wantCacheStatus: "httputil; hit; ttl=60",
+					wantRequests:    1,
+				},
+				{
+					header:          http.Header{"Accept-Language": {"fr"}},
+					wantBody:        "v1",
+					wantCacheStatus: "httputil; fwd=vary-miss; fwd-status=200; stored",
+					wantRequests:    2,
+				},
+				{
+					header:          http.Header{"Accept-Language": {"fr"}},
+					wantBody:        "v1",
+					wantCacheStatus: "httputil; hit; ttl=60",
+					wantRequests:    2,
+				},
+			},
+		},
+		{
+			name:   "VaryStar",
+			header: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200", wantRequests: 1},
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200", wantRequests: 2},
+			},
+		},
+		{
+			name:   "StaleWhileRevalidate",
+			header: http.Header{"Cache-Control": {"max-age=60, stale-while-revalidate=30"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{
+					advance:         70 * time.Second,
+					wantBody:        "v1",
+					wantCacheStatus: "httputil; hit; ttl=-10; detail=stale-while-revalidate",
+					wantRequests:    2,
+				},
+			},
+		},
+		{
+			name:   "StaleWhileRevalidateExpired",
+			header: http.Header{"Cache-Control": {"max-age=60, stale-while-revalidate=30"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{advance: 100 * time.Second, wantBody: "v1", wantCacheStatus: "httputil; fwd=stale; fwd-status=304", wantRequests: 2},
+			},
+		},
+		{
+			name:   "StaleIfError",
+			header: http.Header{"Cache-Control": {"max-age=60, stale-if-error=120"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{
+					advance:         90 * time.Second,
+					serverStatus:    http.StatusServiceUnavailable,
+					wantBody:        "v1",
+					wantCacheStatus: "httputil; hit; ttl=-30; detail=stale-if-error",
+					wantRequests:    2,
+				},
+				{advance: 100 * time.Second, wantStatus: http.StatusServiceUnavailable, wantRequests: 3},
+			},
+		},
+		{
+			name:   "MustRevalidate",
+			header: http.Header{"Cache-Control": {"max-age=60, must-revalidate, stale-if-error=120"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{advance: 90 * time.Second, serverStatus: http.StatusServiceUnavailable, wantStatus: http.StatusServiceUnavailable, wantRequests: 2},
+			},
+		},
+		{
+			name:   "Invalidation",
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			steps: []step{
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 1},
+				{method: http.MethodDelete, wantBody: "v1", wantRequests: 2},
+				{wantBody: "v1", wantCacheStatus: "httputil; fwd=uri-miss; fwd-status=200; stored", wantRequests: 3},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			srv := newCacheServer(t, clock, tc.header)
+			hc := &http.Client{Transport: httputil.CachingTransport(httputil.CacheOptions{Clock: clock})(http.DefaultTransport)}
+
+			for i, s := range tc.steps {
+				clock.Advance(s.advance)
+				srv.set(s.serverStatus, s.serverValue)
+
+				method := s.method
+				if method == "" {
+					method = http.MethodGet
+				}
+				req, err := http.NewRequest(method, srv.URL, nil)
+				if err != nil {
+					t.Fatalf("NewRequest() error=%v", err)
+				}
+				for k, vv := range s.header {
+					req.Header[k] = vv
+				}
+
+				resp, err := hc.Do(req)
+				if err != nil {
+					t.Fatalf("Step %d: Client.Do() error=%v", i+1, err)
+				}
+				b, err := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					t.Fatalf("Step %d: ReadAll() error=%v", i+1, err)
+				}
+
+				wantStatus := s.wantStatus
+				if wantStatus == 0 {
+					wantStatus = http.StatusOK
+				}
+				if resp.StatusCode != wantStatus {
+					t.Errorf("Step %d: Status=%d; want=%d", i+1, resp.StatusCode, wantStatus)
+				}
+				if s.wantBody != "" {
+					if got := strings.TrimSpace(string(b)); got != `"`+s.wantBody+`"` {
+						t.Errorf("Step %d: Body=%s; want=%q", i+1, got, s.wantBody)
+					}
+				}
+				if got := resp.Header.Get("Cache-Status"); got != s.wantCacheStatus {
+					t.Errorf("Step %d: Cache-Status=%q; want=%q", i+1, got, s.wantCacheStatus)
+				}
+
+				// Background revalidations complete after the response is
+				// returned.
+				deadline := time.Now().Add(time.Second)
+				for srv.Requests() < s.wantRequests && time.Now().Before(deadline) {
+					time.Sleep(5 * time.Millisecond)
+				}
+				if got := srv.Requests(); got != s.wantRequests {
+					t.Errorf("Step %d: Requests=%d; want=%d", i+1, got, s.wantRequests)
+				}
+			}
+		})
+	}
+
+	t.Run("MaxBodySize", func(t *testing.T) {
+		clock := newFakeClock()
+		srv := newCacheServer(t, clock, http.Header{"Cache-Control": {"max-age=60"}})
+		srv.set(0, strings.Repeat("x", 64))
+		opts := httputil.CacheOptions{MaxBodySize: 32, Clock: clock}
+		hc := &http.Client{Transport: httputil.CachingTransport(opts)(http.DefaultTransport)}
+
+		for i := 0; i < 2; i++ {
+			resp, err := hc.Get(srv.URL)
+			if err != nil {
+				t.Fatalf("Client.Get() error=%v", err)
+			}
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if want := `"` + strings.Repeat("x", 64) + `"`; strings.TrimSpace(string(b)) != want {
+				t.Errorf("Body=%s; want=%s", b, want)
+			}
+		}
+		if got := srv.Requests(); got != 2 {
+			t.Errorf("Requests=%d; want=%d", got, 2)
+		}
+	})
+
+	t.Run("TooLargeReplacement", func(t *testing.T) {
+		clock := newFakeClock()
+		srv := newCacheServer(t, clock, http.Header{"Cache-Control": {"max-age=60"}})
+		storage := httputil.NewLRUCacheStorage(10)
+		opts := httputil.CacheOptions{Storage: storage, MaxBodySize: 32, Clock: clock}
+		hc := &http.Client{Transport: httputil.CachingTransport(opts)(http.DefaultTransport)}
+
+		for _, value := range []string{"v1", strings.Repeat("x", 64)} {
+			srv.set(0, value)
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatalf("NewRequest() error=%v", err)
+			}
+			req.Header.Set("Cache-Control", "no-cache")
+
+			resp, err := hc.Do(req)
+			if err != nil {
+				t.Fatalf("Client.Do() error=%v", err)
+			}
+			io.Copy(io.Discard, resp.Body) //nolint:errcheck
+			resp.Body.Close()
+		}
+
+		// The stored response must not outlive the response replacing it.
+		if storage.Len() != 0 {
+			t.Errorf("LRUCacheStorage.Len()=%d; want=0", storage.Len())
+		}
+	})
+
+	t.Run("StorageError", func(t *testing.T) {
+		clock := newFakeClock()
+		srv := newCacheServer(t, clock, http.Header{"Cache-Control": {"max-age=60"}})
+
+		var observed []error
+		opts := httputil.CacheOptions{
+			Storage:      failingStorage{},
+			ErrObservers: []httputil.ErrorObserverFunc{func(_ *http.Request, err error) { observed = append(observed, err) }},
+			Clock:        clock,
+		}
+		hc := &http.Client{Transport: httputil.CachingTransport(opts)(http.DefaultTransport)}
+
+		resp, err := hc.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Client.Get() error=%v", err)
+		}
+		resp.Body.Close()
+
+		want := "httputil; fwd=uri-miss; fwd-status=200"
+		if got := resp.Header.Get("Cache-Status"); got != want {
+			t.Errorf("Cache-Status=%q; want=%q", got, want)
+		}
+
+		const op = "httputil.CachingTransport"
+		wantErrs := []error{
+			errors.E(errors.WithOp(op), errors.WithText("get cached response"), errors.Unavailable),
+			errors.E(errors.WithOp(op), errors.WithText("store response"), errors.Unavailable),
+		}
+		if len(observed) != len(wantErrs) {
+			t.Fatalf("Observed errors=%v; want %d errors", observed, len(wantErrs))
+		}
+		for i, wantErr := range wantErrs {
+			if !errors.Match(wantErr, observed[i]) {
+				t.Errorf("Observed error %d=%v; want match for %v", i, observed[i], wantErr)
+			}
+		}
+	})
+}
+
+func TestLRUCacheStorage(t *testing.T) {
+	ctx := context.Background()
+	s := httputil.NewLRUCacheStorage(2)
+	s.Set(ctx, "a", []byte("1")) //nolint:errcheck
+	s.Set(ctx, "b", []byte("2")) //nolint:errcheck
+	s.Get(ctx, "a")              //nolint:errcheck
+	// Evicts b, the least recently used.
+	s.Set(ctx, "c", []byte("3")) //nolint:errcheck
+
+	if _, ok, _ := s.Get(ctx, "b"); ok {
+		t.Error("LRUCacheStorage.Get(b) found; want evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := s.Get(ctx, key); !ok {
+			t.Errorf("LRUCacheStorage.Get(%s) not found", key)
+		}
+	}
+
+	s.Set(ctx, "a", []byte("4")) //nolint:errcheck
+	if v, _, _ := s.Get(ctx, "a"); string(v) != "4" {
+		t.Errorf("LRUCacheStorage.Get(a)=%s; want=4", v)
+	}
+
+	s.Delete(ctx, "a") //nolint:errcheck
+	if _, ok, _ := s.Get(ctx, "a"); ok || s.Len() != 1 {
+		t.Errorf("LRUCacheStorage.Get(a) found=%t, Len()=%d; want false, 1", ok, s.Len())
+	}
+}
+
+// failingStorage is a CacheStorage which fails every operation.
+type failingStorage struct{}
+
+func (failingStorage) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.E(errors.Unavailable, errors.WithText("storage down"))
+}
+
+func (failingStorage) Set(context.Context, string, []byte) error {
+	return errors.E(errors.Unavailable, errors.WithText("storage down"))
+}
+
+func (failingStorage) Delete(context.Context, string) error {
+	return errors.E(errors.Unavailable, errors.WithText("storage down"))
+}
@@ -0,0 +1,196 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// Client is an HTTP client for JSON APIs. It builds URLs relative to the
+// base URL, sends the requests with the default headers and decodes the
+// JSON response bodies.
+//
+// Client should be created using NewClient. It is safe for concurrent
+// use once configured.
+type Client struct {
+	// HTTPClient is used to send the requests. http.DefaultClient is
+	// used if nil.
+	HTTPClient *http.Client
+
+	// Header is the set of default headers sent with each request. A
+	// header set on the request takes precedence over the default.
+	// Optional.
+	Header http.Header
+
+	// Codec is used to encode the request bodies and to decode the
+	// response bodies. StdJSONCodec is used if nil.
+	Codec JSONCodec
+
+	urls URLBuilderSource
+}
+
+// NewClient creates a Client for the API hosted at the baseURL. The
+// baseURL is parsed using NewURLBuilderSource.
+func NewClient(baseURL string) (*Client, error) {
+	const op = "httputil.NewClient"
+
+	src, err := NewURLBuilderSource(baseURL)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return &Client{urls: src}, nil
+}
+
+// NewURLBuilder creates a URLBuilder for a URL relative to the base URL
+// of the Client.
+func (c *Client) NewURLBuilder() *URLBuilder { return c.urls.NewURLBuilder() }
+
+// NewRequest creates a request with the method and URL. If body is not
+// nil, it is encoded as JSON using the Codec of the Client and the
+// Content-Type header is set. Interface upgrade to xgo.JSONer is
+// supported for the body.
+func (c *Client) NewRequest(ctx context.Context, method string, u *url.URL, body interface{}) (*http.Request, error) {
+	const op = "Client.NewRequest"
+
+	var rdr io.Reader
+	if body != nil {
+		buf := getBuffer()
+		defer putBuffer(buf)
+
+		if err := jsonCodecOrDefault(c.Codec).NewEncoder(buf).Encode(jsonBody(body)); err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.Internal, errors.WithText("encode request body"), errors.WithErr(err))
+		}
+		// The pooled buffer is reused, the request needs its own copy.
+		rdr = bytes.NewReader(append([]byte(nil), buf.Bytes()...))
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, u.String(), rdr)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+	}
+
+	if body != nil {
+		r.Header.Set("Content-Type", contentTypeJSON)
+	}
+	return r, nil
+}
+
+// Do sends the request using ctx and decodes the JSON response body into
+// v. The response body is discarded if v is nil.
+//
+// If the response status is not 2xx, the error is constructed using
+// errors.WithResp and has the Kind derived from the status. If the
+// request could not be sent, the error is of kind errors.Unavailable,
+// errors.DeadlineExceeded if it timed out or errors.Canceled if ctx was
+// canceled. The op, which is typically
+// the name of the calling method, is set on the returned error.
+//
+//	func (c *UserClient) User(ctx context.Context, id string) (myapp.User, error) {
+//		const op = "UserClient.User"
+//
+//		u := c.client.NewURLBuilder().Path("/users/{id}").PathParam("id", id).URL()
+//		req, err := c.client.NewRequest(ctx, http.MethodGet, u, nil)
+//		if err != nil {
+//			return myapp.User{}, errors.E(errors.WithOp(op), errors.WithErr(err))
+//		}
+//
+//		var user myapp.User
+//		if err := c.client.Do(ctx, op, req, &user); err != nil {
+//			return myapp.User{}, err
+//		}
+//
+//		return user, nil
+//	}
+func (c *Client) Do(ctx context.Context, op string, req *http.Request, v interface{}) error {
+	resp, err := c.send(req.Clone(ctx))
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.E(errors.WithOp(op), errors.WithResp(resp))
+	}
+
+	if v == nil || resp.StatusCode == http.StatusNoContent {
+		// Drain the body so that the connection can be reused.
+		io.Copy(io.Discard, resp.Body) //nolint:errcheck
+		return nil
+	}
+
+	if err := jsonCodecOrDefault(c.Codec).NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.E(
+			errors.WithOp(op),
+			errors.Internal,
+			errors.WithTextf("[%s] %s: decode response body", req.Method, req.URL.RequestURI()),
+			errors.WithErr(err),
+		)
+	}
+	return nil
+}
+
+// Do sends the request using the Client and decodes the JSON response
+// body into a new value of type Resp. See Client.Do for the details of
+// the errors returned.
+//
+//	req, err := client.NewRequest(ctx, http.MethodGet, u, nil)
+//	// ...
+//	user, err := httputil.Do[myapp.User](ctx, client, op, req)
+func Do[Resp any](ctx context.Context, c *Client, op string, req *http.Request) (Resp, error) {
+	var resp Resp
+	if err := c.Do(ctx, op, req, &resp); err != nil {
+		var zero Resp
+		return zero, err
+	}
+
+	return resp, nil
+}
+
+// send sets the default headers on the request and sends it.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	for k, vv := range c.Header {
+		if _, ok := req.Header[k]; !ok {
+			req.Header[k] = append([]string(nil), vv...)
+		}
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, transportErr(err)
+	}
+	return resp, nil
+}
+
+// transportErr classifies the error returned by http.Client.Do. The
+// Kind of an error returned by a middleware for http.RoundTripper, such
+// as RateLimitingTransport, is retained.
+func transportErr(err error) error {
+	kind := errors.Unavailable
+	var ne net.Error
+	switch {
+	case errors.WhatKind(err) != errors.Unknown:
+		kind = errors.WhatKind(err)
+
+	case errors.Is(err, context.Canceled):
+		kind = errors.Canceled
+
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		kind = errors.DeadlineExceeded
+	}
+
+	return errors.E(kind, errors.WithText("send request"), errors.WithErr(err))
+}
@@ -0,0 +1,176 @@
+package httputil_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestClientDo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var jr httputil.JSONResponder
+		switch r.URL.Path {
+		case "/api/people/donald":
+			if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Accept") != "application/json" {
+				jr.Error(r, w, errors.E(errors.Unauthenticated))
+				return
+			}
+			jr.Respond(r, w, Person{Name: "Donald", Age: 33})
+
+		case "/api/people":
+			var p Person
+			if err := (httputil.JSONDecoder{}).Decode(r, &p); err != nil {
+				jr.Error(r, w, err)
+				return
+			}
+			jr.RespondWithStatus(r, w, http.StatusCreated, p)
+
+		case "/api/people/newman":
+			w.WriteHeader(http.StatusNoContent)
+
+		case "/api/slow":
+			time.Sleep(50 * time.Millisecond)
+
+		case "/api/invalid":
+			io.WriteString(w, "{") //nolint:errcheck
+
+		default:
+			jr.Error(r, w, errors.E(errors.NotFound))
+		}
+	}))
+	defer srv.Close()
+
+	c, err := httputil.NewClient(srv.URL + "/api")
+	if err != nil {
+		t.Fatalf("NewClient() error=%v", err)
+	}
+	c.Header = http.Header{"Authorization": {"Bearer token"}}
+
+	newRequest := func(t *testing.T, method, path string, body interface{}) *http.Request {
+		t.Helper()
+
+		req, err := c.NewRequest(context.Background(), method, c.NewURLBuilder().Path(path).URL(), body)
+		if err != nil {
+			t.Fatalf("Client.NewRequest() error=%v", err)
+		}
+		return req
+	}
+
+	const op = "PeopleClient.Person"
+	cases := []struct {
+		name     string
+		ctx      context.Context
+		req      *http.Request
+		want     Person
+		wantErr  error
+		wantKind errors.Kind
+	}{
+		{
+			name: "Get",
+			req:  newRequest(t, http.MethodGet, "/people/donald", nil),
+			want: Person{Name: "Donald", Age: 33},
+		},
+		{
+			name: "Post",
+			req:  newRequest(t, http.MethodPost, "/people", Person{Name: "Kramer", Age: 41}),
+			want: Person{Name: "Kramer", Age: 41},
+		},
+		{
+			name: "NoContent",
+			req:  newRequest(t, http.MethodDelete, "/people/newman", nil),
+		},
+		{
+			name: "NotFound",
+			req:  newRequest(t, http.MethodGet, "/people/jerry", nil),
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.NotFound,
+				errors.WithText("[GET] /api/people/jerry: 404 Not Found"),
+				errors.WithData(json.RawMessage(`{"success":false,"msg":"","errors":[{"code":"NOT_FOUND","error":"not found","msg":""}]}`+"\n")),
+			),
+			wantKind: errors.NotFound,
+		},
+		{
+			name: "DecodeError",
+			req:  newRequest(t, http.MethodGet, "/invalid", nil),
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.Internal,
+				errors.WithText("[GET] /api/invalid: decode response body"),
+			),
+			wantKind: errors.Internal,
+		},
+		{
+			name:     "DeadlineExceeded",
+			ctx:      timeoutCtx(t, time.Millisecond),
+			req:      newRequest(t, http.MethodGet, "/slow", nil),
+			wantErr:  errors.E(errors.WithOp(op)),
+			wantKind: errors.DeadlineExceeded,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := tc.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			got, err := httputil.Do[Person](ctx, c, op, tc.req)
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("Do() error diff: %s", errorDiff(tc.wantErr, err))
+			}
+			if tc.wantErr != nil && errors.WhatKind(err) != tc.wantKind {
+				t.Errorf("Do() error kind=%v; want=%v", errors.WhatKind(err), tc.wantKind)
+			}
+			if got != tc.want {
+				t.Errorf("Do()=%+v; want=%+v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Unavailable", func(t *testing.T) {
+		down, err := httputil.NewClient("http://127.0.0.1:1")
+		if err != nil {
+			t.Fatalf("NewClient() error=%v", err)
+		}
+
+		req, err := down.NewRequest(context.Background(), http.MethodGet, down.NewURLBuilder().Path("/people").URL(), nil)
+		if err != nil {
+			t.Fatalf("Client.NewRequest() error=%v", err)
+		}
+
+		err = down.Do(context.Background(), op, req, nil)
+		if errors.WhatKind(err) != errors.Unavailable {
+			t.Errorf("Client.Do() error=%v; want error of kind Unavailable", err)
+		}
+	})
+}
+
+func TestClientNewRequest(t *testing.T) {
+	c, err := httputil.NewClient("api.example.com")
+	if err != nil {
+		t.Fatalf("NewClient() error=%v", err)
+	}
+
+	req, err := c.NewRequest(context.Background(), http.MethodPost, c.NewURLBuilder().Path("/users").URL(), marshalFailer{err: errors.New("fail")})
+	wantErr := errors.E(errors.WithOp("Client.NewRequest"), errors.Internal, errors.WithText("encode request body"))
+	if !matchErrors(wantErr, err) {
+		t.Errorf("Client.NewRequest() error diff: %s", errorDiff(wantErr, err))
+	}
+	if req != nil {
+		t.Errorf("Client.NewRequest()=%v; want nil", req)
+	}
+}
+
+func timeoutCtx(t *testing.T, d time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	t.Cleanup(cancel)
+	return ctx
+}
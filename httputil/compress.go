@@ -0,0 +1,505 @@
+package httputil
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// DefaultCompressMinSize is the minimum size of the response body, in
+// bytes, for it to be compressed if CompressOptions.MinSize is zero.
+const DefaultCompressMinSize = 1024
+
+// CompressOptions configures the middleware returned by
+// CompressingMiddleware.
+type CompressOptions struct {
+	// MinSize is the minimum size of the response body, in bytes, for it
+	// to be compressed. Smaller responses are sent uncompressed as the
+	// overhead outweighs the savings. DefaultCompressMinSize is used if
+	// MinSize is zero. All responses are compressed if MinSize is
+	// negative.
+	//
+	// The response is buffered up to MinSize bytes unless the handler
+	// sets the Content-Length header or flushes the response.
+	MinSize int
+
+	// Level is the compression level, as defined by the compress/flate
+	// package. gzip.DefaultCompression is used if Level is zero.
+	Level int
+
+	// SkipContentTypes is the list of content types for which the
+	// response is not compressed. These are used in addition to the
+	// built-in list of content types which are already compressed, such
+	// as "image/png" and "application/zip". A wildcard subtype, such as
+	// "video/*", is supported. Optional.
+	SkipContentTypes []string
+}
+
+// compressedContentTypes is the list of content types which are already
+// compressed.
+var compressedContentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"image/avif",
+	"video/*",
+	"audio/*",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zstd",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+}
+
+// CompressingMiddleware returns a middleware which compresses the
+// response body using gzip or deflate as per the Accept-Encoding header
+// of the request. Accept-Encoding is added to the Vary header of all
+// responses unless it is already listed.
+//
+// The response is not compressed if it is smaller than MinSize, if the
+// content type is listed in SkipContentTypes or is already compressed,
+// if the Content-Encoding header is already set, or for responses to
+// HEAD requests, partial content and responses without a body.
+//
+// Flushing the response, as done by JSONResponder.Stream and
+// SSEResponder, flushes the compressed data written so far to the
+// client so that streaming responses are not held back.
+//
+// A strong ETag is made weak when the response is compressed as the
+// compressed bytes are not guaranteed to be the same across responses.
+// The weak entity tags in the If-Match header are made strong before
+// the request is passed on so that they still match.
+//
+// The ResponseWriter passed to the handler implements http.Hijacker if
+// the underlying ResponseWriter does. A compressed response cannot be
+// hijacked.
+//
+//	http.Handle("/", httputil.CompressingMiddleware(httputil.CompressOptions{})(mux))
+func CompressingMiddleware(opts CompressOptions) func(http.Handler) http.Handler {
+	level := opts.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	minSize := opts.MinSize
+	if minSize == 0 {
+		minSize = DefaultCompressMinSize
+	}
+
+	// The compression writers are expensive to allocate and hence are
+	// pooled.
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() interface{} {
+			zw, _ := gzip.NewWriterLevel(io.Discard, level)
+			return zw
+		}},
+		"deflate": {New: func() interface{} {
+			zw, _ := zlib.NewWriterLevel(io.Discard, level)
+			return zw
+		}},
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addVary(w.Header(), "Accept-Encoding")
+			r = strongIfMatch(r)
+
+			encoding := acceptedEncoding(r.Header)
+			if encoding == "" || r.Method == http.MethodHead {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				opts:           opts,
+				minSize:        minSize,
+				encoding:       encoding,
+				pool:           pools[encoding],
+			}
+			if hijackerOf(w) != nil {
+				h.ServeHTTP(hijackCompressWriter{cw}, r)
+			} else {
+				h.ServeHTTP(cw, r)
+			}
+			// The error, if any, cannot be reported as the handler has
+			// returned and the client has most likely gone away.
+			cw.close() //nolint:errcheck
+		})
+	}
+}
+
+// addVary adds the header name to the Vary header unless it is already
+// listed, such as by an outer CompressingMiddleware.
+func addVary(h http.Header, name string) {
+	for _, v := range h.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field == "*" || strings.EqualFold(field, name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
+
+// acceptedEncoding returns the preferred content coding, "gzip" or
+// "deflate", as per the Accept-Encoding header. It returns an empty
+// string if neither is acceptable.
+func acceptedEncoding(h http.Header) string {
+	var (
+		best  string
+		bestQ float64
+		anyQ  = -1.0
+		seen  = make(map[string]bool, 2)
+	)
+	for _, hv := range h.Values("Accept-Encoding") {
+		for _, c := range strings.Split(hv, ",") {
+			coding, q := parseCoding(c)
+			switch coding {
+			case "gzip", "x-gzip":
+				coding = "gzip"
+
+			case "deflate":
+
+			case "*":
+				anyQ = q
+				continue
+
+			default:
+				continue
+			}
+
+			seen[coding] = true
+			if q <= 0 {
+				continue
+			}
+			// gzip is listed first in the header more often than not and is
+			// preferred on a tie.
+			if q > bestQ || (q == bestQ && coding == "gzip") {
+				best, bestQ = coding, q
+			}
+		}
+	}
+
+	// The wildcard matches the codings not explicitly listed.
+	if anyQ > bestQ {
+		for _, coding := range []string{"gzip", "deflate"} {
+			if !seen[coding] {
+				return coding
+			}
+		}
+	}
+	return best
+}
+
+// parseCoding parses the content coding and its quality value, which
+// defaults to 1, from an element of the Accept-Encoding header.
+func parseCoding(s string) (coding string, q float64) {
+	coding, params, _ := strings.Cut(s, ";")
+	coding = strings.ToLower(strings.TrimSpace(coding))
+
+	q = 1
+	for _, p := range strings.Split(params, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok || strings.ToLower(strings.TrimSpace(k)) != "q" {
+			continue
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			q = f
+		}
+	}
+	return coding, q
+}
+
+// compressWriter compresses the response body once it has been
+// determined that the response is eligible for compression. Until then,
+// the status and the body are held back.
+type compressWriter struct {
+	http.ResponseWriter
+
+	opts     CompressOptions
+	minSize  int
+	encoding string
+	pool     *sync.Pool
+
+	status      int
+	wroteHeader bool
+	started     bool
+	buf         bytes.Buffer
+	zw          compressor
+}
+
+// compressor is implemented by gzip.Writer and zlib.Writer.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+
+	// Informational (1xx) headers are passed through as is.
+	if status >= 100 && status <= 199 && status != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	cw.status = status
+	cw.wroteHeader = true
+
+	h := cw.Header()
+	switch {
+	// The response to the conditional request carries the ETag of the
+	// compressed response which would have been sent otherwise.
+	case status == http.StatusNotModified:
+		weakenETag(h)
+		cw.start(false)
+
+	case !compressibleStatus(status) || h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "":
+		cw.start(false)
+
+	case h.Get("Content-Length") != "":
+		n, err := strconv.Atoi(h.Get("Content-Length"))
+		cw.start(err == nil && n >= cw.minSize && cw.compressible())
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.started {
+		cw.buf.Write(b)
+		if cw.buf.Len() < cw.minSize {
+			return len(b), nil
+		}
+
+		if err := cw.startBuffered(cw.compressible()); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if cw.zw != nil {
+		return cw.zw.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher. The buffered data, if any, and the
+// data pending in the compressor are flushed to the client.
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	// A flushed response is being streamed and is compressed
+	// irrespective of the size of the data written so far.
+	if !cw.started {
+		if err := cw.startBuffered(cw.compressible()); err != nil {
+			return
+		}
+	}
+
+	if cw.zw != nil {
+		if err := cw.zw.Flush(); err != nil {
+			return
+		}
+	}
+	if f := flusherOf(cw.ResponseWriter); f != nil {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter. It is used by
+// http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter { return cw.ResponseWriter }
+
+// hijack writes the buffered data, if any, uncompressed and hijacks the
+// connection. It fails if the response is already being compressed.
+func (cw *compressWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if cw.zw != nil {
+		return nil, nil, errors.E(errors.WithOp("CompressingMiddleware"), errors.WithText("cannot hijack a compressed response"))
+	}
+	if cw.wroteHeader && !cw.started {
+		if err := cw.startBuffered(false); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	conn, rw, err := hijackerOf(cw.ResponseWriter).Hijack()
+	if err == nil {
+		// The response must not be written by close.
+		cw.wroteHeader, cw.started = true, true
+	}
+	return conn, rw, err
+}
+
+type hijackCompressWriter struct{ *compressWriter }
+
+// Hijack implements http.Hijacker.
+func (cw hijackCompressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return cw.hijack() }
+
+// weakenETag makes the ETag in the header weak, if it is strong.
+func weakenETag(h http.Header) {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+}
+
+// strongIfMatch returns the request with the weak entity tags in the
+// If-Match header made strong. If-Match uses the strong comparison and
+// would otherwise never match the ETag weakened for a compressed
+// response.
+func strongIfMatch(r *http.Request) *http.Request {
+	list := r.Header.Get("If-Match")
+	if !strings.Contains(list, "W/") {
+		return r
+	}
+
+	var etags []string
+	for list = strings.TrimSpace(list); list != ""; {
+		var etag string
+		etag, list = scanETag(list)
+		if etag == "" {
+			// Leave the malformed header as is.
+			return r
+		}
+		etags = append(etags, strings.TrimPrefix(etag, "W/"))
+		list = strings.TrimLeft(list, " \t,")
+	}
+
+	r = r.Clone(r.Context())
+	r.Header.Set("If-Match", strings.Join(etags, ", "))
+	return r
+}
+
+// startBuffered starts the response and writes the buffered data.
+func (cw *compressWriter) startBuffered(compress bool) error {
+	cw.start(compress)
+
+	b := cw.buf.Bytes()
+	cw.buf = bytes.Buffer{}
+	if len(b) == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.zw != nil {
+		_, err = cw.zw.Write(b)
+	} else {
+		_, err = cw.ResponseWriter.Write(b)
+	}
+	return err
+}
+
+// start writes the status and headers, setting the Content-Encoding if
+// compress is true.
+func (cw *compressWriter) start(compress bool) {
+	cw.started = true
+
+	if compress {
+		h := cw.Header()
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		weakenETag(h)
+
+		cw.zw = cw.pool.Get().(compressor)
+		cw.zw.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+// compressible reports whether the content type of the response is
+// eligible for compression. If the Content-Type header is not set, it
+// is set by sniffing the buffered data as the server would otherwise
+// sniff the compressed data.
+func (cw *compressWriter) compressible() bool {
+	h := cw.Header()
+	if strings.Contains(h.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		if _, ok := h["Content-Type"]; ok {
+			// Content sniffing has been disabled by the handler.
+			return true
+		}
+		if cw.buf.Len() == 0 {
+			return false
+		}
+		ct = http.DetectContentType(cw.buf.Bytes())
+		h.Set("Content-Type", ct)
+	}
+
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return true
+	}
+	return !matchContentType(mt, compressedContentTypes) && !matchContentType(mt, cw.opts.SkipContentTypes)
+}
+
+// close writes the buffered data, if any, and finishes the compressed
+// stream.
+func (cw *compressWriter) close() error {
+	if !cw.wroteHeader {
+		// The handler did not write a response. The server writes the
+		// default response.
+		return nil
+	}
+	if !cw.started {
+		compress := cw.buf.Len() > 0 && cw.buf.Len() >= cw.minSize && cw.compressible()
+		if err := cw.startBuffered(compress); err != nil {
+			return err
+		}
+	}
+	if cw.zw == nil {
+		return nil
+	}
+
+	err := cw.zw.Close()
+	cw.zw.Reset(io.Discard)
+	cw.pool.Put(cw.zw)
+	cw.zw = nil
+	return err
+}
+
+// compressibleStatus reports whether a response with the status can be
+// compressed. Responses without a body and partial content are not.
+func compressibleStatus(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified && status != http.StatusPartialContent
+}
+
+// matchContentType reports whether the media type matches one of the
+// content types in the list. A wildcard subtype, such as "image/*",
+// is supported.
+func matchContentType(mt string, list []string) bool {
+	for _, ct := range list {
+		ct = strings.ToLower(ct)
+		if ct == mt {
+			return true
+		}
+		if prefix := strings.TrimSuffix(ct, "*"); prefix != ct && strings.HasPrefix(mt, prefix) {
+			return true
+		}
+	}
+	return false
+}
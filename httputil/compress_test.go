@@ -0,0 +1,451 @@
+package httputil_test
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestCompressingMiddleware(t *testing.T) {
+	large := strings.Repeat("Donald ", 200)
+	largeJSON := `{"Name":"` + large + `","Age":33,"V":null}` + "\n"
+
+	respond := func(v interface{}) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var jr httputil.JSONResponder
+			jr.Respond(r, w, v)
+		})
+	}
+
+	cases := []struct {
+		name        string
+		opts        httputil.CompressOptions
+		method      string
+		headers     map[string]string
+		h           http.Handler
+		wantStatus  int
+		wantHeaders map[string]string
+		wantBody    string
+	}{
+		{
+			name:       "Gzip",
+			headers:    map[string]string{"Accept-Encoding": "gzip, deflate, br"},
+			h:          respond(Person{Name: large, Age: 33}),
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Content-Type":     "application/json; charset=utf-8",
+				"Content-Encoding": "gzip",
+				"Vary":             "Accept-Encoding",
+			},
+			wantBody: largeJSON,
+		},
+		{
+			name:       "DeflatePreferred",
+			headers:    map[string]string{"Accept-Encoding": "gzip;q=0.5, deflate"},
+			h:          respond(Person{Name: large, Age: 33}),
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Content-Type":     "application/json; charset=utf-8",
+				"Content-Encoding": "deflate",
+				"Vary":             "Accept-Encoding",
+			},
+			wantBody: largeJSON,
+		},
+		{
+			name:       "Wildcard",
+			headers:    map[string]string{"Accept-Encoding": "gzip;q=0, *"},
+			h:          respond(Person{Name: large, Age: 33}),
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Content-Type":     "application/json; charset=utf-8",
+				"Content-Encoding": "deflate",
+				"Vary":             "Accept-Encoding",
+			},
+			wantBody: largeJSON,
+		},
+		{
+			name:       "NotAccepted",
+			headers:    map[string]string{"Accept-Encoding": "br, gzip;q=0"},
+			h:          respond(Person{Name: large, Age: 33}),
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Content-Type": "application/json; charset=utf-8",
+				"Vary":         "Accept-Encoding",
+			},
+			wantBody: largeJSON,
+		},
+		{
+			name:       "BelowMinSize",
+			headers:    map[string]string{"Accept-Encoding": "gzip"},
+			h:          respond(Person{Name: "Donald", Age: 33}),
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Content-Type": "application/json; charset=utf-8",
+				"Vary":         "Accept-Encoding",
+			},
+			wantBody: `{"Name":"Donald","Age":33,"V":null}` + "\n",
+		},
+		{
+			name:    "Buffered",
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			h: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				jr := httputil.JSONResponder{Buffered: true}
+				jr.Respond(r, w, Person{Name: large, Age: 33})
+			}),
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Content-Type":     "application/json; charset=utf-8",
+				"Content-Encoding": "gzip",
+				"Vary":             "Accept-Encoding",
+			},
+			wantBody: largeJSON,
+		},
+		{
+			name:    "BufferedBelowMinSize",
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			h: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				jr := httputil.JSONResponder{Buffered: true}
+				jr.Respond(r, w, Person{Name: "Donald", Age: 33})
+			}),
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Content-Type":   "application/json; charset=utf-8",
+				"Content-Length": "36",
+				"Vary":           "Accept-Encoding",
+			},
+			wantBody: `{"Name":"Donald","Age":33,"V":null}` + "\n",
+		},
+		{
+			name:    "Error",
+			opts:    httputil.CompressOptions{MinSize: -1},
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			h: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var jr httputil.JSONResponder
+				jr.Error(r, w, errors.E(errors.NotFound))
+			}),
+			wantStatus: http.StatusNotFound,
+			wantHeaders: map[string]string{
+				"Content-Type":     "application/json; charset=utf-8",
+				"Content-Encoding": "gzip",
+				"Vary":             "Accept-Encoding",
+			},
+			wantBody: `{"success":false,"msg":"","errors":[{"code":"NOT_FOUND","error":"not found","msg":""}]}` + "\n",
+		},
+		{
+			name:    "SniffedContentType",
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			h: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				io.WriteString(w, large) //nolint:errcheck
+			}),
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Content-Type":     "text/plain; charset=utf-8",
+				"Content-Encoding": "gzip",
+				"Vary":             "Accept-Encoding",
+			},
+			wantBody: large,
+		},
+		{
+			name:    "AlreadyCompressed",
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			h: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				io.WriteString(w, large) //nolint:errcheck
+			}),
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Content-Type": "image/png",
+				"Vary":         "Accept-Encoding",
+			},
+			wantBody: large,
+		},
+		{
+			name:    "SkipContentTypes",
+			opts:    httputil.CompressOptions{SkipContentTypes: []string{"text/*"}},
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			h: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/csv")
+				io.WriteString(w, large) //nolint:errcheck
+			}),
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Content-Type": "text/csv",
+				"Vary":         "Accept-Encoding",
+			},
+			wantBody: large,
+		},
+		{
+			name:    "ContentEncodingSet",
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			h: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Content-Encoding", "br")
+				io.WriteString(w, large) //nolint:errcheck
+			}),
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Content-Type":     "text/plain",
+				"Content-Encoding": "br",
+				"Vary":             "Accept-Encoding",
+			},
+			wantBody: large,
+		},
+		{
+			name:    "NoContent",
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			h: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}),
+			wantStatus:  http.StatusNoContent,
+			wantHeaders: map[string]string{"Vary": "Accept-Encoding"},
+		},
+		{
+			name:    "Head",
+			method:  http.MethodHead,
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			h: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				jr := httputil.JSONResponder{Buffered: true}
+				jr.Respond(r, w, Person{Name: large, Age: 33})
+			}),
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Content-Type":   "application/json; charset=utf-8",
+				"Content-Length": "1430",
+				"Vary":           "Accept-Encoding",
+			},
+		},
+		{
+			name:    "StrongETagWeakened",
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			h: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var jr httputil.JSONResponder
+				jr.Respond(r, w, versionedPerson{Name: large, Version: 1})
+			}),
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Content-Type":     "application/json; charset=utf-8",
+				"Content-Encoding": "gzip",
+				"Etag":             `W/"v1"`,
+				"Vary":             "Accept-Encoding",
+			},
+			wantBody: `{"name":"` + large + `"}` + "\n",
+		},
+		{
+			name:    "WeakETagNotModified",
+			headers: map[string]string{"Accept-Encoding": "gzip", "If-None-Match": `W/"v1"`},
+			h: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var jr httputil.JSONResponder
+				jr.Respond(r, w, versionedPerson{Name: large, Version: 1})
+			}),
+			wantStatus: http.StatusNotModified,
+			wantHeaders: map[string]string{
+				"Etag": `W/"v1"`,
+				"Vary": "Accept-Encoding",
+			},
+		},
+		{
+			name:    "WeakETagIfMatch",
+			method:  http.MethodPut,
+			headers: map[string]string{"Accept-Encoding": "gzip", "If-Match": `"v0", W/"v1"`},
+			h: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var jr httputil.JSONResponder
+				if err := jr.CheckPreconditions(r, versionedPerson{Version: 1}); err != nil {
+					jr.Error(r, w, err)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}),
+			wantStatus:  http.StatusNoContent,
+			wantHeaders: map[string]string{"Vary": "Accept-Encoding"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "http://host.com/users", nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			httputil.CompressingMiddleware(tc.opts)(tc.h).ServeHTTP(rec, r)
+
+			res := rec.Result()
+			if res.StatusCode != tc.wantStatus {
+				t.Errorf("StatusCode=%d; want=%d", res.StatusCode, tc.wantStatus)
+			}
+			if got := headers(res); !reflect.DeepEqual(got, tc.wantHeaders) {
+				t.Errorf("Headers=%q; want=%q", got, tc.wantHeaders)
+			}
+			if got := decompressBody(t, res.Header.Get("Content-Encoding"), rec.Body); got != tc.wantBody {
+				t.Errorf("Body=%q; want=%q", got, tc.wantBody)
+			}
+		})
+	}
+
+	t.Run("VaryListed", func(t *testing.T) {
+		for _, preset := range []string{"", "Origin, accept-encoding", "*"} {
+			rec := httptest.NewRecorder()
+			if preset != "" {
+				rec.Header().Set("Vary", preset)
+			}
+			r := httptest.NewRequest(http.MethodGet, "http://host.com/users", nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+
+			mw := httputil.CompressingMiddleware(httputil.CompressOptions{})
+			mw(mw(respond(Person{Name: large, Age: 33}))).ServeHTTP(rec, r)
+
+			want := []string{"Accept-Encoding"}
+			if preset != "" {
+				want = []string{preset}
+			}
+			if got := rec.Result().Header.Values("Vary"); !reflect.DeepEqual(got, want) {
+				t.Errorf("Vary=%q with %q preset; want=%q", got, preset, want)
+			}
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "http://host.com/users", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+
+		var flushed []string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var jr httputil.JSONResponder
+			jr.Stream(r, w, httputil.StreamOptions{}, func(yield func(v interface{}) bool) error {
+				for _, name := range []string{"Donald", "Kramer"} {
+					if !yield(Person{Name: name}) {
+						return nil
+					}
+					// The compressed data must be flushed to the client after
+					// each value.
+					flushed = append(flushed, partialGunzip(t, rec.Body.String()))
+				}
+				return nil
+			})
+		})
+		httputil.CompressingMiddleware(httputil.CompressOptions{})(h).ServeHTTP(rec, r)
+
+		wantFlushed := []string{
+			`{"Name":"Donald","Age":0,"V":null}` + "\n",
+			`{"Name":"Donald","Age":0,"V":null}` + "\n" + `{"Name":"Kramer","Age":0,"V":null}` + "\n",
+		}
+		if !reflect.DeepEqual(flushed, wantFlushed) {
+			t.Errorf("Flushed=%q; want=%q", flushed, wantFlushed)
+		}
+		if !rec.Flushed {
+			t.Error("Flushed=false; want=true")
+		}
+		if got := rec.Result().Header.Get("Content-Encoding"); got != "gzip" {
+			t.Errorf("Content-Encoding=%q; want=%q", got, "gzip")
+		}
+		if got := decompressBody(t, "gzip", rec.Body); got != wantFlushed[1] {
+			t.Errorf("Body=%q; want=%q", got, wantFlushed[1])
+		}
+	})
+
+	t.Run("Hijack", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "http://host.com/users", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+
+		var hijackErr error
+		h := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if hijackErr = err; err == nil {
+				conn.Close()
+			}
+		})
+		rec := httptest.NewRecorder()
+		httputil.CompressingMiddleware(httputil.CompressOptions{})(h).ServeHTTP(hijackRecorder{rec}, r)
+
+		if hijackErr != nil {
+			t.Errorf("Hijack() error=%v", hijackErr)
+		}
+		if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+			t.Errorf("Response=%d %q; want nothing written", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("HijackCompressed", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "http://host.com/users", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+
+		var hijackErr error
+		h := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			io.WriteString(w, large) //nolint:errcheck
+			w.(http.Flusher).Flush()
+			_, _, hijackErr = w.(http.Hijacker).Hijack()
+		})
+		httputil.CompressingMiddleware(httputil.CompressOptions{})(h).ServeHTTP(hijackRecorder{httptest.NewRecorder()}, r)
+
+		if hijackErr == nil {
+			t.Error("Hijack() error=nil; want error")
+		}
+	})
+
+	t.Run("HijackNotSupported", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "http://host.com/users", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+
+		var hijacker bool
+		h := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, hijacker = w.(http.Hijacker)
+		})
+		httputil.CompressingMiddleware(httputil.CompressOptions{})(h).ServeHTTP(httptest.NewRecorder(), r)
+
+		if hijacker {
+			t.Error("ResponseWriter implements http.Hijacker=true; want false")
+		}
+	})
+}
+
+func decompressBody(t *testing.T, encoding string, r io.Reader) string {
+	t.Helper()
+
+	var err error
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(r)
+
+	case "deflate":
+		r, err = zlib.NewReader(r)
+	}
+	if err != nil {
+		t.Fatalf("Decompress: %v", err)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Decompress: %v", err)
+	}
+	return string(b)
+}
+
+// partialGunzip decompresses the data flushed so far, ignoring the
+// missing end of the stream.
+func partialGunzip(t *testing.T, s string) string {
+	t.Helper()
+
+	zr, err := gzip.NewReader(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+
+	b, err := io.ReadAll(zr)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		t.Fatalf("Decompress: %v", err)
+	}
+	return string(b)
+}
@@ -0,0 +1,214 @@
+package httputil
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// DefaultDecompressMaxBytes is used by DecompressingDecoderMiddleware
+// for the zero value of DecompressOptions.MaxBytes.
+const DefaultDecompressMaxBytes = 10 << 20 // 10 MiB
+
+// DecompressFunc returns a reader which decompresses the data read from
+// r.
+type DecompressFunc func(r io.Reader) (io.ReadCloser, error)
+
+// DecompressOptions configures the DecoderMiddleware returned by
+// DecompressingDecoderMiddleware.
+type DecompressOptions struct {
+	// MaxBytes limits the size of the decompressed request body. This
+	// guards against decompression bombs. If the limit is exceeded, the
+	// request body read fails with the same error as
+	// http.MaxBytesReader. DefaultDecompressMaxBytes is used if MaxBytes
+	// is zero. No limit is applied if MaxBytes is negative, which leaves
+	// the server exposed to decompression bombs.
+	MaxBytes int64
+
+	// Decompressors maps a content coding, in lower case, to the
+	// DecompressFunc for it. These are used in addition to the
+	// built-in support for "gzip", "x-gzip" and "deflate" and take
+	// precedence over them. Optional.
+	//
+	// This can be used to plug in support for codings such as "br"
+	// which are not supported by the standard library.
+	Decompressors map[string]DecompressFunc
+}
+
+var defaultDecompressors = map[string]DecompressFunc{
+	"gzip":    gzipDecompress,
+	"x-gzip":  gzipDecompress,
+	"deflate": zlibDecompress,
+}
+
+// DecompressingDecoderMiddleware returns a DecoderMiddleware which
+// transparently decompresses the request body as per the
+// Content-Encoding header before delegating to the wrapped Decoder.
+// Multiple codings are undone in the reverse order of application.
+//
+// If the request specifies an unsupported content coding, an error of
+// kind ErrKindUnsupportedMediaType is returned.
+//
+//	var dec httputil.Decoder
+//	{
+//		dec = httputil.JSONDecoder{}
+//		dec = httputil.DecompressingDecoderMiddleware(httputil.DecompressOptions{
+//			MaxBytes: 1 << 20, // 1 MiB
+//		})(dec)
+//	}
+func DecompressingDecoderMiddleware(opts DecompressOptions) DecoderMiddleware {
+	return func(d Decoder) Decoder {
+		return DecodeFunc(func(r *http.Request, v interface{}) error {
+			const op = "DecompressingDecoderMiddleware"
+
+			codings := contentCodings(r.Header)
+			if len(codings) == 0 {
+				return d.Decode(r, v)
+			}
+
+			body, err := opts.decompress(r.Body, codings)
+			if err != nil {
+				return errors.E(errors.WithOp(op), errors.WithErr(err))
+			}
+			defer body.Close() //nolint:errcheck
+
+			// Shallow copy the request so that the caller's request is not
+			// modified.
+			r2 := *r
+			r2.Header = r.Header.Clone()
+			r2.Header.Del("Content-Encoding")
+			r2.Header.Del("Content-Length")
+			r2.ContentLength = -1
+			r2.Body = body
+
+			return d.Decode(&r2, v)
+		})
+	}
+}
+
+func (o DecompressOptions) decompress(body io.ReadCloser, codings []string) (io.ReadCloser, error) {
+	src := &srcReader{r: body}
+	rc := &multiReadCloser{Reader: src}
+	// The codings are listed in the order in which they were applied.
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := codings[i]
+		if coding == "identity" {
+			continue
+		}
+
+		f, ok := o.decompressor(coding)
+		if !ok {
+			rc.Close() //nolint:errcheck
+			return nil, errors.E(
+				ErrKindUnsupportedMediaType,
+				errors.WithTextf("Content-Encoding '%s' is not supported", coding),
+			)
+		}
+
+		dr, err := f(rc.Reader)
+		if err != nil {
+			rc.Close() //nolint:errcheck
+			// An empty or truncated body is malformed compressed data, not a
+			// failure to read the request body.
+			if err == src.err && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return nil, err
+			}
+
+			msg := "Request body could not be decompressed"
+			return nil, errors.E(errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err))
+		}
+
+		rc.Reader = decompressErrReader{Reader: dr, src: src}
+		rc.closers = append(rc.closers, dr)
+	}
+
+	maxBytes := o.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = DefaultDecompressMaxBytes
+	}
+	rc.Reader = limitBody(io.NopCloser(rc.Reader), maxBytes)
+
+	return rc, nil
+}
+
+func (o DecompressOptions) decompressor(coding string) (DecompressFunc, bool) {
+	if f, ok := o.Decompressors[coding]; ok {
+		return f, true
+	}
+
+	f, ok := defaultDecompressors[coding]
+	return f, ok
+}
+
+// contentCodings returns the list of content codings, in lower case, from
+// the Content-Encoding header.
+func contentCodings(h http.Header) []string {
+	var codings []string
+	for _, hv := range h.Values("Content-Encoding") {
+		for _, c := range strings.Split(hv, ",") {
+			if c = strings.ToLower(strings.TrimSpace(c)); c != "" {
+				codings = append(codings, c)
+			}
+		}
+	}
+	return codings
+}
+
+func gzipDecompress(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+
+func zlibDecompress(r io.Reader) (io.ReadCloser, error) { return zlib.NewReader(r) }
+
+// srcReader records the last error returned by the underlying reader.
+type srcReader struct {
+	r   io.Reader
+	err error
+}
+
+func (s *srcReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if err != nil {
+		s.err = err
+	}
+	return n, err
+}
+
+// decompressErrReader classifies the errors encountered while
+// decompressing as invalid input. Errors from reading the source, such
+// as the request body being too large, are returned as is.
+type decompressErrReader struct {
+	io.Reader
+
+	src *srcReader
+}
+
+func (d decompressErrReader) Read(p []byte) (int, error) {
+	n, err := d.Reader.Read(p)
+	if err != nil && err != io.EOF && err != d.src.err && errors.WhatKind(err) == errors.Unknown {
+		msg := "Request body could not be decompressed"
+		err = errors.E(errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err))
+	}
+	return n, err
+}
+
+// multiReadCloser reads from the Reader and closes all the closers, in
+// reverse order, when closed. The original request body is not closed
+// since that is the responsibility of the server.
+type multiReadCloser struct {
+	io.Reader
+
+	closers []io.Closer
+}
+
+func (m *multiReadCloser) Close() error {
+	var firstErr error
+	for i := len(m.closers) - 1; i >= 0; i-- {
+		if err := m.closers[i].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,261 @@
+package httputil_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestDecompressingDecoderMiddleware(t *testing.T) {
+	var (
+		method = http.MethodPost
+		url    = "http://host.com/route"
+		body   = `{ "name": "Donald", "age": 33 }`
+		// bomb decompresses to just over DefaultDecompressMaxBytes.
+		bombName = strings.Repeat("a", httputil.DefaultDecompressMaxBytes)
+		bomb     = gzipStr(t, `{ "name": "`+bombName+`" }`)
+	)
+	cases := []struct {
+		name    string
+		opts    httputil.DecompressOptions
+		r       request
+		want    interface{}
+		wantErr error
+	}{
+		{
+			name: "NoContentEncoding",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json"},
+				body:    body,
+			},
+			want: &Person{Name: "Donald", Age: 33},
+		},
+		{
+			name: "Gzip",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+				body:    gzipStr(t, body),
+			},
+			want: &Person{Name: "Donald", Age: 33},
+		},
+		{
+			name: "Deflate",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json", "Content-Encoding": "Deflate"},
+				body:    zlibStr(t, body),
+			},
+			want: &Person{Name: "Donald", Age: 33},
+		},
+		{
+			name: "MultipleCodings",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json", "Content-Encoding": "deflate, identity, gzip"},
+				body:    gzipStr(t, zlibStr(t, body)),
+			},
+			want: &Person{Name: "Donald", Age: 33},
+		},
+		{
+			name: "CustomDecompressor",
+			opts: httputil.DecompressOptions{
+				Decompressors: map[string]httputil.DecompressFunc{
+					"rot13": func(r io.Reader) (io.ReadCloser, error) {
+						b, err := io.ReadAll(r)
+						if err != nil {
+							return nil, err
+						}
+						return io.NopCloser(strings.NewReader(rot13(string(b)))), nil
+					},
+				},
+			},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json", "Content-Encoding": "rot13"},
+				body:    rot13(body),
+			},
+			want: &Person{Name: "Donald", Age: 33},
+		},
+		{
+			name: "UnsupportedEncoding",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json", "Content-Encoding": "br"},
+				body:    body,
+			},
+			wantErr: errors.E(
+				errors.WithOp("DecompressingDecoderMiddleware"),
+				httputil.ErrKindUnsupportedMediaType,
+				errors.WithText("Content-Encoding 'br' is not supported"),
+			),
+		},
+		{
+			name: "InvalidGzipHeader",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+				body:    body,
+			},
+			wantErr: errors.E(
+				errors.WithOp("DecompressingDecoderMiddleware"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body could not be decompressed"),
+			),
+		},
+		{
+			name: "EmptyGzipBody",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+			},
+			wantErr: errors.E(
+				errors.WithOp("DecompressingDecoderMiddleware"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body could not be decompressed"),
+			),
+		},
+		{
+			name: "TruncatedGzipHeader",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+				body:    gzipStr(t, body)[:4],
+			},
+			wantErr: errors.E(
+				errors.WithOp("DecompressingDecoderMiddleware"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body could not be decompressed"),
+			),
+		},
+		{
+			name: "TruncatedGzipBody",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+				body:    gzipStr(t, body)[:20],
+			},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body could not be decompressed"),
+			),
+		},
+		{
+			name: "DecompressedBodyTooLarge",
+			opts: httputil.DecompressOptions{MaxBytes: 64},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+				body:    gzipStr(t, `{ "name": "`+strings.Repeat("a", 1024)+`" }`),
+			},
+			wantErr: errors.E(errors.WithOp("JSONDecoder.Decode"), httputil.ErrKindRequestEntityTooLarge),
+		},
+		{
+			name: "DefaultMaxBytes",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+				body:    bomb,
+			},
+			wantErr: errors.E(errors.WithOp("JSONDecoder.Decode"), httputil.ErrKindRequestEntityTooLarge),
+		},
+		{
+			name: "Unlimited",
+			opts: httputil.DecompressOptions{MaxBytes: -1},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+				body:    bomb,
+			},
+			want: &Person{Name: bombName},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := tc.r.build()
+			if err != nil {
+				t.Fatalf("http.NewRequest: %s", err)
+			}
+
+			dec := httputil.DecompressingDecoderMiddleware(tc.opts)(httputil.JSONDecoder{})
+
+			var p Person
+			err = dec.Decode(r, &p)
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("Decoder.Decode() error diff: %s", errorDiff(tc.wantErr, err))
+				return
+			}
+
+			if err == nil && !reflect.DeepEqual(tc.want, &p) {
+				t.Errorf("\nDecoder.Decode()=%#v \nwant %#v", &p, tc.want)
+			}
+
+			if got := r.Header.Get("Content-Encoding"); got != tc.r.headers["Content-Encoding"] {
+				t.Errorf("Request was modified, Content-Encoding=%q; want %q", got, tc.r.headers["Content-Encoding"])
+			}
+		})
+	}
+}
+
+func gzipStr(t *testing.T, s string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatalf("gzip.Writer.Write: %s", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip.Writer.Close: %s", err)
+	}
+	return buf.String()
+}
+
+func zlibStr(t *testing.T, s string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatalf("zlib.Writer.Write: %s", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zlib.Writer.Close: %s", err)
+	}
+	return buf.String()
+}
+
+func rot13(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+13)%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+13)%26
+		}
+		return r
+	}, s)
+}
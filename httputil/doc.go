@@ -37,6 +37,44 @@
 //		dec = httputil.ValidatingDecoderMiddleware(vd)(dec)
 //	}
 //
+// Compressed request bodies can be decoded transparently using
+// DecompressingDecoderMiddleware. MaxBytes limits the size of the
+// decompressed body, DefaultDecompressMaxBytes by default:
+//
+//	dec = httputil.DecompressingDecoderMiddleware(httputil.DecompressOptions{
+//		MaxBytes: 1 << 20, // 1 MiB
+//	})(dec)
+//
+// MergePatchDecoder (RFC 7396) and JSONPatchDecoder (RFC 6902) apply the
+// patch in the request body to an existing value:
+//
+//	u, err := svc.User(ctx, id)
+//	// ...
+//	var dec httputil.MergePatchDecoder
+//	if err := dec.Decode(r, &u); err != nil {
+//		responder.Error(r, w, err)
+//		return
+//	}
+//
+// MultipartDecoder decodes multipart/form-data request bodies. Files are
+// streamed to the destination value, which must implement
+// MultipartFileHandler, subject to the size limits and the allowed
+// content types:
+//
+//	dec := httputil.MultipartDecoder{
+//		MaxFileBytes:        10 << 20, // 10 MiB
+//		AllowedContentTypes: []string{"image/*"},
+//	}
+//
+// QueryDecoder decodes the query parameters into a struct using the
+// "query" struct tags. EncodeQuery and URLBuilder.QueryStruct do the
+// inverse so that the client and the server can share the request type:
+//
+//	type SearchRequest struct {
+//		Query string   `query:"q"`
+//		Tags  []string `query:"tags,comma,omitempty"`
+//	}
+//
 // # Encoding responses
 //
 // JSONResponder is a simple helper for responding to requests with JSON
@@ -58,6 +96,44 @@
 //		Data:    id,
 //	})
 //
+// Setting Buffered on the JSONResponder encodes the response body into a
+// buffer before writing the status. This makes it possible to set the
+// Content-Length header and to respond with an errors.Internal error if
+// encoding fails.
+//
+// JSONResponder.Stream writes the values produced by an iterator
+// function as NDJSON or as a JSON array incrementally. StreamChan and
+// StreamSeq adapt a channel and an iter.Seq respectively:
+//
+//	httputil.StreamChan(&responder, r, w, httputil.StreamOptions{Format: httputil.JSONArray}, orders)
+//
+// SSEResponder streams events, with the data encoded as JSON, using
+// Server-Sent Events:
+//
+//	sse := httputil.SSEResponder{Responder: &responder, HeartbeatInterval: 15 * time.Second}
+//	sse.Respond(r, w, func(s *httputil.SSEStream) error {
+//		return s.Send(httputil.SSEEvent{ID: "1", Event: "progress", Data: p})
+//	})
+//
+// Conditional requests are supported using the ETag and Last-Modified
+// headers. Setting ETag on the JSONResponder generates the entity tag
+// from the encoded response body. Alternatively, the value can
+// implement ETagger and LastModifier. JSONResponder.CheckPreconditions
+// evaluates If-Match and If-Unmodified-Since before updating a
+// resource:
+//
+//	if err := responder.CheckPreconditions(r, current); err != nil {
+//		responder.Error(r, w, err) // 412: Precondition Failed
+//		return
+//	}
+//
+// CompressingMiddleware compresses the response body using gzip or
+// deflate as per the Accept-Encoding header. Small responses and
+// content types which are already compressed are sent uncompressed.
+// Streaming responses are still flushed to the client:
+//
+//	http.Handle("/", httputil.CompressingMiddleware(httputil.CompressOptions{})(mux))
+//
 // JSONResponder builds upon the interfaces declared in the
 // github.com/sudo-suhas/xgo/errors package to translate the error value
 // into the status and response body suitable to be sent to the caller.
@@ -114,6 +190,55 @@
 //		httplog.LogEntrySetField(r, "error_details", e.Details())
 //	}
 //
+// JSONResponder.Recoverer recovers from panics in the wrapped handler,
+// notifies the ErrObservers with an errors.Internal error carrying the
+// PanicData and writes the error response if possible:
+//
+//	http.Handle("/", responder.Recoverer(mux))
+//
+// # JSON codec
+//
+// JSONDecoder and JSONResponder use encoding/json by default. An
+// alternative implementation of JSONCodec can be specified as the Codec
+// on either. Options such as disabling HTML escaping and indentation are
+// set on the codec:
+//
+//	codec := httputil.StdJSONCodec{DisableHTMLEscape: true}
+//	responder := httputil.JSONResponder{Codec: codec}
+//
+// # Typed handlers
+//
+// Decode decodes the request into a new value of the given type and
+// Handle adapts a plain typed function into an http.Handler which
+// decodes the request, calls the function and responds with the result
+// or the error:
+//
+//	h := httputil.Handle(dec, &responder,
+//		func(ctx context.Context, u myapp.User) (myapp.Response, error) {
+//			id, err := svc.Create(ctx, u)
+//			if err != nil {
+//				return myapp.Response{}, err
+//			}
+//
+//			return myapp.Response{Success: true, Data: id}, nil
+//		},
+//		httputil.WithStatus(http.StatusCreated),
+//	)
+//
+// HandlerFunc is an HTTP handler which returns the error instead of
+// writing the error response itself. JSONResponder.Handler adapts it
+// into an http.Handler which writes the error using JSONResponder.Error:
+//
+//	http.Handle("/users", responder.Handler(func(w http.ResponseWriter, r *http.Request) error {
+//		users, err := svc.Users(r.Context())
+//		if err != nil {
+//			return err
+//		}
+//
+//		responder.Respond(r, w, users)
+//		return nil
+//	}))
+//
 // # Building URLs
 //
 // URLBuilder makes building URLs convenient and prevents common
@@ -150,4 +275,103 @@
 //		}).
 //		URL()
 //	fmt.Println(u) // https://api.example.com/users/foo/posts/bar/comments?limit=10&search=some+text
+//
+// URL does not modify the URLBuilder and can be called any number of
+// times. URLBuilder.Clone can be used to derive variants from a
+// partially configured URLBuilder.
+//
+// URLBuilder.Build fails with an error of kind errors.InvalidInput if a
+// placeholder in the path template is unresolved, a path parameter is
+// unused or the path template is malformed. Setting Strict on the
+// URLBuilderSource makes URLBuilder.Build also reject path parameters
+// with an empty value.
+//
+// URITemplate implements RFC 6570 URI Templates up to level 4. It can be
+// expanded on its own or used in place of the path template using
+// URLBuilder.URITemplate:
+//
+//	issues := httputil.MustParseURITemplate("/repos/{owner}/{repo}/issues{?labels*,state}")
+//	u = b.NewURLBuilder().
+//		URITemplate(issues).
+//		PathParam("owner", "golang").
+//		PathParam("repo", "go").
+//		TemplateVar("labels", []string{"NeedsFix", "release-blocker"}).
+//		URL()
+//	fmt.Println(u) // https://api.example.com/repos/golang/go/issues?labels=NeedsFix&labels=release-blocker
+//
+// URLBuilder.QueryStruct sets the query parameters encoded from a struct
+// using EncodeQuery:
+//
+//	u = b.NewURLBuilder().
+//		Path("/search").
+//		QueryStruct(SearchRequest{Query: "shoes", Tags: []string{"red", "blue"}}).
+//		URL()
+//	fmt.Println(u) // https://api.example.com/search?q=shoes&tags=red%2Cblue
+//
+// URLBuilder.Sign signs the URL using HMAC-SHA256 with an expiry and
+// URLVerifier verifies it, with support for key rotation:
+//
+//	u, err := b.NewURLBuilder().
+//		Path("/downloads/{id}").
+//		PathParam("id", id).
+//		Sign(key, time.Now().Add(15*time.Minute))
+//
+//	v := httputil.URLVerifier{Keys: []httputil.URLSigningKey{newKey, oldKey}}
+//	mux.Handle("/downloads/", v.Middleware(downloadHandler))
+//
+// RequestBuilder builds upon URLBuilder to build the complete
+// http.Request with the method, headers and the body:
+//
+//	req, err := b.NewRequestBuilder().
+//		Method(http.MethodPost).
+//		Path("/users/{id}/posts").
+//		PathParam("id", id).
+//		JSON(post).
+//		Context(ctx).
+//		Build()
+//
+// # JSON client
+//
+// Client pairs URLBuilderSource with http.Client for calling JSON APIs.
+// Do decodes the response into a new value of the given type. A non-2xx
+// response is converted into an error using errors.WithResp:
+//
+//	c, err := httputil.NewClient("https://api.example.com/")
+//	// ...
+//	u := c.NewURLBuilder().Path("/users/{id}").PathParam("id", id).URL()
+//	req, err := c.NewRequest(ctx, http.MethodGet, u, nil)
+//	// ...
+//	user, err := httputil.Do[myapp.User](ctx, c, "UserService.User", req)
+//
+// RetryingTransport retries requests which fail with a transient
+// connection error or a status whose Kind is retryable, using
+// exponential backoff with jitter and honouring Retry-After. Only
+// idempotent requests are retried and a RetryBudget limits the retries
+// across requests:
+//
+//	c.HTTPClient = &http.Client{
+//		Transport: httputil.RetryingTransport(httputil.RetryOptions{})(http.DefaultTransport),
+//	}
+//
+// RateLimitingTransport limits the rate, using a token bucket, and the
+// number of requests in flight for each host. A request which cannot be
+// sent before its context deadline fails with errors.ResourceExhausted.
+// In adaptive mode, the requests to a host are paused as asked by the
+// Retry-After and X-RateLimit-* headers:
+//
+//	c.HTTPClient = &http.Client{
+//		Transport: httputil.RateLimitingTransport(httputil.RateLimitOptions{
+//			Default:  httputil.RateLimit{Rate: 10, Burst: 5, MaxInFlight: 4},
+//			Adaptive: true,
+//		})(http.DefaultTransport),
+//	}
+//
+// CachingTransport caches the responses to GET requests as per RFC 9111,
+// with support for revalidation, Vary, stale-while-revalidate and
+// stale-if-error. The responses are stored in a CacheStorage, an
+// in-memory LRUCacheStorage by default:
+//
+//	c.HTTPClient = &http.Client{
+//		Transport: httputil.CachingTransport(httputil.CacheOptions{})(http.DefaultTransport),
+//	}
 package httputil
@@ -0,0 +1,249 @@
+package httputil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// ETagMode controls the generation of the ETag header from the encoded
+// response body.
+type ETagMode int
+
+// ETag modes.
+const (
+	// NoETag disables the generation of the ETag header from the
+	// response body.
+	NoETag ETagMode = iota
+
+	// StrongETag generates a strong ETag from the response body.
+	StrongETag
+
+	// WeakETag generates a weak ETag from the response body.
+	WeakETag
+)
+
+// ETagger is implemented by any value which provides its own entity
+// tag, for example from a version number. ETag returns the entity tag,
+// optionally quoted and prefixed with "W/" for a weak entity tag. An
+// unquoted value is quoted before setting it in the ETag header.
+type ETagger interface {
+	ETag() string
+}
+
+// LastModifier is implemented by any value which can report the time
+// at which it was last modified.
+type LastModifier interface {
+	LastModified() time.Time
+}
+
+// CheckPreconditions evaluates the If-Match, If-Unmodified-Since and,
+// for requests other than GET and HEAD, the If-None-Match headers of the
+// request against v, the current state of the resource. It returns an
+// error of kind errors.FailedPrecondition if the preconditions fail.
+// This can be used for optimistic concurrency control before updating
+// the resource.
+//
+// The entity tag of v is determined using the ETagger interface or, if
+// ETag is not NoETag, from the JSON encoding of v. The modification
+// time is determined using the LastModifier interface.
+//
+//	u, err := svc.User(ctx, id)
+//	// ...
+//	if err := responder.CheckPreconditions(r, u); err != nil {
+//		responder.Error(r, w, err)
+//		return
+//	}
+func (jr *JSONResponder) CheckPreconditions(r *http.Request, v interface{}) error {
+	const op = "JSONResponder.CheckPreconditions"
+
+	h := make(http.Header)
+	setValidators(h, v)
+	if h.Get("ETag") == "" && jr.ETag != NoETag {
+		buf := getBuffer()
+		defer putBuffer(buf)
+
+		if err := jsonCodecOrDefault(jr.Codec).NewEncoder(buf).Encode(jsonBody(v)); err != nil {
+			return errors.E(errors.WithOp(op), errors.Internal, errors.WithText("encode value"), errors.WithErr(err))
+		}
+		h.Set("ETag", bodyETag(buf.Bytes(), jr.ETag == WeakETag))
+	}
+
+	if evalConditions(r, h) == http.StatusPreconditionFailed {
+		return errors.E(errors.WithOp(op), errors.FailedPrecondition, errors.WithUserMsg(preconditionFailedMsg))
+	}
+	return nil
+}
+
+const preconditionFailedMsg = "The resource has been modified since it was last fetched"
+
+// setValidators sets the ETag and Last-Modified headers using the
+// ETagger and LastModifier interfaces implemented by v.
+func setValidators(h http.Header, v interface{}) {
+	if e, ok := v.(ETagger); ok {
+		if etag := e.ETag(); etag != "" {
+			h.Set("ETag", quoteETag(etag))
+		}
+	}
+
+	if lm, ok := v.(LastModifier); ok {
+		if t := lm.LastModified(); !t.IsZero() {
+			h.Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+		}
+	}
+}
+
+func hasValidators(h http.Header) bool {
+	return h.Get("ETag") != "" || h.Get("Last-Modified") != ""
+}
+
+// respondConditional generates the ETag, if enabled, and evaluates the
+// conditional request headers for the response. It reports whether the
+// response has been written, with either '304: Not Modified' or the
+// error response for '412: Precondition Failed'.
+func (jr *JSONResponder) respondConditional(r *http.Request, w http.ResponseWriter, status int, b []byte) bool {
+	const op = "JSONResponder.RespondWithStatus"
+
+	if status < 200 || status > 299 {
+		return false
+	}
+
+	h := w.Header()
+	if jr.ETag != NoETag && h.Get("ETag") == "" {
+		h.Set("ETag", bodyETag(b, jr.ETag == WeakETag))
+	}
+
+	// The preconditions for unsafe methods must be checked before
+	// making the change, for example using CheckPreconditions.
+	if status != http.StatusOK || r == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+
+	switch evalConditions(r, h) {
+	case http.StatusNotModified:
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return true
+
+	case http.StatusPreconditionFailed:
+		jr.Error(r, w, errors.E(errors.WithOp(op), errors.FailedPrecondition, errors.WithUserMsg(preconditionFailedMsg)))
+		return true
+	}
+
+	return false
+}
+
+// evalConditions evaluates the conditional request headers against the
+// ETag and Last-Modified response headers as per RFC 9110 section
+// 13.2.2. It returns the status to respond with or zero if the response
+// should be sent as is.
+func evalConditions(r *http.Request, h http.Header) int {
+	etag := h.Get("ETag")
+	lastModified, _ := http.ParseTime(h.Get("Last-Modified"))
+	isGetOrHead := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !etagListMatch(im, etag, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.After(ius) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagListMatch(inm, etag, false) {
+			return 0
+		}
+		if isGetOrHead {
+			return http.StatusNotModified
+		}
+		return http.StatusPreconditionFailed
+	}
+
+	if !isGetOrHead || lastModified.IsZero() {
+		return 0
+	}
+	if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.After(ims) {
+		return http.StatusNotModified
+	}
+
+	return 0
+}
+
+// etagListMatch reports whether the list of entity tags in the header
+// value matches the etag. The wildcard "*" matches any current entity.
+func etagListMatch(list, etag string, strong bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return etag != ""
+	}
+	if etag == "" {
+		return false
+	}
+
+	for list = strings.TrimSpace(list); list != ""; {
+		var candidate string
+		candidate, list = scanETag(list)
+		if candidate == "" {
+			return false
+		}
+		if etagMatch(candidate, etag, strong) {
+			return true
+		}
+		list = strings.TrimLeft(list, " \t,")
+	}
+	return false
+}
+
+// scanETag returns the entity tag at the start of s and the remainder.
+// It returns an empty entity tag if s does not start with a valid
+// entity tag.
+func scanETag(s string) (etag, rest string) {
+	start := 0
+	if strings.HasPrefix(s, "W/") {
+		start = 2
+	}
+	if len(s) <= start || s[start] != '"' {
+		return "", ""
+	}
+
+	end := strings.IndexByte(s[start+1:], '"')
+	if end == -1 {
+		return "", ""
+	}
+	end += start + 2
+	return s[:end], s[end:]
+}
+
+// etagMatch compares the entity tags using the strong or weak
+// comparison as per RFC 9110 section 8.8.3.2.
+func etagMatch(a, b string, strong bool) bool {
+	if strong {
+		return !strings.HasPrefix(a, "W/") && !strings.HasPrefix(b, "W/") && a == b
+	}
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// quoteETag quotes the entity tag if required.
+func quoteETag(etag string) string {
+	if strings.HasSuffix(etag, `"`) && (strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`)) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// bodyETag returns the entity tag for the response body.
+func bodyETag(b []byte, weak bool) string {
+	sum := sha256.Sum256(b)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if weak {
+		etag = "W/" + etag
+	}
+	return etag
+}
@@ -0,0 +1,225 @@
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+type versionedPerson struct {
+	Name    string    `json:"name"`
+	Version int       `json:"-"`
+	Updated time.Time `json:"-"`
+}
+
+func (p versionedPerson) ETag() string { return "v" + strconv.Itoa(p.Version) }
+
+func (p versionedPerson) LastModified() time.Time { return p.Updated }
+
+func TestJSONResponderConditional(t *testing.T) {
+	updated := time.Date(2023, time.March, 14, 10, 30, 0, 0, time.UTC)
+	person := versionedPerson{Name: "Donald", Version: 2, Updated: updated}
+	bodyETag := `"7e74d0a302e554d1e3c2cb6a618056d9"`
+	failedBody := []byte(`{"success":false,"msg":"The resource has been modified since it was last fetched","errors":[{"code":"FAILED_PRECONDITION","error":"failed precondition","msg":"The resource has been modified since it was last fetched"}]}`)
+
+	cases := []struct {
+		name    string
+		jr      httputil.JSONResponder
+		method  string
+		headers map[string]string
+		v       interface{}
+		want    response
+	}{
+		{
+			name: "NoETag",
+			v:    Person{Name: "Donald", Age: 33},
+			want: response{
+				status:  http.StatusOK,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    []byte(`{"Name":"Donald","Age":33,"V":null}`),
+			},
+		},
+		{
+			name: "StrongETag",
+			jr:   httputil.JSONResponder{ETag: httputil.StrongETag},
+			v:    Person{Name: "Donald", Age: 33},
+			want: response{
+				status: http.StatusOK,
+				headers: map[string]string{
+					"Content-Type":   "application/json; charset=utf-8",
+					"Content-Length": "36",
+					"Etag":           bodyETag,
+				},
+				body: []byte(`{"Name":"Donald","Age":33,"V":null}`),
+			},
+		},
+		{
+			name:    "WeakETagNotModified",
+			jr:      httputil.JSONResponder{ETag: httputil.WeakETag},
+			headers: map[string]string{"If-None-Match": `"abc", W/` + bodyETag},
+			v:       Person{Name: "Donald", Age: 33},
+			want: response{
+				status:  http.StatusNotModified,
+				headers: map[string]string{"Etag": "W/" + bodyETag},
+			},
+		},
+		{
+			name:    "ETaggerNotModified",
+			headers: map[string]string{"If-None-Match": `W/"v2"`},
+			v:       person,
+			want: response{
+				status: http.StatusNotModified,
+				headers: map[string]string{
+					"Etag":          `"v2"`,
+					"Last-Modified": "Tue, 14 Mar 2023 10:30:00 GMT",
+				},
+			},
+		},
+		{
+			name:    "ETaggerModified",
+			headers: map[string]string{"If-None-Match": `"v1"`, "If-Modified-Since": "Tue, 14 Mar 2023 10:30:00 GMT"},
+			v:       person,
+			want: response{
+				status: http.StatusOK,
+				headers: map[string]string{
+					"Content-Type":   "application/json; charset=utf-8",
+					"Content-Length": "18",
+					"Etag":           `"v2"`,
+					"Last-Modified":  "Tue, 14 Mar 2023 10:30:00 GMT",
+				},
+				body: []byte(`{"name":"Donald"}`),
+			},
+		},
+		{
+			name:    "IfModifiedSince",
+			headers: map[string]string{"If-Modified-Since": "Tue, 14 Mar 2023 10:30:00 GMT"},
+			v:       person,
+			want: response{
+				status: http.StatusNotModified,
+				headers: map[string]string{
+					"Etag":          `"v2"`,
+					"Last-Modified": "Tue, 14 Mar 2023 10:30:00 GMT",
+				},
+			},
+		},
+		{
+			name:    "IfMatchFailed",
+			headers: map[string]string{"If-Match": `"v1"`},
+			v:       person,
+			want: response{
+				status: http.StatusPreconditionFailed,
+				headers: map[string]string{
+					"Content-Type":   "application/json; charset=utf-8",
+					"Content-Length": "220",
+					"Etag":           `"v2"`,
+					"Last-Modified":  "Tue, 14 Mar 2023 10:30:00 GMT",
+				},
+				body: failedBody,
+			},
+		},
+		{
+			name:    "NotModifiedIgnoredForPost",
+			method:  http.MethodPost,
+			headers: map[string]string{"If-None-Match": `"v2"`},
+			v:       person,
+			want: response{
+				status: http.StatusOK,
+				headers: map[string]string{
+					"Content-Type":   "application/json; charset=utf-8",
+					"Content-Length": "18",
+					"Etag":           `"v2"`,
+					"Last-Modified":  "Tue, 14 Mar 2023 10:30:00 GMT",
+				},
+				body: []byte(`{"name":"Donald"}`),
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "http://host.com/users/1", nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			tc.jr.Respond(r, rec, tc.v)
+
+			matchResponse(t, rec.Result(), tc.want)
+		})
+	}
+}
+
+func TestJSONResponderCheckPreconditions(t *testing.T) {
+	updated := time.Date(2023, time.March, 14, 10, 30, 0, 0, time.UTC)
+	person := versionedPerson{Name: "Donald", Version: 2, Updated: updated}
+	failed := errors.E(
+		errors.WithOp("JSONResponder.CheckPreconditions"),
+		errors.FailedPrecondition,
+		errors.WithUserMsg("The resource has been modified since it was last fetched"),
+	)
+
+	cases := []struct {
+		name    string
+		jr      httputil.JSONResponder
+		headers map[string]string
+		v       interface{}
+		wantErr error
+	}{
+		{name: "NoConditions", v: person},
+		{name: "IfMatch", headers: map[string]string{"If-Match": `"v1", "v2"`}, v: person},
+		{name: "IfMatchWildcard", headers: map[string]string{"If-Match": "*"}, v: person},
+		{name: "IfMatchFailed", headers: map[string]string{"If-Match": `"v1"`}, v: person, wantErr: failed},
+		{name: "IfMatchWeakFailed", headers: map[string]string{"If-Match": `W/"v2"`}, v: person, wantErr: failed},
+		{
+			name:    "IfMatchBodyETag",
+			jr:      httputil.JSONResponder{ETag: httputil.StrongETag},
+			headers: map[string]string{"If-Match": `"7e74d0a302e554d1e3c2cb6a618056d9"`},
+			v:       Person{Name: "Donald", Age: 33},
+		},
+		{
+			name:    "IfMatchWithoutETag",
+			headers: map[string]string{"If-Match": "*"},
+			v:       Person{Name: "Donald", Age: 33},
+			wantErr: failed,
+		},
+		{
+			name:    "IfUnmodifiedSince",
+			headers: map[string]string{"If-Unmodified-Since": "Tue, 14 Mar 2023 10:30:00 GMT"},
+			v:       person,
+		},
+		{
+			name:    "IfUnmodifiedSinceFailed",
+			headers: map[string]string{"If-Unmodified-Since": "Tue, 14 Mar 2023 10:29:59 GMT"},
+			v:       person,
+			wantErr: failed,
+		},
+		{
+			name:    "IfNoneMatchWildcard",
+			headers: map[string]string{"If-None-Match": "*"},
+			v:       person,
+			wantErr: failed,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "http://host.com/users/1", nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+
+			err := tc.jr.CheckPreconditions(r, tc.v)
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("CheckPreconditions() error diff: %s", errorDiff(tc.wantErr, err))
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package httputil
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sudo-suhas/xgo"
+)
+
+// Decode decodes the HTTP request into a new value of type T using the
+// given Decoder. The zero value of T is returned if decoding fails.
+//
+//	u, err := httputil.Decode[myapp.User](dec, r)
+//	if err != nil {
+//		responder.Error(r, w, err)
+//		return
+//	}
+func Decode[T any](dec Decoder, r *http.Request) (T, error) {
+	var v T
+	if err := dec.Decode(r, &v); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return v, nil
+}
+
+// HandleOption is a type of option for Handle.
+type HandleOption func(*handleOptions)
+
+type handleOptions struct {
+	status int
+	vd     xgo.Validator
+}
+
+// WithStatus sets the status code for the response when the handler
+// function succeeds. Defaults to '200: OK'. If the status is
+// '204: No Content', the response value is discarded and only the
+// status is written.
+func WithStatus(status int) HandleOption {
+	return func(o *handleOptions) {
+		o.status = status
+	}
+}
+
+// WithValidator sets the xgo.Validator used to validate the decoded
+// request value before the handler function is called.
+func WithValidator(vd xgo.Validator) HandleOption {
+	return func(o *handleOptions) {
+		o.vd = vd
+	}
+}
+
+// Handle returns an http.Handler which decodes the request into a value
+// of type Req, calls fn with it and responds with the returned value of
+// type Resp. Errors from decoding the request or from fn are written
+// using JSONResponder.Error.
+//
+//	h := httputil.Handle(dec, &responder,
+//		func(ctx context.Context, u myapp.User) (myapp.Response, error) {
+//			id, err := svc.Create(ctx, u)
+//			if err != nil {
+//				return myapp.Response{}, err
+//			}
+//
+//			return myapp.Response{Success: true, Data: id}, nil
+//		},
+//		httputil.WithStatus(http.StatusCreated),
+//	)
+//
+// If res is nil, a JSONResponder with the default configuration is
+// used.
+func Handle[Req, Resp any](
+	dec Decoder, res *JSONResponder, fn func(ctx context.Context, req Req) (Resp, error), opts ...HandleOption,
+) http.Handler {
+	o := handleOptions{status: http.StatusOK}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.vd != nil {
+		dec = ValidatingDecoderMiddleware(o.vd)(dec)
+	}
+	if res == nil {
+		res = &JSONResponder{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := Decode[Req](dec, r)
+		if err != nil {
+			res.Error(r, w, err)
+			return
+		}
+
+		resp, err := fn(r.Context(), req)
+		if err != nil {
+			res.Error(r, w, err)
+			return
+		}
+
+		if o.status == http.StatusNoContent {
+			res.RespondWithStatus(r, w, o.status, nil)
+			return
+		}
+
+		res.RespondWithStatus(r, w, o.status, resp)
+	})
+}
@@ -0,0 +1,145 @@
+package httputil_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/sudo-suhas/xgo"
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestDecode(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		r := newJSONRequest(t, `{ "name": "Donald", "age": 33 }`)
+
+		got, err := httputil.Decode[Person](httputil.JSONDecoder{}, r)
+		if err != nil {
+			t.Fatalf("Decode() error: %s", err)
+		}
+
+		want := Person{Name: "Donald", Age: 33}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Decode()=%#v; want %#v", got, want)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		r := newJSONRequest(t, `{ "name": "Donald", "age": }`)
+
+		got, err := httputil.Decode[Person](httputil.JSONDecoder{}, r)
+		want := errors.E(errors.WithOp("JSONDecoder.Decode"), errors.InvalidInput)
+		if !errors.Match(want, err) {
+			t.Errorf("Decode() error diff: %s", errorDiff(want, err))
+		}
+
+		if !reflect.DeepEqual(got, Person{}) {
+			t.Errorf("Decode()=%#v; want zero value", got)
+		}
+	})
+}
+
+func TestHandle(t *testing.T) {
+	greet := func(_ context.Context, p Person) (map[string]string, error) {
+		if p.Name == "Kramer" {
+			return nil, errors.E(errors.PermissionDenied, errors.WithUserMsg("No soup for you!"))
+		}
+
+		return map[string]string{"greeting": "Hello, " + p.Name}, nil
+	}
+	nameRequired := xgo.ValidatorFunc(func(v interface{}) error {
+		if v.(*Person).Name == "" {
+			return errors.E(errors.InvalidInput, errors.WithUserMsg("Name is required"))
+		}
+		return nil
+	})
+
+	cases := []struct {
+		name string
+		opts []httputil.HandleOption
+		body string
+		want response
+	}{
+		{
+			name: "Success",
+			body: `{ "name": "Donald" }`,
+			want: response{
+				status:  http.StatusOK,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    []byte(`{"greeting": "Hello, Donald"}`),
+			},
+		},
+		{
+			name: "WithStatus",
+			opts: []httputil.HandleOption{httputil.WithStatus(http.StatusCreated)},
+			body: `{ "name": "Donald" }`,
+			want: response{
+				status:  http.StatusCreated,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    []byte(`{"greeting": "Hello, Donald"}`),
+			},
+		},
+		{
+			name: "WithStatusNoContent",
+			opts: []httputil.HandleOption{httputil.WithStatus(http.StatusNoContent)},
+			body: `{ "name": "Donald" }`,
+			want: response{status: http.StatusNoContent},
+		},
+		{
+			name: "DecodeError",
+			body: `{ "name": }`,
+			want: response{
+				status:  http.StatusBadRequest,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    []byte(`{"success":false,"msg":"Request body contains badly-formed JSON (at position 11)","errors":[{"code":"INVALID_INPUT","error":"invalid input","msg":"Request body contains badly-formed JSON (at position 11)"}]}`),
+			},
+		},
+		{
+			name: "ValidationError",
+			opts: []httputil.HandleOption{httputil.WithValidator(nameRequired)},
+			body: `{ "age": 33 }`,
+			want: response{
+				status:  http.StatusBadRequest,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    []byte(`{"success":false,"msg":"Name is required","errors":[{"code":"INVALID_INPUT","error":"invalid input","msg":"Name is required"}]}`),
+			},
+		},
+		{
+			name: "HandlerError",
+			body: `{ "name": "Kramer" }`,
+			want: response{
+				status:  http.StatusForbidden,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    []byte(`{"success":false,"msg":"No soup for you!","errors":[{"code":"PERMISSION_DENIED","error":"permission denied","msg":"No soup for you!"}]}`),
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := httputil.Handle(httputil.JSONDecoder{}, nil, greet, tc.opts...)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, newJSONRequest(t, tc.body))
+
+			matchResponse(t, rec.Result(), tc.want)
+		})
+	}
+}
+
+func newJSONRequest(t *testing.T, body string) *http.Request {
+	t.Helper()
+
+	r, err := request{
+		method:  http.MethodPost,
+		url:     "http://host.com/route",
+		headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+		body:    body,
+	}.build()
+	if err != nil {
+		t.Fatalf("http.NewRequest: %s", err)
+	}
+	return r
+}
@@ -0,0 +1,153 @@
+package httputil
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// HandlerFunc is an HTTP handler which returns an error instead of
+// writing the error response itself. JSONResponder.Handler adapts a
+// HandlerFunc into an http.Handler.
+//
+//	func UserHandler(svc myapp.UserService) httputil.HandlerFunc {
+//		var responder httputil.JSONResponder
+//		return func(w http.ResponseWriter, r *http.Request) error {
+//			user, err := svc.User(r.Context(), chi.URLParam(r, "userID"))
+//			if err != nil {
+//				return err
+//			}
+//
+//			responder.Respond(r, w, myapp.Response{Success: true, Data: user})
+//			return nil
+//		}
+//	}
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Handler returns an http.Handler which calls h and writes the error
+// returned by it, if any, using JSONResponder.Error.
+//
+// If h has already written the response when it returns the error, the
+// error response cannot be written. In this case, the ErrObservers are
+// notified of the error instead.
+//
+//	http.Handle("/users/{userID}", responder.Handler(UserHandler(svc)))
+func (jr *JSONResponder) Handler(h HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const op = "JSONResponder.Handler"
+
+		tw, rw := newTrackingWriter(w)
+		err := h(rw, r)
+		if err == nil {
+			return
+		}
+
+		if tw.wroteHeader {
+			jr.observeError(r, errors.E(
+				errors.WithOp(op),
+				errors.WithText("response already written, error not sent"),
+				errors.WithErr(err),
+			))
+			return
+		}
+
+		jr.Error(r, w, err)
+	})
+}
+
+// trackingWriter tracks whether the response header has been written.
+type trackingWriter struct {
+	http.ResponseWriter
+
+	wroteHeader bool
+}
+
+// newTrackingWriter wraps w in a trackingWriter. The trackingWriter is
+// returned along with the ResponseWriter to be passed to the handler.
+// The latter implements http.Flusher and http.Hijacker only if w does
+// so that the handler can detect the features supported by w.
+func newTrackingWriter(w http.ResponseWriter) (*trackingWriter, http.ResponseWriter) {
+	tw := &trackingWriter{ResponseWriter: w}
+
+	flusher, hijacker := flusherOf(w) != nil, hijackerOf(w) != nil
+	switch {
+	case flusher && hijacker:
+		return tw, flushHijackTrackingWriter{tw}
+
+	case flusher:
+		return tw, flushTrackingWriter{tw}
+
+	case hijacker:
+		return tw, hijackTrackingWriter{tw}
+	}
+	return tw, tw
+}
+
+func (t *trackingWriter) WriteHeader(status int) {
+	// Informational (1xx) headers can be written more than once.
+	if !t.wroteHeader && (status < 100 || status > 199 || status == http.StatusSwitchingProtocols) {
+		t.wroteHeader = true
+	}
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *trackingWriter) Write(b []byte) (int, error) {
+	t.wroteHeader = true
+	return t.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter. It is used by
+// http.ResponseController.
+func (t *trackingWriter) Unwrap() http.ResponseWriter { return t.ResponseWriter }
+
+func (t *trackingWriter) flush() {
+	t.wroteHeader = true
+	flusherOf(t.ResponseWriter).Flush()
+}
+
+func (t *trackingWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := hijackerOf(t.ResponseWriter).Hijack()
+	if err == nil {
+		// The connection is owned by the handler now. The error response
+		// cannot be written.
+		t.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+type flushTrackingWriter struct{ *trackingWriter }
+
+// Flush implements http.Flusher.
+func (t flushTrackingWriter) Flush() { t.flush() }
+
+type hijackTrackingWriter struct{ *trackingWriter }
+
+// Hijack implements http.Hijacker.
+func (t hijackTrackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return t.hijack() }
+
+type flushHijackTrackingWriter struct{ *trackingWriter }
+
+// Flush implements http.Flusher.
+func (t flushHijackTrackingWriter) Flush() { t.flush() }
+
+// Hijack implements http.Hijacker.
+func (t flushHijackTrackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return t.hijack() }
+
+// hijackerOf returns the http.Hijacker for the ResponseWriter, unwrapping
+// it if required. It returns nil if hijacking is not supported.
+func hijackerOf(w http.ResponseWriter) http.Hijacker {
+	for {
+		switch t := w.(type) {
+		case http.Hijacker:
+			return t
+
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package httputil_test
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestJSONResponderHandler(t *testing.T) {
+	cases := []struct {
+		name        string
+		h           httputil.HandlerFunc
+		want        response
+		wantObserve error
+	}{
+		{
+			name: "Success",
+			h: func(w http.ResponseWriter, r *http.Request) error {
+				var jr httputil.JSONResponder
+				jr.Respond(r, w, Person{Name: "Donald", Age: 33})
+				return nil
+			},
+			want: response{
+				status:  http.StatusOK,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    []byte(`{"Name": "Donald", "Age": 33, "V": null}`),
+			},
+		},
+		{
+			name: "Error",
+			h: func(http.ResponseWriter, *http.Request) error {
+				return errors.E(errors.NotFound, errors.WithUserMsg("Who?"))
+			},
+			want: response{
+				status:  http.StatusNotFound,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    []byte(`{"success":false,"msg":"Who?","errors":[{"code":"NOT_FOUND","error":"not found","msg":"Who?"}]}`),
+			},
+			wantObserve: errors.E(errors.NotFound, errors.WithUserMsg("Who?")),
+		},
+		{
+			name: "ErrorAfterWriteHeader",
+			h: func(w http.ResponseWriter, _ *http.Request) error {
+				w.WriteHeader(http.StatusAccepted)
+				return errors.E(errors.WithOp("late"), errors.Internal)
+			},
+			want: response{status: http.StatusAccepted},
+			wantObserve: errors.E(
+				errors.WithOp("JSONResponder.Handler"),
+				errors.Internal,
+				errors.WithText("response already written, error not sent"),
+				errors.WithErr(errors.E(errors.WithOp("late"))),
+			),
+		},
+		{
+			name: "ErrorAfterWrite",
+			h: func(w http.ResponseWriter, _ *http.Request) error {
+				w.Write([]byte("{}")) //nolint:errcheck
+				return errors.E(errors.Internal)
+			},
+			want: response{
+				status:  http.StatusOK,
+				headers: map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+				body:    []byte(`{}`),
+			},
+			wantObserve: errors.E(
+				errors.WithOp("JSONResponder.Handler"),
+				errors.Internal,
+				errors.WithText("response already written, error not sent"),
+			),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var observed error
+			jr := httputil.JSONResponder{
+				ErrObservers: []httputil.ErrorObserverFunc{
+					func(_ *http.Request, err error) { observed = err },
+				},
+			}
+
+			rec := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			jr.Handler(tc.h).ServeHTTP(rec, r)
+
+			if !matchErrors(tc.wantObserve, observed) {
+				t.Errorf("ErrorObserver.err diff: %s", errorDiff(tc.wantObserve, observed))
+			}
+
+			matchResponse(t, rec.Result(), tc.want)
+		})
+	}
+}
+
+func TestJSONResponderHandlerInterfaces(t *testing.T) {
+	cases := []struct {
+		name                  string
+		w                     http.ResponseWriter
+		wantFlush, wantHijack bool
+	}{
+		{name: "Plain", w: plainWriter{httptest.NewRecorder()}},
+		{name: "Flusher", w: httptest.NewRecorder(), wantFlush: true},
+		{name: "Hijacker", w: plainHijacker{plainWriter{httptest.NewRecorder()}}, wantHijack: true},
+		{name: "FlusherHijacker", w: hijackRecorder{httptest.NewRecorder()}, wantFlush: true, wantHijack: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var flush, hijack bool
+			var jr httputil.JSONResponder
+			h := jr.Handler(func(w http.ResponseWriter, _ *http.Request) error {
+				_, flush = w.(http.Flusher)
+				_, hijack = w.(http.Hijacker)
+				return nil
+			})
+			h.ServeHTTP(tc.w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if flush != tc.wantFlush {
+				t.Errorf("ResponseWriter implements http.Flusher=%t; want %t", flush, tc.wantFlush)
+			}
+			if hijack != tc.wantHijack {
+				t.Errorf("ResponseWriter implements http.Hijacker=%t; want %t", hijack, tc.wantHijack)
+			}
+		})
+	}
+
+	t.Run("Hijack", func(t *testing.T) {
+		var observed error
+		jr := httputil.JSONResponder{
+			ErrObservers: []httputil.ErrorObserverFunc{
+				func(_ *http.Request, err error) { observed = err },
+			},
+		}
+
+		rec := httptest.NewRecorder()
+		h := jr.Handler(func(w http.ResponseWriter, _ *http.Request) error {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return err
+			}
+			conn.Close()
+			return errors.E(errors.WithOp("late"), errors.Internal)
+		})
+		h.ServeHTTP(hijackRecorder{rec}, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		want := errors.E(
+			errors.WithOp("JSONResponder.Handler"),
+			errors.Internal,
+			errors.WithText("response already written, error not sent"),
+		)
+		if !matchErrors(want, observed) {
+			t.Errorf("ErrorObserver.err diff: %s", errorDiff(want, observed))
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("Body=%s; want=<empty>", rec.Body)
+		}
+	})
+}
+
+// plainWriter hides the optional interfaces implemented by the
+// ResponseWriter.
+type plainWriter struct{ http.ResponseWriter }
+
+// plainHijacker is a ResponseWriter which only supports hijacking.
+type plainHijacker struct{ plainWriter }
+
+func (plainHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) { return hijackConn() }
+
+// hijackRecorder is a ResponseRecorder which supports hijacking.
+type hijackRecorder struct{ *httptest.ResponseRecorder }
+
+func (hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) { return hijackConn() }
+
+func hijackConn() (net.Conn, *bufio.ReadWriter, error) {
+	conn, peer := net.Pipe()
+	peer.Close()
+	return conn, bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)), nil
+}
@@ -0,0 +1,176 @@
+package httputil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// JSONCodec creates the streaming encoders and decoders used by
+// JSONDecoder and JSONResponder. It makes it possible to plug in an
+// alternative JSON implementation.
+type JSONCodec interface {
+	// NewEncoder returns an encoder which writes to w.
+	NewEncoder(w io.Writer) JSONStreamEncoder
+
+	// NewDecoder returns a decoder which reads from r.
+	NewDecoder(r io.Reader) JSONStreamDecoder
+}
+
+// JSONStreamEncoder writes JSON values to an output stream.
+type JSONStreamEncoder interface {
+	// Encode writes the JSON encoding of v to the stream.
+	Encode(v interface{}) error
+}
+
+// JSONStreamDecoder reads and decodes JSON values from an input stream.
+//
+// For JSONDecoder to classify the errors returned by Decode, the
+// decoder must return io.EOF if the input is empty,
+// io.ErrUnexpectedEOF if the input ends abruptly and a *JSONDecodeError
+// for malformed JSON, invalid values and unknown fields. Errors from
+// reading the input must be returned as is.
+type JSONStreamDecoder interface {
+	// Decode reads the next JSON value from its input and stores it in
+	// the value pointed to by v.
+	Decode(v interface{}) error
+
+	// UseNumber causes the decoder to unmarshal a number into an
+	// interface{} as a json.Number instead of as a float64.
+	UseNumber()
+
+	// DisallowUnknownFields causes the decoder to return an error when
+	// the destination is a struct and the input contains object keys
+	// which do not match any non-ignored, exported fields in the
+	// destination.
+	DisallowUnknownFields()
+}
+
+// JSONDecodeErrorKind is the class of the problem with the JSON input.
+type JSONDecodeErrorKind int
+
+// JSON decode error kinds.
+const (
+	// JSONErrSyntax indicates that the input is not valid JSON.
+	JSONErrSyntax JSONDecodeErrorKind = iota + 1
+
+	// JSONErrType indicates that a JSON value is not appropriate for
+	// the type of the destination.
+	JSONErrType
+
+	// JSONErrUnknownField indicates that an object key does not match
+	// any field in the destination.
+	JSONErrUnknownField
+)
+
+// JSONDecodeError is returned by a JSONStreamDecoder to describe the
+// problem with the JSON input.
+type JSONDecodeError struct {
+	Kind JSONDecodeErrorKind
+
+	// Offset is the number of bytes of input read before the error
+	// occurred. For JSONErrSyntax, it includes the offending byte. For
+	// JSONErrType, it includes the offending value. It is not used for
+	// JSONErrUnknownField.
+	Offset int64
+
+	// Field is the unknown object key for JSONErrUnknownField and the
+	// path of the offending value, if known, for JSONErrType.
+	Field string
+
+	// Err is the underlying error returned by the JSON implementation.
+	Err error
+}
+
+func (e *JSONDecodeError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+
+	switch e.Kind {
+	case JSONErrSyntax:
+		return fmt.Sprintf("json: syntax error at offset %d", e.Offset)
+
+	case JSONErrType:
+		return fmt.Sprintf("json: invalid value for field %q at offset %d", e.Field, e.Offset)
+
+	case JSONErrUnknownField:
+		return fmt.Sprintf("json: unknown field %q", e.Field)
+	}
+
+	return "json: decode error"
+}
+
+// Unwrap returns the underlying error.
+func (e *JSONDecodeError) Unwrap() error { return e.Err }
+
+// StdJSONCodec is a JSONCodec which uses the encoding/json package. It is
+// used by default if no JSONCodec is specified.
+type StdJSONCodec struct {
+	// DisableHTMLEscape disables the escaping of the characters <, > and
+	// & in JSON strings. See json.Encoder.SetEscapeHTML.
+	DisableHTMLEscape bool
+
+	// Prefix and Indent, if either is set, cause each encoded value to
+	// be indented. See json.Encoder.SetIndent.
+	Prefix, Indent string
+}
+
+// NewEncoder returns a *json.Encoder which writes to w.
+func (c StdJSONCodec) NewEncoder(w io.Writer) JSONStreamEncoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(!c.DisableHTMLEscape)
+	if c.Prefix != "" || c.Indent != "" {
+		enc.SetIndent(c.Prefix, c.Indent)
+	}
+	return enc
+}
+
+// NewDecoder returns a decoder, backed by *json.Decoder, which reads
+// from r.
+func (StdJSONCodec) NewDecoder(r io.Reader) JSONStreamDecoder {
+	return stdJSONDecoder{json.NewDecoder(r)}
+}
+
+// stdJSONDecoder translates the errors returned by json.Decoder.Decode
+// into *JSONDecodeError.
+type stdJSONDecoder struct {
+	*json.Decoder
+}
+
+func (d stdJSONDecoder) Decode(v interface{}) error {
+	err := d.Decoder.Decode(v)
+	if err == nil {
+		return nil
+	}
+
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &syntaxErr):
+		return &JSONDecodeError{Kind: JSONErrSyntax, Offset: syntaxErr.Offset, Err: err}
+
+	case errors.As(err, &typeErr):
+		return &JSONDecodeError{Kind: JSONErrType, Offset: typeErr.Offset, Field: typeErr.Field, Err: err}
+
+	// There is an open issue at https://github.com/golang/go/issues/29035
+	// regarding turning this into a sentinel error.
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &JSONDecodeError{Kind: JSONErrUnknownField, Field: field, Err: err}
+	}
+
+	return err
+}
+
+func jsonCodecOrDefault(c JSONCodec) JSONCodec {
+	if c == nil {
+		return StdJSONCodec{}
+	}
+	return c
+}
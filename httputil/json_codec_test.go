@@ -0,0 +1,181 @@
+package httputil_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestStdJSONCodec(t *testing.T) {
+	v := map[string]string{"html": "<b>&</b>"}
+	cases := []struct {
+		name  string
+		codec httputil.StdJSONCodec
+		want  string
+	}{
+		{
+			name: "Default",
+			want: `{"html":"\u003cb\u003e\u0026\u003c/b\u003e"}` + "\n",
+		},
+		{
+			name:  "DisableHTMLEscape",
+			codec: httputil.StdJSONCodec{DisableHTMLEscape: true},
+			want:  `{"html":"<b>&</b>"}` + "\n",
+		},
+		{
+			name:  "Indent",
+			codec: httputil.StdJSONCodec{Indent: "  "},
+			want:  "{\n  \"html\": \"\\u003cb\\u003e\\u0026\\u003c/b\\u003e\"\n}\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			jr := httputil.JSONResponder{Codec: tc.codec}
+
+			rec := httptest.NewRecorder()
+			jr.Respond(nil, rec, v)
+
+			if got := rec.Body.String(); got != tc.want {
+				t.Errorf("JSONResponder.Respond() body=%q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJSONDecoderCodec(t *testing.T) {
+	cases := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{
+			name: "SyntaxError",
+			err:  &httputil.JSONDecodeError{Kind: httputil.JSONErrSyntax, Offset: 12, Err: errors.E(errors.WithText("bad"))},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains badly-formed JSON (at line 1, column 12)"),
+			),
+		},
+		{
+			name: "TypeError",
+			err:  &httputil.JSONDecodeError{Kind: httputil.JSONErrType, Offset: 31, Err: errors.E(errors.WithText("bad"))},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains an invalid value for the 'age' field (at line 1, column 28)"),
+			),
+		},
+		{
+			name: "UnknownField",
+			err:  &httputil.JSONDecodeError{Kind: httputil.JSONErrUnknownField, Field: "nickname", Err: errors.E(errors.WithText("bad"))},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains unknown field 'nickname'"),
+			),
+		},
+		{
+			name: "EmptyBody",
+			err:  io.EOF,
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body must not be empty"),
+			),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			codec := fakeJSONCodec{err: tc.err}
+			dec := httputil.JSONDecoder{Codec: &codec, UseNumber: true, DisallowUnknownFields: true, ReportErrorLocation: true}
+
+			var p Person
+			err := dec.Decode(newJSONRequest(t, `{ "name": "Donald", "age": "33" }`), &p)
+			if !errors.Match(tc.wantErr, err) {
+				t.Errorf("JSONDecoder.Decode() error diff: %s", errorDiff(tc.wantErr, err))
+			}
+
+			if !codec.useNumber || !codec.disallowUnknownFields {
+				t.Errorf("JSONDecoder.Decode() did not configure the decoder, UseNumber=%t, DisallowUnknownFields=%t",
+					codec.useNumber, codec.disallowUnknownFields)
+			}
+		})
+	}
+}
+
+func TestJSONDecodeError(t *testing.T) {
+	dec := httputil.StdJSONCodec{}.NewDecoder(newJSONRequest(t, `{ "name": 1 }`).Body)
+
+	var p Person
+	err := dec.Decode(&p)
+
+	var decErr *httputil.JSONDecodeError
+	if !errors.As(err, &decErr) || decErr.Kind != httputil.JSONErrType {
+		t.Fatalf("Decode() error=%#v; want *JSONDecodeError with Kind JSONErrType", err)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Errorf("Decode() error=%v; want it to wrap *json.UnmarshalTypeError", err)
+	}
+}
+
+func TestJSONDecodeErrorWithoutErr(t *testing.T) {
+	cases := []struct {
+		name string
+		err  *httputil.JSONDecodeError
+		want string
+	}{
+		{
+			name: "Syntax",
+			err:  &httputil.JSONDecodeError{Kind: httputil.JSONErrSyntax, Offset: 12},
+			want: "json: syntax error at offset 12",
+		},
+		{
+			name: "Type",
+			err:  &httputil.JSONDecodeError{Kind: httputil.JSONErrType, Offset: 31, Field: "age"},
+			want: `json: invalid value for field "age" at offset 31`,
+		},
+		{
+			name: "UnknownField",
+			err:  &httputil.JSONDecodeError{Kind: httputil.JSONErrUnknownField, Field: "nickname"},
+			want: `json: unknown field "nickname"`,
+		},
+		{
+			name: "ZeroValue",
+			err:  &httputil.JSONDecodeError{},
+			want: "json: decode error",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.err.Error(); got != tc.want {
+				t.Errorf("Error()=%q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// fakeJSONCodec returns a decoder which records the options and fails
+// with err.
+type fakeJSONCodec struct {
+	err                              error
+	useNumber, disallowUnknownFields bool
+}
+
+func (*fakeJSONCodec) NewEncoder(w io.Writer) httputil.JSONStreamEncoder {
+	return httputil.StdJSONCodec{}.NewEncoder(w)
+}
+
+func (c *fakeJSONCodec) NewDecoder(io.Reader) httputil.JSONStreamDecoder { return c }
+
+func (c *fakeJSONCodec) Decode(interface{}) error { return c.err }
+
+func (c *fakeJSONCodec) UseNumber() { c.useNumber = true }
+
+func (c *fakeJSONCodec) DisallowUnknownFields() { c.disallowUnknownFields = true }
@@ -1,12 +1,12 @@
 package httputil
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"regexp"
-	"strings"
 
 	"github.com/sudo-suhas/xgo/errors"
 )
@@ -25,6 +25,11 @@ var (
 
 // JSONDecoder decodes the request body into the given value. It expects
 // the request body to be JSON.
+//
+// The request body is decoded as a stream unless one of the options
+// which require it to be read fully into memory is enabled or the
+// destination contains xgo.Optional values. The options of the
+// JSONDecoder also apply to the values of xgo.Optional.
 type JSONDecoder struct {
 	// SkipCheckContentType, if set to true, skips the check on
 	// value of Content-Type header being "application/json".
@@ -39,13 +44,43 @@ type JSONDecoder struct {
 	// which do not match any non-ignored, exported fields in the
 	// destination.
 	DisallowUnknownFields bool
+
+	// DisallowDuplicateKeys causes the Decoder to return an error when
+	// an object in the input contains the same key more than once. If
+	// the destination is a struct, keys which resolve to the same field
+	// are considered duplicates as well. By default, the last value
+	// wins silently.
+	//
+	// Enabling this, or CaseSensitiveFields, requires the request body
+	// to be read fully into memory before decoding.
+	DisallowDuplicateKeys bool
+
+	// CaseSensitiveFields causes the Decoder to return an error when an
+	// object key only matches a struct field in the destination
+	// case-insensitively. By default, encoding/json prefers an exact
+	// match but also accepts a case-insensitive match.
+	CaseSensitiveFields bool
+
+	// ReportErrorLocation causes the Decoder to report the line and
+	// column of the problem in the errors for badly-formed JSON and
+	// invalid values, instead of the byte offset. JSONErrorLocation is
+	// set as the Data on such errors. The JSON path of an invalid value
+	// is reported either way.
+	//
+	// Enabling this requires the request body to be read fully into
+	// memory before decoding.
+	ReportErrorLocation bool
+
+	// Codec is used to decode the request body. StdJSONCodec is used if
+	// nil. Optional.
+	Codec JSONCodec
 }
 
 // Decode decodes the HTTP request into the given value.
 func (j JSONDecoder) Decode(r *http.Request, v interface{}) error {
 	const op = "JSONDecoder.Decode"
 
-	defer io.Copy(io.Discard, r.Body) //nolint:errcheck
+	defer drain(r.Body)
 
 	// Based on https://www.alexedwards.net/blog/how-to-properly-parse-a-json-request-body
 
@@ -53,73 +88,51 @@ func (j JSONDecoder) Decode(r *http.Request, v interface{}) error {
 		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
-	dec := j.newDecoder(r.Body)
+	var (
+		body = io.Reader(r.Body)
+		// b is the request body if it was read fully. loc is set to b if
+		// the location of any problem should be reported.
+		b, loc    []byte
+		locate    = j.DisallowDuplicateKeys || j.CaseSensitiveFields || j.ReportErrorLocation
+		optionals = hasOptional(reflect.TypeOf(v))
+	)
+	if locate || optionals {
+		var err error
+		if b, err = io.ReadAll(r.Body); err != nil {
+			return decodeErr(nil, err)
+		}
+
+		if j.DisallowDuplicateKeys || j.CaseSensitiveFields {
+			if err := j.checkKeys(b, v); err != nil {
+				return errors.E(errors.WithOp(op), errors.WithErr(err))
+			}
+		}
+
+		body = bytes.NewReader(b)
+		if locate {
+			loc = b
+		}
+	}
+
+	dec := j.newDecoder(body)
 	if err := dec.Decode(v); err != nil {
-		var (
-			syntaxErr *json.SyntaxError
-			typeErr   *json.UnmarshalTypeError
-		)
-		switch {
-		// Catch any syntax errors in the JSON and send an error message
-		// which interpolates the location of the problem to make it
-		// easier for the client to fix.
-		case errors.As(err, &syntaxErr):
-			msg := fmt.Sprintf("Request body contains badly-formed JSON (at position %d)", syntaxErr.Offset)
-			return errors.E(
-				errors.WithOp(op), errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err),
-			)
-
-		// In some circumstances Decode() may also return an
-		// io.ErrUnexpectedEOF error for syntax errors in the JSON. There
-		// is an open issue regarding this at
-		// https://github.com/golang/go/issues/25956.
-		case errors.Is(err, io.ErrUnexpectedEOF):
-			msg := "Request body contains badly-formed JSON"
-			return errors.E(
-				errors.WithOp(op), errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err),
-			)
-
-		// Catch any type errors, like trying to assign a string in the
-		// JSON request body to an int field in our Person struct. We can
-		// interpolate the relevant field name and position into the error
-		// message to make it easier for the client to fix.
-		case errors.As(err, &typeErr):
-			msg := fmt.Sprintf(
-				"Request body contains an invalid value for the '%s' field (at position %d)",
-				typeErr.Field, typeErr.Offset,
-			)
-			return errors.E(
-				errors.WithOp(op), errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err),
-			)
-
-		// Catch the error caused by extra unexpected fields in the request
-		// body. We extract the field name from the error message and
-		// interpolate it in our custom error message. There is an open
-		// issue at https://github.com/golang/go/issues/29035 regarding
-		// turning this into a sentinel error.
-		case strings.HasPrefix(err.Error(), "json: unknown field "):
-			fieldName := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
-			msg := fmt.Sprintf("Request body contains unknown field '%s'", fieldName)
-			return errors.E(
-				errors.WithOp(op), errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err),
-			)
-
-		// An io.EOF error is returned by Decode() if the request body is
-		// empty.
-		case errors.Is(err, io.EOF):
-			msg := "Request body must not be empty"
-			return errors.E(
-				errors.WithOp(op), errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err),
-			)
-
-		// Catch the error caused by the request body being too large. Again
-		// there is an open issue regarding turning this into a sentinel
-		// error at https://github.com/golang/go/issues/30715.
-		case err.Error() == "http: request body too large":
-			return errors.E(errors.WithOp(op), ErrKindRequestEntityTooLarge, errors.WithErr(err))
+		err = withJSONPath(err, reflect.TypeOf(v))
+
+		// The error could be from the value of an Optional, with the
+		// offset relative to the value. Decoding the Optionals again
+		// finds the error with the offset relative to the request body.
+		if optionals {
+			if optErr := j.decodeOptionals(b, v, 0, ""); optErr != nil {
+				err = optErr
+			}
 		}
+		return decodeErr(loc, err)
+	}
 
-		return errors.E(errors.WithOp(op), errors.Internal, errors.WithErr(err))
+	if optionals && (j.UseNumber || j.DisallowUnknownFields || j.Codec != nil) {
+		if err := j.decodeOptionals(b, v, 0, ""); err != nil {
+			return decodeErr(loc, err)
+		}
 	}
 
 	// Call decode again, using a pointer to an empty anonymous struct as
@@ -136,6 +149,123 @@ func (j JSONDecoder) Decode(r *http.Request, v interface{}) error {
 	return nil
 }
 
+// decodeErr translates the error returned by JSONStreamDecoder.Decode
+// into an *errors.Error with the appropriate Kind and UserMsg. b is the
+// request body and is used to determine the location of the problem. It
+// is nil if the request body was decoded as a stream, in which case the
+// byte offset is reported instead.
+func decodeErr(b []byte, err error) error {
+	const op = "JSONDecoder.Decode"
+
+	var decErr *JSONDecodeError
+	errors.As(err, &decErr)
+	switch {
+	// Preserve the classification of errors encountered while reading
+	// the request body, such as a failure to decompress it.
+	case errors.WhatKind(err) != errors.Unknown:
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+
+	// Catch any syntax errors in the JSON and send an error message
+	// which interpolates the location of the problem to make it
+	// easier for the client to fix.
+	case decErr != nil && decErr.Kind == JSONErrSyntax && b == nil:
+		msg := fmt.Sprintf("Request body contains badly-formed JSON (at position %d)", decErr.Offset)
+		return errors.E(
+			errors.WithOp(op), errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err),
+		)
+
+	case decErr != nil && decErr.Kind == JSONErrSyntax:
+		// The offset is after reading the offending byte.
+		path, _ := jsonValueAt(b, decErr.Offset)
+		loc := newJSONErrorLocation(b, decErr.Offset-1, path)
+		msg := fmt.Sprintf(
+			"Request body contains badly-formed JSON (at line %d, column %d)", loc.Line, loc.Column,
+		)
+		return errors.E(
+			errors.WithOp(op), errors.InvalidInput, errors.WithUserMsg(msg), errors.WithData(loc), errors.WithErr(err),
+		)
+
+	// In some circumstances Decode() may also return an
+	// io.ErrUnexpectedEOF error for syntax errors in the JSON. There
+	// is an open issue regarding this at
+	// https://github.com/golang/go/issues/25956.
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		msg := "Request body contains badly-formed JSON"
+		return errors.E(
+			errors.WithOp(op), errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err),
+		)
+
+	// Catch any type errors, like trying to assign a string in the
+	// JSON request body to an int field in our Person struct. We can
+	// interpolate the relevant JSON path and location into the error
+	// message to make it easier for the client to fix.
+	case decErr != nil && decErr.Kind == JSONErrType && b == nil:
+		msg := fmt.Sprintf(
+			"Request body contains an invalid value for the '%s' field (at position %d)", decErr.Field, decErr.Offset,
+		)
+		if decErr.Field == "" {
+			msg = fmt.Sprintf("Request body contains an invalid value (at position %d)", decErr.Offset)
+		}
+		return errors.E(
+			errors.WithOp(op), errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err),
+		)
+
+	case decErr != nil && decErr.Kind == JSONErrType:
+		path, start := jsonValueAt(b, decErr.Offset)
+		loc := newJSONErrorLocation(b, start, path)
+		msg := fmt.Sprintf(
+			"Request body contains an invalid value for the '%s' field (at line %d, column %d)",
+			path, loc.Line, loc.Column,
+		)
+		if path == "" {
+			msg = fmt.Sprintf("Request body contains an invalid value (at line %d, column %d)", loc.Line, loc.Column)
+		}
+		return errors.E(
+			errors.WithOp(op), errors.InvalidInput, errors.WithUserMsg(msg), errors.WithData(loc), errors.WithErr(err),
+		)
+
+	// Catch the error caused by extra unexpected fields in the request
+	// body and interpolate the field name in our custom error message.
+	case decErr != nil && decErr.Kind == JSONErrUnknownField:
+		msg := fmt.Sprintf("Request body contains unknown field '%s'", decErr.Field)
+		return errors.E(
+			errors.WithOp(op), errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err),
+		)
+
+	// An io.EOF error is returned by Decode() if the request body is
+	// empty.
+	case errors.Is(err, io.EOF):
+		msg := "Request body must not be empty"
+		return errors.E(
+			errors.WithOp(op), errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err),
+		)
+
+	// Catch the error caused by the request body being too large. Again
+	// there is an open issue regarding turning this into a sentinel
+	// error at https://github.com/golang/go/issues/30715.
+	case err.Error() == "http: request body too large":
+		return errors.E(errors.WithOp(op), ErrKindRequestEntityTooLarge, errors.WithErr(err))
+	}
+
+	return errors.E(errors.WithOp(op), errors.Internal, errors.WithErr(err))
+}
+
+// withJSONPath rewrites the Field of a JSONErrType error, a path of
+// keys and indices separated by dots as reported by encoding/json, to
+// the JSON path format used elsewhere, such as 'items[1].amount'. t is
+// the type of the destination and is used to tell the array indices
+// from the object keys.
+func withJSONPath(err error, t reflect.Type) error {
+	var decErr *JSONDecodeError
+	if !errors.As(err, &decErr) || decErr.Kind != JSONErrType || decErr.Field == "" {
+		return err
+	}
+
+	e := *decErr
+	e.Field = jsonFieldPath(t, decErr.Field)
+	return &e
+}
+
 // checkContentType checks that the Content-Type header is present and
 // has the value application/json. The check is skipped if
 // SkipCheckContentType is true.
@@ -156,8 +286,8 @@ func (j JSONDecoder) checkContentType(r *http.Request) error {
 
 // newDecoder sets up the decoder and calls the DisallowUnknownFields(),
 // UseNumber() methods on it.
-func (j JSONDecoder) newDecoder(r io.Reader) *json.Decoder {
-	dec := json.NewDecoder(r)
+func (j JSONDecoder) newDecoder(r io.Reader) JSONStreamDecoder {
+	dec := jsonCodecOrDefault(j.Codec).NewDecoder(r)
 
 	if j.UseNumber {
 		dec.UseNumber()
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/sudo-suhas/xgo"
 	"github.com/sudo-suhas/xgo/errors"
 	"github.com/sudo-suhas/xgo/httputil"
 )
@@ -104,6 +105,22 @@ func TestJSONDecoderDecode(t *testing.T) {
 				errors.WithUserMsg("Request body contains badly-formed JSON (at position 28)"),
 			),
 		},
+		{
+			name: "SyntaxErrorLocation",
+			j:    httputil.JSONDecoder{ReportErrorLocation: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{ "name": "Donald", "age": }`,
+			},
+			v: &Person{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains badly-formed JSON (at line 1, column 28)"),
+			),
+		},
 		{
 			name: "UnexpectedEOF",
 			r: request{
@@ -131,7 +148,89 @@ func TestJSONDecoderDecode(t *testing.T) {
 			wantErr: errors.E(
 				errors.WithOp("JSONDecoder.Decode"),
 				errors.InvalidInput,
-				errors.WithUserMsg("Request body contains an invalid value for the 'Age' field (at position 35)"),
+				errors.WithUserMsg("Request body contains an invalid value for the 'age' field (at position 35)"),
+			),
+		},
+		{
+			name: "TypeErrorLocation",
+			j:    httputil.JSONDecoder{ReportErrorLocation: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{ "name": "Donald", "age": "middle" }`,
+			},
+			v: &Person{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains an invalid value for the 'age' field (at line 1, column 28)"),
+				errors.WithData(httputil.JSONErrorLocation{Path: "age", Line: 1, Column: 28, Offset: 27}),
+			),
+		},
+		{
+			name: "SyntaxErrorMultiline",
+			j:    httputil.JSONDecoder{ReportErrorLocation: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    "{\n  \"id\": \"ö1\",\n  \"items\": [\n    { \"sku\": \"a\", },\n  ]\n}",
+			},
+			v: &Order{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains badly-formed JSON (at line 4, column 19)"),
+				errors.WithData(httputil.JSONErrorLocation{Path: "items[0]", Line: 4, Column: 19, Offset: 48}),
+			),
+		},
+		{
+			name: "TypeErrorNested",
+			j:    httputil.JSONDecoder{ReportErrorLocation: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    "{\n  \"id\": \"o1\",\n  \"items\": [\n    { \"sku\": \"a\", \"amount\": 1 },\n    { \"sku\": \"b\", \"amount\": \"ten\" }\n  ]\n}",
+			},
+			v: &Order{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains an invalid value for the 'items[1].amount' field (at line 5, column 29)"),
+				errors.WithData(httputil.JSONErrorLocation{Path: "items[1].amount", Line: 5, Column: 29, Offset: 90}),
+			),
+		},
+		{
+			name: "TypeErrorNestedPosition",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{"id":"o1","items":[{"sku":"a","amount":1},{"sku":"b","amount":"ten"}]}`,
+			},
+			v: &Order{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains an invalid value for the 'items[1].amount' field (at position 68)"),
+			),
+		},
+		{
+			name: "TypeErrorTopLevel",
+			j:    httputil.JSONDecoder{ReportErrorLocation: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `[1, 2]`,
+			},
+			v: &Person{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains an invalid value (at line 1, column 1)"),
 			),
 		},
 		{
@@ -180,6 +279,207 @@ func TestJSONDecoderDecode(t *testing.T) {
 				errors.WithUserMsg("Request body must only contain a single JSON object"),
 			),
 		},
+		{
+			name: "DuplicateKeysAllowedByDefault",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{ "name": "Donald", "age": 1, "age": 33 }`,
+			},
+			v:    &Person{},
+			want: &Person{Name: "Donald", Age: 33},
+		},
+		{
+			name: "SuccessWithDisallowDuplicateKeys",
+			j:    httputil.JSONDecoder{DisallowDuplicateKeys: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{ "id": "o1", "items": [{ "sku": "a", "amount": 1 }, { "sku": "b", "amount": 2 }] }`,
+			},
+			v: &Order{},
+			want: &Order{ID: "o1", Items: []OrderItem{
+				{SKU: "a", Amount: 1},
+				{SKU: "b", Amount: 2},
+			}},
+		},
+		{
+			name: "DuplicateKey",
+			j:    httputil.JSONDecoder{DisallowDuplicateKeys: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{ "name": "Donald", "age": 1, "age": 33 }`,
+			},
+			v: &Person{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains duplicate key 'age' (at 'age')"),
+				errors.WithData(httputil.JSONErrorLocation{Path: "age", Key: "age", Line: 1, Column: 31, Offset: 30}),
+			),
+		},
+		{
+			name: "DuplicateKeyNested",
+			j:    httputil.JSONDecoder{DisallowDuplicateKeys: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{ "id": "o1", "items": [{ "sku": "a" }, { "sku": "b", "amount": 1, "amount": 1000 }] }`,
+			},
+			v: &Order{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains duplicate key 'amount' (at 'items[1].amount')"),
+			),
+		},
+		{
+			name: "DuplicateKeyDifferentCase",
+			j:    httputil.JSONDecoder{DisallowDuplicateKeys: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{ "items": [{ "amount": 1, "Amount": 1000 }] }`,
+			},
+			v: &Order{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains duplicate key 'Amount' (at 'items[0].Amount')"),
+			),
+		},
+		{
+			name: "DuplicateKeyInMap",
+			j:    httputil.JSONDecoder{DisallowDuplicateKeys: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{ "id": "o1", "meta": { "k": "v", "k": "w" } }`,
+			},
+			v: &Order{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains duplicate key 'k' (at 'meta.k')"),
+			),
+		},
+		{
+			name: "CaseSensitiveFields",
+			j:    httputil.JSONDecoder{CaseSensitiveFields: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{ "id": "o1", "items": [{ "SKU": "a" }] }`,
+			},
+			v: &Order{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains field 'SKU' which does not match the case of 'sku' (at 'items[0].SKU')"),
+			),
+		},
+		{
+			name: "SyntaxErrorWithDisallowDuplicateKeys",
+			j:    httputil.JSONDecoder{DisallowDuplicateKeys: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{ "name": "Donald", "age": }`,
+			},
+			v: &Person{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains badly-formed JSON (at line 1, column 28)"),
+			),
+		},
+		{
+			name: "OptionalUnknownField",
+			j:    httputil.JSONDecoder{DisallowUnknownFields: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{"sub":{"a":1,"zzz":2}}`,
+			},
+			v: &Profile{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains unknown field 'zzz'"),
+			),
+		},
+		{
+			name: "OptionalUseNumber",
+			j:    httputil.JSONDecoder{UseNumber: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{"extra":{"n":10},"subs":[{"a":1}]}`,
+			},
+			v: &Profile{},
+			want: &Profile{
+				Extra: xgo.NewOptional[interface{}](map[string]interface{}{"n": json.Number("10")}),
+				Subs:  []xgo.Optional[ProfileSub]{xgo.NewOptional(ProfileSub{A: 1})},
+			},
+		},
+		{
+			name: "OptionalTypeError",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{"pad":"xxxxxxxx","age":"abc"}`,
+			},
+			v: &Profile{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains an invalid value for the 'age' field (at position 29)"),
+			),
+		},
+		{
+			name: "OptionalTypeErrorNested",
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    `{"subs":[{"a":1},{"a":"one"}]}`,
+			},
+			v: &Profile{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains an invalid value for the 'subs[1].a' field (at position 27)"),
+			),
+		},
+		{
+			name: "OptionalTypeErrorLocation",
+			j:    httputil.JSONDecoder{ReportErrorLocation: true},
+			r: request{
+				method:  method,
+				url:     url,
+				headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+				body:    "{\n  \"pad\": \"xxxxxxxx\",\n  \"sub\": {\"a\": \"one\"}\n}",
+			},
+			v: &Profile{},
+			wantErr: errors.E(
+				errors.WithOp("JSONDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains an invalid value for the 'sub.a' field (at line 3, column 16)"),
+				errors.WithData(httputil.JSONErrorLocation{Path: "sub.a", Line: 3, Column: 16, Offset: 38}),
+			),
+		},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -249,6 +549,29 @@ func (req request) build() (*http.Request, error) {
 	return r, nil
 }
 
+type Order struct {
+	ID    string            `json:"id"`
+	Items []OrderItem       `json:"items"`
+	Meta  map[string]string `json:"meta"`
+}
+
+type OrderItem struct {
+	SKU    string `json:"sku"`
+	Amount int    `json:"amount"`
+}
+
+type Profile struct {
+	Pad   string                     `json:"pad"`
+	Age   xgo.Optional[int]          `json:"age"`
+	Sub   xgo.Optional[ProfileSub]   `json:"sub"`
+	Subs  []xgo.Optional[ProfileSub] `json:"subs"`
+	Extra xgo.Optional[interface{}]  `json:"extra"`
+}
+
+type ProfileSub struct {
+	A int `json:"a"`
+}
+
 type Person struct {
 	Name string
 	Age  int
@@ -0,0 +1,337 @@
+package httputil
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// checkKeys walks over the JSON in b and checks the object keys as per
+// DisallowDuplicateKeys and CaseSensitiveFields. The structure of v is
+// used to resolve keys to struct fields.
+//
+// Malformed JSON is not reported by checkKeys. It is left to the
+// subsequent decoding step to do so.
+func (j JSONDecoder) checkKeys(b []byte, v interface{}) error {
+	kc := keyChecker{
+		dec:           json.NewDecoder(bytes.NewReader(b)),
+		b:             b,
+		dupKeys:       j.DisallowDuplicateKeys,
+		caseSensitive: j.CaseSensitiveFields,
+	}
+	kc.dec.UseNumber()
+
+	err := kc.value(reflect.TypeOf(v), "")
+	if err == errMalformedJSON {
+		return nil
+	}
+	return err
+}
+
+// errMalformedJSON signals that the key check was aborted because the
+// JSON could not be tokenised.
+var errMalformedJSON = errors.New("malformed JSON")
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+type keyChecker struct {
+	dec           *json.Decoder
+	b             []byte
+	dupKeys       bool
+	caseSensitive bool
+}
+
+// value checks the next JSON value. t is the destination type for the
+// value and can be nil if it is not known.
+func (kc keyChecker) value(t reflect.Type, path string) error {
+	tok, err := kc.dec.Token()
+	if err != nil {
+		return errMalformedJSON
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		return kc.object(t, path)
+
+	case json.Delim('['):
+		return kc.array(t, path)
+	}
+
+	return nil
+}
+
+func (kc keyChecker) object(t reflect.Type, path string) error {
+	t = destType(t)
+
+	var fields []jsonField
+	if t != nil && t.Kind() == reflect.Struct {
+		fields = structFields(t)
+	}
+
+	seen := make(map[string]bool)
+	for kc.dec.More() {
+		keyStart := skipJSONSeparators(kc.b, kc.dec.InputOffset())
+		tok, err := kc.dec.Token()
+		if err != nil {
+			return errMalformedJSON
+		}
+
+		key, ok := tok.(string)
+		if !ok {
+			return errMalformedJSON
+		}
+		keyPath := jsonPathKey(path, key)
+		loc := newJSONErrorLocation(kc.b, keyStart, keyPath)
+		loc.Key = key
+
+		var (
+			childType reflect.Type
+			dedupKey  = key
+		)
+		switch {
+		case fields != nil:
+			f, exact := lookupField(fields, key)
+			if f != nil && !exact && kc.caseSensitive {
+				msg := fmt.Sprintf(
+					"Request body contains field '%s' which does not match the case of '%s' (at '%s')",
+					key, f.name, keyPath,
+				)
+				return errors.E(
+					errors.InvalidInput,
+					errors.WithUserMsg(msg),
+					errors.WithData(loc),
+				)
+			}
+			if f != nil {
+				childType, dedupKey = f.typ, f.name
+			}
+
+		case t != nil && t.Kind() == reflect.Map:
+			childType = t.Elem()
+		}
+
+		if kc.dupKeys && seen[dedupKey] {
+			msg := fmt.Sprintf("Request body contains duplicate key '%s' (at '%s')", key, keyPath)
+			return errors.E(
+				errors.InvalidInput,
+				errors.WithUserMsg(msg),
+				errors.WithData(loc),
+			)
+		}
+		seen[dedupKey] = true
+
+		if err := kc.value(childType, keyPath); err != nil {
+			return err
+		}
+	}
+
+	// Consume the closing delimiter.
+	if _, err := kc.dec.Token(); err != nil {
+		return errMalformedJSON
+	}
+	return nil
+}
+
+func (kc keyChecker) array(t reflect.Type, path string) error {
+	t = destType(t)
+
+	var elemType reflect.Type
+	if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		elemType = t.Elem()
+	}
+
+	for i := 0; kc.dec.More(); i++ {
+		if err := kc.value(elemType, jsonPathIndex(path, i)); err != nil {
+			return err
+		}
+	}
+
+	// Consume the closing delimiter.
+	if _, err := kc.dec.Token(); err != nil {
+		return errMalformedJSON
+	}
+	return nil
+}
+
+// destType dereferences pointers and returns the type which would be
+// used as the destination by encoding/json. nil is returned if the type
+// is not known or if it implements custom unmarshaling.
+func destType(t reflect.Type) reflect.Type {
+	for t != nil {
+		if t.Implements(jsonUnmarshalerType) || t.Implements(textUnmarshalerType) {
+			return nil
+		}
+		if t.Kind() != reflect.Ptr {
+			break
+		}
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() == reflect.Interface {
+		return nil
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return nil
+	}
+	return t
+}
+
+// jsonField is a struct field as seen by encoding/json.
+type jsonField struct {
+	name  string
+	typ   reflect.Type
+	index []int
+}
+
+// structFields returns the fields of the struct type t which are
+// considered by encoding/json, including the promoted fields of
+// embedded structs. It is a simplified take on the field resolution in
+// encoding/json and does not handle conflicting names at the same
+// depth.
+func structFields(t reflect.Type) []jsonField {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var (
+		fields  []jsonField
+		visited = map[reflect.Type]bool{}
+		current = []embedded{{typ: t}}
+	)
+	for len(current) > 0 {
+		var next []embedded
+		for _, e := range current {
+			st := e.typ
+			if visited[st] {
+				continue
+			}
+			visited[st] = true
+
+			for i := 0; i < st.NumField(); i++ {
+				sf := st.Field(i)
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+
+				name := tag
+				if idx := strings.Index(tag, ","); idx != -1 {
+					name = tag[:idx]
+				}
+
+				index := append(append([]int(nil), e.index...), i)
+
+				ft := sf.Type
+				if sf.Anonymous {
+					if ft.Kind() == reflect.Ptr {
+						ft = ft.Elem()
+					}
+					if name == "" && ft.Kind() == reflect.Struct {
+						next = append(next, embedded{typ: ft, index: index})
+						continue
+					}
+				}
+				if !sf.IsExported() {
+					continue
+				}
+
+				if name == "" {
+					name = sf.Name
+				}
+				if !hasField(fields, name) {
+					fields = append(fields, jsonField{name: name, typ: sf.Type, index: index})
+				}
+			}
+		}
+		current = next
+	}
+	return fields
+}
+
+func hasField(fields []jsonField, name string) bool {
+	for _, f := range fields {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupField returns the field matching the key. Like encoding/json, an
+// exact match is preferred over a case-insensitive one.
+func lookupField(fields []jsonField, key string) (f *jsonField, exact bool) {
+	for i := range fields {
+		if fields[i].name == key {
+			return &fields[i], true
+		}
+	}
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, key) {
+			return &fields[i], false
+		}
+	}
+	return nil, false
+}
+
+// jsonPathKey appends the object key to the JSON path.
+func jsonPathKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// jsonPathIndex appends the array index to the JSON path.
+func jsonPathIndex(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+// jsonFieldPath converts the path of the value in t, with the keys and
+// indices separated by dots, to the JSON path format. A segment is
+// treated as an object key once the type of the value is not known.
+func jsonFieldPath(t reflect.Type, field string) string {
+	var path string
+	for _, seg := range strings.Split(field, ".") {
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		var kind reflect.Kind
+		if t != nil {
+			kind = t.Kind()
+		}
+		switch kind {
+		case reflect.Slice, reflect.Array:
+			if i, err := strconv.Atoi(seg); err == nil {
+				path, t = jsonPathIndex(path, i), t.Elem()
+				continue
+			}
+			t = nil
+
+		case reflect.Map:
+			t = t.Elem()
+
+		case reflect.Struct:
+			if f, _ := lookupField(structFields(t), seg); f != nil {
+				t = f.typ
+			} else {
+				t = nil
+			}
+
+		default:
+			t = nil
+		}
+		path = jsonPathKey(path, seg)
+	}
+	return path
+}
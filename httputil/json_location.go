@@ -0,0 +1,120 @@
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"unicode/utf8"
+)
+
+// JSONErrorLocation pinpoints the location of the problem in the
+// request body. It is set as the Data on errors returned by
+// JSONDecoder.Decode for badly-formed JSON, invalid values and
+// disallowed keys so that clients can highlight the exact location.
+type JSONErrorLocation struct {
+	// Path is the JSON path of the offending value or key, for example
+	// "items[3].price". It is empty for the top-level value.
+	Path string `json:"path,omitempty"`
+
+	// Key is the offending object key, if applicable.
+	Key string `json:"key,omitempty"`
+
+	// Line is the 1-based line number.
+	Line int `json:"line"`
+
+	// Column is the 1-based column number, counted in characters.
+	Column int `json:"column"`
+
+	// Offset is the 0-based byte offset.
+	Offset int64 `json:"offset"`
+}
+
+// newJSONErrorLocation builds the JSONErrorLocation for the byte at the
+// given offset in b.
+func newJSONErrorLocation(b []byte, offset int64, path string) JSONErrorLocation {
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	lineStart := bytes.LastIndexByte(b[:offset], '\n') + 1
+	return JSONErrorLocation{
+		Path:   path,
+		Line:   bytes.Count(b[:offset], []byte{'\n'}) + 1,
+		Column: utf8.RuneCount(b[lineStart:offset]) + 1,
+		Offset: offset,
+	}
+}
+
+// jsonValueAt returns the JSON path and the starting offset of the
+// value in b which spans the given offset, as reported by encoding/json
+// in errors. If b is malformed, the path of the value being read when
+// the problem was encountered is returned.
+func jsonValueAt(b []byte, offset int64) (path string, start int64) {
+	pf := pathFinder{dec: json.NewDecoder(bytes.NewReader(b)), b: b, offset: offset}
+	pf.dec.UseNumber()
+
+	path, start, _ = pf.value("")
+	return path, start
+}
+
+type pathFinder struct {
+	dec    *json.Decoder
+	b      []byte
+	offset int64
+}
+
+// value reads the next JSON value. found is true if the value, or one
+// of its descendants, spans the offset or is malformed.
+func (pf pathFinder) value(path string) (p string, start int64, found bool) {
+	start = skipJSONSeparators(pf.b, pf.dec.InputOffset())
+	tok, err := pf.dec.Token()
+	if err != nil || pf.dec.InputOffset() >= pf.offset {
+		return path, start, true
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		for pf.dec.More() {
+			tok, err := pf.dec.Token()
+			key, ok := tok.(string)
+			if err != nil || !ok {
+				return path, start, true
+			}
+
+			if p, start, found := pf.value(jsonPathKey(path, key)); found {
+				return p, start, true
+			}
+		}
+
+	case json.Delim('['):
+		for i := 0; pf.dec.More(); i++ {
+			if p, start, found := pf.value(jsonPathIndex(path, i)); found {
+				return p, start, true
+			}
+		}
+
+	default:
+		return "", 0, false
+	}
+
+	// Consume the closing delimiter.
+	if _, err := pf.dec.Token(); err != nil {
+		return path, start, true
+	}
+	return "", 0, false
+}
+
+// skipJSONSeparators returns the offset of the first byte in b, starting
+// at offset, which is not whitespace or a separator.
+func skipJSONSeparators(b []byte, offset int64) int64 {
+	for ; offset < int64(len(b)); offset++ {
+		switch b[offset] {
+		case ' ', '\t', '\n', '\r', ',', ':':
+		default:
+			return offset
+		}
+	}
+	return offset
+}
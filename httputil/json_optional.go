@@ -0,0 +1,281 @@
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// decodeTargeter is implemented by xgo.Optional. DecodeTarget marks the
+// value as present and returns a pointer to the underlying value.
+type decodeTargeter interface {
+	DecodeTarget() interface{}
+}
+
+var decodeTargeterType = reflect.TypeOf((*decodeTargeter)(nil)).Elem()
+
+// optionalTypes caches the result of hasOptional by type.
+var optionalTypes sync.Map // map[reflect.Type]bool
+
+// hasOptional reports whether the values of the type t can contain an
+// Optional which would be decoded by encoding/json.
+func hasOptional(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+	if has, ok := optionalTypes.Load(t); ok {
+		return has.(bool)
+	}
+
+	has := containsOptional(t, map[reflect.Type]bool{})
+	optionalTypes.Store(t, has)
+	return has
+}
+
+func containsOptional(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+
+	if reflect.PtrTo(t).Implements(decodeTargeterType) {
+		return true
+	}
+	if t.Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return containsOptional(t.Elem(), visited)
+
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && containsOptional(t.Elem(), visited)
+
+	case reflect.Struct:
+		for _, f := range structFields(t) {
+			if containsOptional(f.typ, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeOptionals walks over the JSON in b alongside v and decodes the
+// value of each Optional again, this time with the options of the
+// JSONDecoder. Optional.UnmarshalJSON uses json.Unmarshal and is not
+// aware of these options. It also reports errors relative to the value
+// of the Optional rather than the request body.
+//
+// b starts at the offset base in the request body and is the value at
+// path. Malformed JSON is not reported by decodeOptionals. It is left to
+// the decoding of v to do so.
+func (j JSONDecoder) decodeOptionals(b []byte, v interface{}, base int64, path string) error {
+	od := optionalDecoder{
+		j:    j,
+		dec:  json.NewDecoder(bytes.NewReader(b)),
+		b:    b,
+		base: base,
+	}
+
+	err := od.value(reflect.ValueOf(v), path)
+	if err == errMalformedJSON {
+		return nil
+	}
+	return err
+}
+
+type optionalDecoder struct {
+	j    JSONDecoder
+	dec  *json.Decoder
+	b    []byte
+	base int64
+}
+
+// value decodes the Optionals in the next JSON value. v is the
+// destination for the value and can be invalid if it is not known.
+func (od optionalDecoder) value(v reflect.Value, path string) error {
+	for v.IsValid() && v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v = reflect.Value{}
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || !v.CanSet() || !hasOptional(v.Type()) {
+		return od.skip()
+	}
+
+	if dt, ok := v.Addr().Interface().(decodeTargeter); ok {
+		return od.optional(dt, path)
+	}
+
+	tok, err := od.dec.Token()
+	if err != nil {
+		return errMalformedJSON
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		return od.object(v, path)
+
+	case json.Delim('['):
+		return od.array(v, path)
+	}
+
+	return nil
+}
+
+func (od optionalDecoder) optional(dt decodeTargeter, path string) error {
+	start := od.base + skipJSONSeparators(od.b, od.dec.InputOffset())
+
+	var raw json.RawMessage
+	if err := od.dec.Decode(&raw); err != nil {
+		return errMalformedJSON
+	}
+	if bytes.Equal(raw, []byte("null")) {
+		return nil
+	}
+
+	target := dt.DecodeTarget()
+	if err := od.j.newDecoder(bytes.NewReader(raw)).Decode(target); err != nil {
+		return optionalErr(err, reflect.TypeOf(target), start, path)
+	}
+
+	if hasOptional(reflect.TypeOf(target).Elem()) {
+		return od.j.decodeOptionals(raw, target, start, path)
+	}
+	return nil
+}
+
+func (od optionalDecoder) object(v reflect.Value, path string) error {
+	var fields []jsonField
+	if v.Kind() == reflect.Struct {
+		fields = structFields(v.Type())
+	}
+
+	for od.dec.More() {
+		tok, err := od.dec.Token()
+		if err != nil {
+			return errMalformedJSON
+		}
+
+		key, ok := tok.(string)
+		if !ok {
+			return errMalformedJSON
+		}
+		keyPath := jsonPathKey(path, key)
+
+		switch {
+		case fields != nil:
+			var field reflect.Value
+			if f, exact := lookupField(fields, key); f != nil && (exact || !od.j.CaseSensitiveFields) {
+				field = structField(v, f.index)
+			}
+			if err := od.value(field, keyPath); err != nil {
+				return err
+			}
+
+		case v.Kind() == reflect.Map && !v.IsNil():
+			// Map elements are not addressable. So the element is copied,
+			// decoded and set back on the map.
+			k := reflect.ValueOf(key).Convert(v.Type().Key())
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if mv := v.MapIndex(k); mv.IsValid() {
+				elem.Set(mv)
+			}
+			if err := od.value(elem, keyPath); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, elem)
+
+		default:
+			if err := od.skip(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Consume the closing delimiter.
+	if _, err := od.dec.Token(); err != nil {
+		return errMalformedJSON
+	}
+	return nil
+}
+
+func (od optionalDecoder) array(v reflect.Value, path string) error {
+	for i := 0; od.dec.More(); i++ {
+		var elem reflect.Value
+		if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && i < v.Len() {
+			elem = v.Index(i)
+		}
+		if err := od.value(elem, jsonPathIndex(path, i)); err != nil {
+			return err
+		}
+	}
+
+	// Consume the closing delimiter.
+	if _, err := od.dec.Token(); err != nil {
+		return errMalformedJSON
+	}
+	return nil
+}
+
+// skip consumes the next JSON value.
+func (od optionalDecoder) skip() error {
+	var raw json.RawMessage
+	if err := od.dec.Decode(&raw); err != nil {
+		return errMalformedJSON
+	}
+	return nil
+}
+
+// structField returns the field of the struct v with the given index.
+// An invalid Value is returned if the field is promoted from a nil
+// embedded struct pointer.
+func structField(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// optionalErr translates the error from decoding the value of an
+// Optional, which starts at offset in the request body and is at path,
+// to be relative to the request body. t is the type of the value.
+func optionalErr(err error, t reflect.Type, offset int64, path string) error {
+	var decErr *JSONDecodeError
+	if !errors.As(err, &decErr) || decErr.Kind == JSONErrUnknownField {
+		return err
+	}
+
+	shifted := *decErr
+	shifted.Offset += offset
+	if shifted.Kind == JSONErrType {
+		shifted.Field = path
+		if decErr.Field != "" {
+			shifted.Field = jsonPathJoin(path, jsonFieldPath(t, decErr.Field))
+		}
+	}
+	return &shifted
+}
+
+// jsonPathJoin appends the JSON path rel, relative to the value at path,
+// to path.
+func jsonPathJoin(path, rel string) string {
+	if path == "" || strings.HasPrefix(rel, "[") {
+		return path + rel
+	}
+	return path + "." + rel
+}
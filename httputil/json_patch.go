@@ -0,0 +1,451 @@
+package httputil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// JSONPatchOperation is a single operation in a JSON Patch document as
+// defined in RFC 6902.
+type JSONPatchOperation struct {
+	// Op is the operation to perform. One of "add", "remove", "replace",
+	// "move", "copy" or "test".
+	Op string `json:"op"`
+
+	// Path is the JSON Pointer (RFC 6901) to the target location.
+	Path string `json:"path"`
+
+	// From is the JSON Pointer to the source location for the "move"
+	// and "copy" operations.
+	From string `json:"from,omitempty"`
+
+	// Value is the value for the "add", "replace" and "test"
+	// operations.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch is a JSON Patch document as defined in RFC 6902.
+//
+// Operations are applied in order and application stops at the first
+// failing operation. A malformed operation results in an error of kind
+// errors.InvalidInput. An operation which cannot be applied to the
+// document, such as a "test" operation which fails or a "remove"
+// operation on a nonexistent location, results in an error of kind
+// errors.Conflict.
+type JSONPatch []JSONPatchOperation
+
+// Apply applies the JSON Patch to the JSON document and returns the
+// result.
+func (p JSONPatch) Apply(doc []byte) ([]byte, error) {
+	const op = "JSONPatch.Apply"
+
+	d, err := unmarshalJSONDoc(doc)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("unmarshal document"), errors.WithErr(err))
+	}
+
+	if d, err = p.apply(d); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.Internal, errors.WithErr(err))
+	}
+
+	return b, nil
+}
+
+func (p JSONPatch) apply(doc interface{}) (interface{}, error) {
+	for i, o := range p {
+		var err error
+		if doc, err = o.apply(doc); err != nil {
+			return nil, errors.E(errors.WithData(map[string]interface{}{"index": i, "op": o.Op, "path": o.Path}), errors.WithErr(err))
+		}
+	}
+	return doc, nil
+}
+
+func (o JSONPatchOperation) apply(doc interface{}) (interface{}, error) {
+	path, err := parseJSONPointer(o.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o.Op {
+	case "add":
+		v, err := o.value()
+		if err != nil {
+			return nil, err
+		}
+		return addAt(doc, path, v)
+
+	case "remove":
+		doc, _, err = removeAt(doc, path)
+		return doc, err
+
+	case "replace":
+		v, err := o.value()
+		if err != nil {
+			return nil, err
+		}
+		if doc, _, err = removeAt(doc, path); err != nil {
+			return nil, err
+		}
+		return addAt(doc, path, v)
+
+	case "move":
+		from, err := parseJSONPointer(o.From)
+		if err != nil {
+			return nil, err
+		}
+		if o.From == o.Path {
+			return doc, nil
+		}
+		if strings.HasPrefix(o.Path, o.From+"/") {
+			msg := fmt.Sprintf("Patch cannot move '%s' into one of its children", o.From)
+			return nil, errors.E(errors.InvalidInput, errors.WithUserMsg(msg))
+		}
+
+		doc, v, err := removeAt(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addAt(doc, path, v)
+
+	case "copy":
+		from, err := parseJSONPointer(o.From)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := getAt(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addAt(doc, path, deepCopyJSON(v))
+
+	case "test":
+		want, err := o.value()
+		if err != nil {
+			return nil, err
+		}
+
+		got, err := getAt(doc, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !jsonEqual(got, want) {
+			msg := fmt.Sprintf("Patch test failed for the value at '%s'", o.Path)
+			return nil, errors.E(errors.Conflict, errors.WithUserMsg(msg))
+		}
+		return doc, nil
+	}
+
+	msg := fmt.Sprintf("Patch contains an unsupported operation '%s'", o.Op)
+	return nil, errors.E(errors.InvalidInput, errors.WithUserMsg(msg))
+}
+
+func (o JSONPatchOperation) value() (interface{}, error) {
+	if o.Value == nil {
+		msg := fmt.Sprintf("Patch operation '%s' is missing the value", o.Op)
+		return nil, errors.E(errors.InvalidInput, errors.WithUserMsg(msg))
+	}
+
+	v, err := unmarshalJSONDoc(o.Value)
+	if err != nil {
+		msg := fmt.Sprintf("Patch operation '%s' has an invalid value", o.Op)
+		return nil, errors.E(errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err))
+	}
+	return v, nil
+}
+
+// JSONPatchDecoder decodes the request body as a JSON Patch (RFC 6902)
+// and applies it to the value pointed to by v. It expects v to already
+// hold the current state of the resource.
+//
+// The patch is applied to the JSON representation of v and the result
+// is decoded into a new value. So fields which are not part of the JSON
+// representation, such as unexported fields or those tagged with
+// `json:"-"`, are reset to their zero value.
+//
+//	u, err := svc.User(ctx, id)
+//	if err != nil {
+//		// ...
+//	}
+//
+//	var dec httputil.JSONPatchDecoder
+//	if err := dec.Decode(r, &u); err != nil {
+//		responder.Error(r, w, err)
+//		return
+//	}
+type JSONPatchDecoder struct {
+	// SkipCheckContentType, if set to true, skips the check on
+	// value of Content-Type header being "application/json-patch+json".
+	SkipCheckContentType bool
+
+	// DisallowUnknownFields causes the Decoder to return an error when
+	// the patch adds object keys which do not match any non-ignored,
+	// exported fields in the destination.
+	DisallowUnknownFields bool
+}
+
+// Decode decodes the JSON Patch from the HTTP request and applies it to
+// the given value.
+func (j JSONPatchDecoder) Decode(r *http.Request, v interface{}) error {
+	const op = "JSONPatchDecoder.Decode"
+
+	if !j.SkipCheckContentType {
+		if err := checkMediaType(r, "application/json-patch+json"); err != nil {
+			return errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+	}
+
+	var patch JSONPatch
+	dec := JSONDecoder{SkipCheckContentType: true, ReportErrorLocation: true}
+	if err := dec.Decode(r, &patch); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	if err := patchValue(v, j.DisallowUnknownFields, patch.apply); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return nil
+}
+
+// parseJSONPointer parses the JSON Pointer (RFC 6901) into the list of
+// reference tokens.
+func parseJSONPointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+
+	if ptr[0] != '/' {
+		msg := fmt.Sprintf("Patch contains an invalid JSON Pointer '%s'", ptr)
+		return nil, errors.E(errors.InvalidInput, errors.WithUserMsg(msg))
+	}
+
+	tokens := strings.Split(ptr[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func getAt(doc interface{}, path []string) (interface{}, error) {
+	for i, tok := range path {
+		switch n := doc.(type) {
+		case map[string]interface{}:
+			v, ok := n[tok]
+			if !ok {
+				return nil, pathNotFoundErr(path[:i+1])
+			}
+			doc = v
+
+		case []interface{}:
+			idx, err := arrayIndex(tok, len(n)-1, path[:i+1])
+			if err != nil {
+				return nil, err
+			}
+			doc = n[idx]
+
+		default:
+			return nil, pathNotFoundErr(path[:i+1])
+		}
+	}
+	return doc, nil
+}
+
+// addAt adds the value at the path and returns the updated document.
+func addAt(doc interface{}, path []string, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+
+	parent, err := getAt(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	last := path[len(path)-1]
+	switch n := parent.(type) {
+	case map[string]interface{}:
+		n[last] = v
+		return doc, nil
+
+	case []interface{}:
+		idx := len(n)
+		if last != "-" {
+			if idx, err = arrayIndex(last, len(n), path); err != nil {
+				return nil, err
+			}
+		}
+
+		n = append(n, nil)
+		copy(n[idx+1:], n[idx:])
+		n[idx] = v
+		return replaceAt(doc, path[:len(path)-1], n), nil
+	}
+
+	return nil, pathNotFoundErr(path)
+}
+
+// removeAt removes the value at the path and returns the updated
+// document along with the removed value.
+func removeAt(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+
+	parent, err := getAt(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	last := path[len(path)-1]
+	switch n := parent.(type) {
+	case map[string]interface{}:
+		v, ok := n[last]
+		if !ok {
+			return nil, nil, pathNotFoundErr(path)
+		}
+		delete(n, last)
+		return doc, v, nil
+
+	case []interface{}:
+		idx, err := arrayIndex(last, len(n)-1, path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		v := n[idx]
+		n = append(n[:idx:idx], n[idx+1:]...)
+		return replaceAt(doc, path[:len(path)-1], n), v, nil
+	}
+
+	return nil, nil, pathNotFoundErr(path)
+}
+
+// replaceAt replaces the value at the path, which is known to exist, and
+// returns the updated document. It is needed since slices cannot be
+// updated in place when their length changes.
+func replaceAt(doc interface{}, path []string, v interface{}) interface{} {
+	if len(path) == 0 {
+		return v
+	}
+
+	parent, _ := getAt(doc, path[:len(path)-1])
+	last := path[len(path)-1]
+	switch n := parent.(type) {
+	case map[string]interface{}:
+		n[last] = v
+
+	case []interface{}:
+		idx, _ := strconv.Atoi(last)
+		n[idx] = v
+	}
+	return doc
+}
+
+// arrayIndex parses the reference token as an array index which must be
+// in the range [0, maxIdx].
+func arrayIndex(tok string, maxIdx int, path []string) (int, error) {
+	idx, err := strconv.Atoi(tok)
+	if err != nil || idx < 0 || (len(tok) > 1 && tok[0] == '0') || tok[0] == '+' {
+		msg := fmt.Sprintf("Patch contains an invalid array index '%s' (at '%s')", tok, formatJSONPointer(path))
+		return 0, errors.E(errors.InvalidInput, errors.WithUserMsg(msg))
+	}
+
+	if idx > maxIdx {
+		return 0, pathNotFoundErr(path)
+	}
+	return idx, nil
+}
+
+func pathNotFoundErr(path []string) error {
+	msg := fmt.Sprintf("Patch refers to the nonexistent location '%s'", formatJSONPointer(path))
+	return errors.E(errors.Conflict, errors.WithUserMsg(msg))
+}
+
+func formatJSONPointer(path []string) string {
+	var b strings.Builder
+	for _, tok := range path {
+		b.WriteByte('/')
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(tok, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
+
+func deepCopyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = deepCopyJSON(e)
+		}
+		return m
+
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = deepCopyJSON(e)
+		}
+		return s
+	}
+	return v
+}
+
+// jsonEqual compares the two JSON values as per the rules for the
+// "test" operation in RFC 6902 section 4.6. Numbers are considered
+// equal if their values are numerically equal.
+func jsonEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		}
+
+		af, err1 := a.Float64()
+		bf, err2 := b.Float64()
+		return err1 == nil && err2 == nil && af == bf
+
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !jsonEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,318 @@
+package httputil_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestJSONPatchApply(t *testing.T) {
+	// Test cases are mostly from RFC 6902 Appendix A.
+	cases := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "AddObjectMember",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			want:  `{"baz": "qux", "foo": "bar"}`,
+		},
+		{
+			name:  "AddArrayElement",
+			doc:   `{"foo": ["bar", "baz"]}`,
+			patch: `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			want:  `{"foo": ["bar", "qux", "baz"]}`,
+		},
+		{
+			name:  "AddArrayElementAtEnd",
+			doc:   `{"foo": ["bar"]}`,
+			patch: `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			want:  `{"foo": ["bar", ["abc", "def"]]}`,
+		},
+		{
+			name:  "AddNestedMember",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+			want:  `{"foo": "bar", "child": {"grandchild": {}}}`,
+		},
+		{
+			name:  "AddNullValue",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": null}]`,
+			want:  `{"foo": "bar", "baz": null}`,
+		},
+		{
+			name:  "RemoveObjectMember",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "remove", "path": "/baz"}]`,
+			want:  `{"foo": "bar"}`,
+		},
+		{
+			name:  "RemoveArrayElement",
+			doc:   `{"foo": ["bar", "qux", "baz"]}`,
+			patch: `[{"op": "remove", "path": "/foo/1"}]`,
+			want:  `{"foo": ["bar", "baz"]}`,
+		},
+		{
+			name:  "ReplaceValue",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			want:  `{"baz": "boo", "foo": "bar"}`,
+		},
+		{
+			name:  "ReplaceRoot",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "replace", "path": "", "value": [1]}]`,
+			want:  `[1]`,
+		},
+		{
+			name:  "MoveValue",
+			doc:   `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			patch: `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			want:  `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+		},
+		{
+			name:  "MoveArrayElement",
+			doc:   `{"foo": ["all", "grass", "cows", "eat"]}`,
+			patch: `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			want:  `{"foo": ["all", "cows", "eat", "grass"]}`,
+		},
+		{
+			name:  "CopyValue",
+			doc:   `{"foo": {"bar": [1, 2]}}`,
+			patch: `[{"op": "copy", "from": "/foo/bar", "path": "/baz"}, {"op": "add", "path": "/baz/-", "value": 3}]`,
+			want:  `{"foo": {"bar": [1, 2]}, "baz": [1, 2, 3]}`,
+		},
+		{
+			name:  "TestSuccess",
+			doc:   `{"baz": "qux", "foo": ["a", 2, "c"], "n": 10}`,
+			patch: `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}, {"op": "test", "path": "/n", "value": 1e1}]`,
+			want:  `{"baz": "qux", "foo": ["a", 2, "c"], "n": 10}`,
+		},
+		{
+			name:  "EscapedPointer",
+			doc:   `{"/": 9, "~1": 10}`,
+			patch: `[{"op": "test", "path": "/~01", "value": 10}, {"op": "remove", "path": "/~1"}]`,
+			want:  `{"~1": 10}`,
+		},
+		{
+			name:  "TestFailure",
+			doc:   `{"baz": "qux"}`,
+			patch: `[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			wantErr: errors.E(
+				errors.WithOp("JSONPatch.Apply"),
+				errors.Conflict,
+				errors.WithUserMsg("Patch test failed for the value at '/baz'"),
+				errors.WithData(map[string]interface{}{"index": 0, "op": "test", "path": "/baz"}),
+			),
+		},
+		{
+			name:  "AddToNonexistentTarget",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			wantErr: errors.E(
+				errors.WithOp("JSONPatch.Apply"),
+				errors.Conflict,
+				errors.WithUserMsg("Patch refers to the nonexistent location '/baz'"),
+			),
+		},
+		{
+			name:  "RemoveNonexistentTarget",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/a", "value": 1}, {"op": "remove", "path": "/baz"}]`,
+			wantErr: errors.E(
+				errors.WithOp("JSONPatch.Apply"),
+				errors.Conflict,
+				errors.WithUserMsg("Patch refers to the nonexistent location '/baz'"),
+				errors.WithData(map[string]interface{}{"index": 1, "op": "remove", "path": "/baz"}),
+			),
+		},
+		{
+			name:  "ArrayIndexOutOfBounds",
+			doc:   `{"foo": ["bar"]}`,
+			patch: `[{"op": "add", "path": "/foo/2", "value": "qux"}]`,
+			wantErr: errors.E(
+				errors.WithOp("JSONPatch.Apply"),
+				errors.Conflict,
+				errors.WithUserMsg("Patch refers to the nonexistent location '/foo/2'"),
+			),
+		},
+		{
+			name:  "InvalidArrayIndex",
+			doc:   `{"foo": ["bar"]}`,
+			patch: `[{"op": "remove", "path": "/foo/01"}]`,
+			wantErr: errors.E(
+				errors.WithOp("JSONPatch.Apply"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Patch contains an invalid array index '01' (at '/foo/01')"),
+			),
+		},
+		{
+			name:  "MoveIntoChild",
+			doc:   `{"foo": {"bar": 1}}`,
+			patch: `[{"op": "move", "from": "/foo", "path": "/foo/bar/baz"}]`,
+			wantErr: errors.E(
+				errors.WithOp("JSONPatch.Apply"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Patch cannot move '/foo' into one of its children"),
+			),
+		},
+		{
+			name:  "MissingValue",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz"}]`,
+			wantErr: errors.E(
+				errors.WithOp("JSONPatch.Apply"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Patch operation 'add' is missing the value"),
+			),
+		},
+		{
+			name:  "InvalidPointer",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "remove", "path": "foo"}]`,
+			wantErr: errors.E(
+				errors.WithOp("JSONPatch.Apply"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Patch contains an invalid JSON Pointer 'foo'"),
+			),
+		},
+		{
+			name:  "UnsupportedOperation",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "merge", "path": "/foo", "value": "baz"}]`,
+			wantErr: errors.E(
+				errors.WithOp("JSONPatch.Apply"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Patch contains an unsupported operation 'merge'"),
+			),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var p httputil.JSONPatch
+			if err := json.Unmarshal([]byte(tc.patch), &p); err != nil {
+				t.Fatalf("json.Unmarshal: %s", err)
+			}
+
+			got, err := p.Apply([]byte(tc.doc))
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("JSONPatch.Apply() error diff: %s", errorDiff(tc.wantErr, err))
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			ok, err := jsonBytesEqual(got, []byte(tc.want))
+			if err != nil {
+				t.Fatalf("jsonBytesEqual()=%q", err)
+			}
+			if !ok {
+				t.Errorf("JSONPatch.Apply()=%s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJSONPatchDecoderDecode(t *testing.T) {
+	type User struct {
+		Name  string   `json:"name"`
+		Email string   `json:"email,omitempty"`
+		Tags  []string `json:"tags"`
+	}
+	jsonPatchCT := map[string]string{"Content-Type": "application/json-patch+json"}
+
+	cases := []struct {
+		name    string
+		d       httputil.JSONPatchDecoder
+		r       request
+		want    User
+		wantErr error
+	}{
+		{
+			name: "Success",
+			r: request{
+				method:  http.MethodPatch,
+				url:     "http://host.com/users/1",
+				headers: jsonPatchCT,
+				body: `[
+					{"op": "test", "path": "/name", "value": "Donald"},
+					{"op": "replace", "path": "/name", "value": "Puddy"},
+					{"op": "remove", "path": "/email"},
+					{"op": "add", "path": "/tags/0", "value": "z"}
+				]`,
+			},
+			want: User{Name: "Puddy", Tags: []string{"z", "a"}},
+		},
+		{
+			name: "ContentTypeNotAccepted",
+			r: request{
+				method:  http.MethodPatch,
+				url:     "http://host.com/users/1",
+				headers: map[string]string{"Content-Type": "application/merge-patch+json"},
+				body:    `[]`,
+			},
+			wantErr: errors.E(
+				errors.WithOp("JSONPatchDecoder.Decode"),
+				httputil.ErrKindUnsupportedMediaType,
+				errors.WithText("Content-Type header 'application/merge-patch+json' is not application/json-patch+json"),
+			),
+		},
+		{
+			name: "TestFailure",
+			r: request{
+				method:  http.MethodPatch,
+				url:     "http://host.com/users/1",
+				headers: jsonPatchCT,
+				body:    `[{"op": "test", "path": "/name", "value": "Kramer"}]`,
+			},
+			wantErr: errors.E(
+				errors.WithOp("JSONPatchDecoder.Decode"),
+				errors.Conflict,
+				errors.WithUserMsg("Patch test failed for the value at '/name'"),
+			),
+		},
+		{
+			name: "InvalidValue",
+			r: request{
+				method:  http.MethodPatch,
+				url:     "http://host.com/users/1",
+				headers: jsonPatchCT,
+				body:    `[{"op": "add", "path": "/tags/-", "value": 1}]`,
+			},
+			wantErr: errors.E(
+				errors.WithOp("JSONPatchDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Patch results in an invalid value for the 'tags[1]' field"),
+			),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := tc.r.build()
+			if err != nil {
+				t.Fatalf("http.NewRequest: %s", err)
+			}
+
+			u := User{Name: "Donald", Email: "donald@example.com", Tags: []string{"a"}}
+			err = tc.d.Decode(r, &u)
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("JSONPatchDecoder.Decode() error diff: %s", errorDiff(tc.wantErr, err))
+				return
+			}
+
+			if err == nil && !reflect.DeepEqual(u, tc.want) {
+				t.Errorf("\nJSONPatchDecoder.Decode()=%#v \nwant %#v", u, tc.want)
+			}
+		})
+	}
+}
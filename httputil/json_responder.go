@@ -1,14 +1,20 @@
 package httputil
 
 import (
-	"encoding/json"
+	"bytes"
 	"net/http"
 	"reflect"
+	"strconv"
+	"sync"
 
 	"github.com/sudo-suhas/xgo"
 	"github.com/sudo-suhas/xgo/errors"
 )
 
+// contentTypeJSON is the value of the Content-Type header for JSON
+// responses.
+const contentTypeJSON = "application/json; charset=utf-8"
+
 // ErrorObserverFunc takes some action when an error occurs during
 // request processing.
 //
@@ -31,6 +37,23 @@ type JSONResponder struct {
 	// ErrObservers are notified of errors for responses sent via
 	// JSONResponder.Error and JSONResponder.ErrorWithStatus.
 	ErrObservers []ErrorObserverFunc
+
+	// Codec is used to encode the response body. StdJSONCodec is used
+	// if nil. Optional.
+	Codec JSONCodec
+
+	// Buffered, if set to true, causes the response body to be encoded
+	// into a buffer before writing the response. This makes it possible
+	// to set the Content-Length header and to respond with the error
+	// response for an errors.Internal error if encoding fails, instead
+	// of a truncated body with the original status.
+	Buffered bool
+
+	// ETag enables the generation of the ETag header from the encoded
+	// response body for successful responses. The ETag is not generated
+	// if the value implements ETagger. The response body is buffered if
+	// enabled. Defaults to NoETag.
+	ETag ETagMode
 }
 
 // Respond encodes v as JSON and writes the response with status
@@ -43,22 +66,85 @@ func (jr *JSONResponder) Respond(r *http.Request, w http.ResponseWriter, v inter
 // RespondWithStatus encodes the value as JSON and writes the response
 // with the specified status code. Only HTTP status is written as the
 // response if v is nil. Furthermore, interface upgrade to xgo.JSON is
-// supported for v.
+// supported for v. The response body is omitted for HEAD requests.
+//
+// For successful responses, the ETag and Last-Modified headers are set
+// if v implements ETagger and LastModifier respectively. If either of
+// these headers is set, the conditional request headers of GET and HEAD
+// requests are evaluated. The response is then written with the status
+// '304: Not Modified' or the error response for an error of kind
+// errors.FailedPrecondition as appropriate.
 func (jr *JSONResponder) RespondWithStatus(r *http.Request, w http.ResponseWriter, status int, v interface{}) {
 	if v == nil {
 		w.WriteHeader(status)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if status >= 200 && status <= 299 {
+		setValidators(w.Header(), v)
+	}
+
+	body := jsonBody(v)
+
+	if jr.Buffered || jr.ETag != NoETag || hasValidators(w.Header()) {
+		jr.respondBuffered(r, w, status, body, true)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
 	w.WriteHeader(status)
 
-	body := v
-	if j, ok := v.(xgo.JSONer); ok {
-		body = j.JSON()
+	if isHeadRequest(r) {
+		return
 	}
 
-	if err := json.NewEncoder(w).Encode(body); err != nil {
+	if err := jsonCodecOrDefault(jr.Codec).NewEncoder(w).Encode(body); err != nil {
+		jr.observeError(r, err)
+	}
+}
+
+// respondBuffered encodes the body into a buffer before writing the
+// response. If encoding fails and fallback is true, the error response
+// for an errors.Internal error is written instead.
+func (jr *JSONResponder) respondBuffered(r *http.Request, w http.ResponseWriter, status int, body interface{}, fallback bool) {
+	const op = "JSONResponder.RespondWithStatus"
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := jsonCodecOrDefault(jr.Codec).NewEncoder(buf).Encode(body); err != nil {
+		err = errors.E(errors.WithOp(op), errors.Internal, errors.WithText("encode response body"), errors.WithErr(err))
+		jr.observeError(r, err)
+
+		// The validators describe the body which could not be encoded,
+		// not the error response.
+		w.Header().Del("ETag")
+		w.Header().Del("Last-Modified")
+
+		if !fallback {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// Avoid an endless loop if the error response cannot be encoded
+		// either.
+		jr.respondBuffered(r, w, http.StatusInternalServerError, jr.convertErrorToBody(err), false)
+		return
+	}
+
+	if fallback && jr.respondConditional(r, w, status, buf.Bytes()) {
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+
+	if isHeadRequest(r) {
+		return
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
 		jr.observeError(r, err)
 	}
 }
@@ -79,6 +165,19 @@ func (jr *JSONResponder) ErrorWithStatus(r *http.Request, w http.ResponseWriter,
 	jr.RespondWithStatus(r, w, status, jr.convertErrorToBody(err))
 }
 
+// jsonBody returns the value to be encoded as JSON for v. Interface
+// upgrade to xgo.JSONer is supported.
+func jsonBody(v interface{}) interface{} {
+	if j, ok := v.(xgo.JSONer); ok {
+		return j.JSON()
+	}
+	return v
+}
+
+func isHeadRequest(r *http.Request) bool {
+	return r != nil && r.Method == http.MethodHead
+}
+
 func (jr *JSONResponder) observeError(r *http.Request, err error) {
 	for _, f := range jr.ErrObservers {
 		f(r, err)
@@ -111,3 +210,25 @@ func (jr *JSONResponder) convertErrorToBody(err error) interface{} {
 
 	return body
 }
+
+// maxPooledBufferSize is the capacity above which buffers are not
+// returned to the pool so that a single large response does not pin the
+// memory.
+const maxPooledBufferSize = 64 << 10 // 64 KiB
+
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+
+	buf.Reset()
+	bufferPool.Put(buf)
+}
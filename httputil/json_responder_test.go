@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/sudo-suhas/xgo/errors"
 	"github.com/sudo-suhas/xgo/httputil"
@@ -91,6 +92,113 @@ func TestJSONResponderRespondWithStatus(t *testing.T) {
 	})
 }
 
+func TestJSONResponderBuffered(t *testing.T) {
+	cases := []struct {
+		name          string
+		errToRespBody func(error) interface{}
+		method        string
+		v             interface{}
+		want          response
+		wantErrCnt    int
+	}{
+		{
+			name:   "WithValue",
+			method: http.MethodGet,
+			v:      Person{Name: "Donald", Age: 33},
+			want: response{
+				status: http.StatusCreated,
+				headers: map[string]string{
+					"Content-Type":   "application/json; charset=utf-8",
+					"Content-Length": "36",
+				},
+				body: []byte(`{"Name": "Donald", "Age": 33, "V": null}`),
+			},
+		},
+		{
+			name:   "HeadRequest",
+			method: http.MethodHead,
+			v:      Person{Name: "Donald", Age: 33},
+			want: response{
+				status: http.StatusCreated,
+				headers: map[string]string{
+					"Content-Type":   "application/json; charset=utf-8",
+					"Content-Length": "36",
+				},
+			},
+		},
+		{
+			name:   "EncodeError",
+			method: http.MethodGet,
+			v:      marshalFailer{err: errors.E(errors.WithOp("marshal"), errors.WithText("fail"))},
+			want: response{
+				status: http.StatusInternalServerError,
+				headers: map[string]string{
+					"Content-Type":   "application/json; charset=utf-8",
+					"Content-Length": "92",
+				},
+				body: []byte(`{"success":false,"msg":"","errors":[{"code":"INTERNAL","error":"internal error","msg":""}]}`),
+			},
+			wantErrCnt: 1,
+		},
+		{
+			name:   "EncodeErrorWithValidators",
+			method: http.MethodGet,
+			v: versionedMarshalFailer{
+				marshalFailer: marshalFailer{err: errors.E(errors.WithOp("marshal"), errors.WithText("fail"))},
+				updated:       time.Date(2021, 3, 31, 10, 0, 0, 0, time.UTC),
+			},
+			want: response{
+				status: http.StatusInternalServerError,
+				headers: map[string]string{
+					"Content-Type":   "application/json; charset=utf-8",
+					"Content-Length": "92",
+				},
+				body: []byte(`{"success":false,"msg":"","errors":[{"code":"INTERNAL","error":"internal error","msg":""}]}`),
+			},
+			wantErrCnt: 1,
+		},
+		{
+			name:          "ErrorBodyEncodeError",
+			errToRespBody: func(error) interface{} { return make(chan int) },
+			method:        http.MethodGet,
+			v:             make(chan int),
+			want:          response{status: http.StatusInternalServerError},
+			wantErrCnt:    2,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var errs []error
+			jr := httputil.JSONResponder{
+				ErrToRespBody: tc.errToRespBody,
+				ErrObservers: []httputil.ErrorObserverFunc{
+					func(_ *http.Request, err error) { errs = append(errs, err) },
+				},
+				Buffered: true,
+			}
+
+			r := httptest.NewRequest(tc.method, "http://host.com/route", nil)
+			rec := httptest.NewRecorder()
+			jr.RespondWithStatus(r, rec, http.StatusCreated, tc.v)
+
+			matchResponse(t, rec.Result(), tc.want)
+
+			if len(errs) != tc.wantErrCnt {
+				t.Fatalf("ErrObserver call count=%d; want %d", len(errs), tc.wantErrCnt)
+			}
+
+			want := errors.E(
+				errors.WithOp("JSONResponder.RespondWithStatus"), errors.Internal, errors.WithText("encode response body"),
+			)
+			for _, err := range errs {
+				if !errors.Match(want, err) {
+					t.Errorf("ErrorObserver.err diff= %s", errorDiff(want, err))
+				}
+			}
+		})
+	}
+}
+
 func TestJSONResponderError(t *testing.T) {
 	cases := []struct {
 		name string
@@ -259,3 +367,12 @@ func (p personJSONer) JSON() interface{} {
 type marshalFailer struct{ err error }
 
 func (m marshalFailer) MarshalJSON() ([]byte, error) { return nil, m.err }
+
+type versionedMarshalFailer struct {
+	marshalFailer
+	updated time.Time
+}
+
+func (versionedMarshalFailer) ETag() string { return "v1" }
+
+func (v versionedMarshalFailer) LastModified() time.Time { return v.updated }
@@ -0,0 +1,118 @@
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// MergePatchDecoder decodes the request body as a JSON Merge Patch
+// (RFC 7396) and applies it to the value pointed to by v. It expects v
+// to already hold the current state of the resource.
+//
+// Unlike JSONDecoder, a merge patch distinguishes between an absent
+// field, which is left unchanged, and a field set to null, which is
+// removed, ie reset to its zero value.
+//
+// The patch is applied to the JSON representation of v and the result
+// is decoded into a new value. So fields which are not part of the JSON
+// representation, such as unexported fields or those tagged with
+// `json:"-"`, are reset to their zero value.
+//
+//	u, err := svc.User(ctx, id)
+//	if err != nil {
+//		// ...
+//	}
+//
+//	var dec httputil.MergePatchDecoder
+//	if err := dec.Decode(r, &u); err != nil {
+//		responder.Error(r, w, err)
+//		return
+//	}
+type MergePatchDecoder struct {
+	// SkipCheckContentType, if set to true, skips the check on
+	// value of Content-Type header being
+	// "application/merge-patch+json".
+	SkipCheckContentType bool
+
+	// DisallowUnknownFields causes the Decoder to return an error when
+	// the patch adds object keys which do not match any non-ignored,
+	// exported fields in the destination.
+	DisallowUnknownFields bool
+}
+
+// Decode decodes the merge patch from the HTTP request and applies it
+// to the given value.
+func (m MergePatchDecoder) Decode(r *http.Request, v interface{}) error {
+	const op = "MergePatchDecoder.Decode"
+
+	if !m.SkipCheckContentType {
+		if err := checkMediaType(r, "application/merge-patch+json"); err != nil {
+			return errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+	}
+
+	var patch interface{}
+	dec := JSONDecoder{SkipCheckContentType: true, UseNumber: true, ReportErrorLocation: true}
+	if err := dec.Decode(r, &patch); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	err := patchValue(v, m.DisallowUnknownFields, func(doc interface{}) (interface{}, error) {
+		return mergePatch(doc, patch), nil
+	})
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return nil
+}
+
+// MergePatch applies the JSON Merge Patch (RFC 7396) to the JSON
+// document and returns the result.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	const op = "httputil.MergePatch"
+
+	d, err := unmarshalJSONDoc(doc)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("unmarshal document"), errors.WithErr(err))
+	}
+
+	p, err := unmarshalJSONDoc(patch)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("unmarshal patch"), errors.WithErr(err))
+	}
+
+	b, err := json.Marshal(mergePatch(d, p))
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.Internal, errors.WithErr(err))
+	}
+
+	return b, nil
+}
+
+// mergePatch implements the MergePatch function described in RFC 7396
+// section 2.
+func mergePatch(target, patch interface{}) interface{} {
+	pm, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	tm, ok := target.(map[string]interface{})
+	if !ok {
+		tm = make(map[string]interface{}, len(pm))
+	}
+
+	for k, v := range pm {
+		if v == nil {
+			delete(tm, k)
+			continue
+		}
+
+		tm[k] = mergePatch(tm[k], v)
+	}
+
+	return tm
+}
@@ -0,0 +1,185 @@
+package httputil_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Test cases from RFC 7396 Appendix A.
+	cases := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.patch, func(t *testing.T) {
+			got, err := httputil.MergePatch([]byte(tc.doc), []byte(tc.patch))
+			if err != nil {
+				t.Fatalf("MergePatch() error: %s", err)
+			}
+
+			ok, err := jsonBytesEqual(got, []byte(tc.want))
+			if err != nil {
+				t.Fatalf("jsonBytesEqual()=%q", err)
+			}
+			if !ok {
+				t.Errorf("MergePatch(%s, %s)=%s; want %s", tc.doc, tc.patch, got, tc.want)
+			}
+		})
+	}
+
+	t.Run("InvalidPatch", func(t *testing.T) {
+		_, err := httputil.MergePatch([]byte(`{}`), []byte(`{"a":`))
+		want := errors.E(errors.WithOp("httputil.MergePatch"), errors.InvalidInput)
+		if !errors.Match(want, err) {
+			t.Errorf("MergePatch() error diff: %s", errorDiff(want, err))
+		}
+	})
+}
+
+func TestMergePatchDecoderDecode(t *testing.T) {
+	type Address struct {
+		City string `json:"city"`
+		Zip  string `json:"zip,omitempty"`
+	}
+	type User struct {
+		Name    string   `json:"name"`
+		Age     int      `json:"age"`
+		Tags    []string `json:"tags"`
+		Address *Address `json:"address"`
+	}
+	current := func() User {
+		return User{
+			Name:    "Donald",
+			Age:     33,
+			Tags:    []string{"a", "b"},
+			Address: &Address{City: "Paris", Zip: "75001"},
+		}
+	}
+	mergePatchCT := map[string]string{"Content-Type": "application/merge-patch+json"}
+
+	cases := []struct {
+		name    string
+		d       httputil.MergePatchDecoder
+		r       request
+		want    User
+		wantErr error
+	}{
+		{
+			name: "Success",
+			r: request{
+				method:  http.MethodPatch,
+				url:     "http://host.com/users/1",
+				headers: mergePatchCT,
+				body:    `{"age": 34, "tags": null, "address": {"zip": null}}`,
+			},
+			want: User{Name: "Donald", Age: 34, Address: &Address{City: "Paris"}},
+		},
+		{
+			name: "SuccessWithSkipCheckContentType",
+			d:    httputil.MergePatchDecoder{SkipCheckContentType: true},
+			r: request{
+				method:  http.MethodPatch,
+				url:     "http://host.com/users/1",
+				headers: map[string]string{"Content-Type": "application/json"},
+				body:    `{"name": "Puddy"}`,
+			},
+			want: User{Name: "Puddy", Age: 33, Tags: []string{"a", "b"}, Address: &Address{City: "Paris", Zip: "75001"}},
+		},
+		{
+			name: "ContentTypeNotAccepted",
+			r: request{
+				method:  http.MethodPatch,
+				url:     "http://host.com/users/1",
+				headers: map[string]string{"Content-Type": "application/json"},
+				body:    `{"age": 34}`,
+			},
+			wantErr: errors.E(
+				errors.WithOp("MergePatchDecoder.Decode"),
+				httputil.ErrKindUnsupportedMediaType,
+				errors.WithText("Content-Type header 'application/json' is not application/merge-patch+json"),
+			),
+		},
+		{
+			name: "BadlyFormedPatch",
+			r: request{
+				method:  http.MethodPatch,
+				url:     "http://host.com/users/1",
+				headers: mergePatchCT,
+				body:    `{"age": }`,
+			},
+			wantErr: errors.E(
+				errors.WithOp("MergePatchDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains badly-formed JSON (at line 1, column 9)"),
+			),
+		},
+		{
+			name: "InvalidValue",
+			r: request{
+				method:  http.MethodPatch,
+				url:     "http://host.com/users/1",
+				headers: mergePatchCT,
+				body:    `{"address": {"city": 75}}`,
+			},
+			wantErr: errors.E(
+				errors.WithOp("MergePatchDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Patch results in an invalid value for the 'address.city' field"),
+			),
+		},
+		{
+			name: "UnknownField",
+			d:    httputil.MergePatchDecoder{DisallowUnknownFields: true},
+			r: request{
+				method:  http.MethodPatch,
+				url:     "http://host.com/users/1",
+				headers: mergePatchCT,
+				body:    `{"height": 175}`,
+			},
+			wantErr: errors.E(
+				errors.WithOp("MergePatchDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Patch results in unknown field 'height'"),
+			),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := tc.r.build()
+			if err != nil {
+				t.Fatalf("http.NewRequest: %s", err)
+			}
+
+			u := current()
+			err = tc.d.Decode(r, &u)
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("MergePatchDecoder.Decode() error diff: %s", errorDiff(tc.wantErr, err))
+				return
+			}
+
+			if err == nil && !reflect.DeepEqual(u, tc.want) {
+				t.Errorf("\nMergePatchDecoder.Decode()=%#v \nwant %#v", u, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package httputil
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// maxMultipartValueBytes is the limit on the size of a single non-file
+// part in the multipart request body.
+const maxMultipartValueBytes = 10 << 20 // 10 MiB
+
+// sniffLen is the number of bytes used by http.DetectContentType.
+const sniffLen = 512
+
+// MultipartFile is a file part of a multipart/form-data request body. It
+// is a streaming reader over the contents of the file and is only valid
+// until the MultipartFileHandler returns.
+type MultipartFile struct {
+	io.Reader
+
+	// FieldName is the name of the form field.
+	FieldName string
+
+	// FileName is the file name as specified by the client. It must not
+	// be trusted to be a valid or safe file name.
+	FileName string
+
+	// ContentType is the content type of the file as determined by
+	// http.DetectContentType.
+	ContentType string
+
+	// Header is the MIME header of the part.
+	Header textproto.MIMEHeader
+}
+
+// MultipartFileHandler is implemented by destination values which
+// handle the files in a multipart/form-data request body. The file
+// must be consumed, ie streamed to its destination, before returning
+// from HandleMultipartFile.
+type MultipartFileHandler interface {
+	HandleMultipartFile(f *MultipartFile) error
+}
+
+// MultipartDecoder decodes the multipart/form-data request body into
+// the given value which must be a pointer to a struct.
+//
+// Form values are mapped to the fields of the struct using the "form"
+// struct tag. The field name is used if the tag is absent and fields
+// with the tag "-" are ignored. Strings, booleans, numbers, types
+// implementing encoding.TextUnmarshaler, and pointers and slices of
+// these are supported. Slices collect repeated values.
+//
+// The request body is read as a stream and files are not buffered in
+// memory or on disk. Instead, each file is passed to the destination
+// value, which must implement MultipartFileHandler, as it is
+// encountered. Form values which appear after a file part are not
+// available when the file is handled.
+//
+//	type UploadRequest struct {
+//		Title string `form:"title"`
+//
+//		keys []string
+//		store myapp.BlobStore
+//	}
+//
+//	func (u *UploadRequest) HandleMultipartFile(f *httputil.MultipartFile) error {
+//		key, err := u.store.Put(ctx, f.FileName, f.ContentType, f)
+//		if err != nil {
+//			return err
+//		}
+//
+//		u.keys = append(u.keys, key)
+//		return nil
+//	}
+type MultipartDecoder struct {
+	// MaxBytes limits the size of the request body. If the limit is
+	// exceeded, an error of kind ErrKindRequestEntityTooLarge is
+	// returned. No limit is applied if MaxBytes is zero or negative.
+	MaxBytes int64
+
+	// MaxFileBytes limits the size of each file. If the limit is
+	// exceeded, reading the file fails and an error of kind
+	// ErrKindRequestEntityTooLarge is returned. No limit is applied if
+	// MaxFileBytes is zero or negative.
+	MaxFileBytes int64
+
+	// AllowedContentTypes is the list of content types allowed for the
+	// files. The content type is determined by sniffing the first 512
+	// bytes of the file using http.DetectContentType. The content type
+	// supplied by the client is ignored. A wildcard subtype, such as
+	// "image/*", matches all the subtypes. If the content type of a file
+	// is not allowed, an error of kind ErrKindUnsupportedMediaType is
+	// returned. All content types are allowed if empty.
+	AllowedContentTypes []string
+
+	// DisallowUnknownFields causes the Decoder to return an error when
+	// the request body contains form values which do not match any
+	// non-ignored, exported fields in the destination.
+	DisallowUnknownFields bool
+}
+
+// Decode decodes the HTTP request into the given value.
+func (m MultipartDecoder) Decode(r *http.Request, v interface{}) error {
+	const op = "MultipartDecoder.Decode"
+
+	body := limitBody(r.Body, m.MaxBytes)
+	defer drain(body)
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.E(
+			errors.WithOp(op), errors.Internal, errors.WithTextf("destination must be a non-nil pointer to a struct, got %T", v),
+		)
+	}
+
+	boundary, err := multipartBoundary(r)
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	fields := formFields(rv.Elem().Type())
+	mr := multipart.NewReader(body, boundary)
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.E(errors.WithOp(op), errors.WithErr(multipartErr(err)))
+		}
+
+		if p.FileName() != "" {
+			err = m.handleFile(p, v)
+		} else {
+			err = m.setValue(p, rv.Elem(), fields)
+		}
+		if err != nil {
+			return errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+	}
+}
+
+func (m MultipartDecoder) handleFile(p *multipart.Part, v interface{}) error {
+	h, ok := v.(MultipartFileHandler)
+	if !ok {
+		msg := fmt.Sprintf("Request body contains unexpected file for field '%s'", p.FormName())
+		return errors.E(errors.InvalidInput, errors.WithUserMsg(msg))
+	}
+
+	fr := &fileReader{r: p, name: p.FormName(), limit: m.MaxFileBytes}
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(fr, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return multipartErr(err)
+	}
+
+	ct := http.DetectContentType(buf[:n])
+	if !m.allowedContentType(ct) {
+		msg := fmt.Sprintf("File for field '%s' has unsupported content type '%s'", p.FormName(), ct)
+		return errors.E(ErrKindUnsupportedMediaType, errors.WithUserMsg(msg))
+	}
+
+	err = h.HandleMultipartFile(&MultipartFile{
+		Reader:      io.MultiReader(bytes.NewReader(buf[:n]), fr),
+		FieldName:   p.FormName(),
+		FileName:    p.FileName(),
+		ContentType: ct,
+		Header:      p.Header,
+	})
+	// The handler might not return the error from reading the file as is.
+	// Errors from reading the file take precedence since they explain the
+	// failure.
+	if fr.err != nil {
+		return multipartErr(fr.err)
+	}
+
+	return err
+}
+
+func (m MultipartDecoder) allowedContentType(ct string) bool {
+	if len(m.AllowedContentTypes) == 0 {
+		return true
+	}
+
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+
+	return matchContentType(mt, m.AllowedContentTypes)
+}
+
+func (m MultipartDecoder) setValue(p *multipart.Part, sv reflect.Value, fields map[string][]int) error {
+	name := p.FormName()
+	idx, ok := fields[name]
+	if !ok {
+		if m.DisallowUnknownFields {
+			msg := fmt.Sprintf("Request body contains unknown field '%s'", name)
+			return errors.E(errors.InvalidInput, errors.WithUserMsg(msg))
+		}
+		return nil
+	}
+
+	b, err := io.ReadAll(io.LimitReader(p, maxMultipartValueBytes+1))
+	if err != nil {
+		return multipartErr(err)
+	}
+	if len(b) > maxMultipartValueBytes {
+		msg := fmt.Sprintf("Value for field '%s' is too large", name)
+		return errors.E(ErrKindRequestEntityTooLarge, errors.WithUserMsg(msg))
+	}
+
+	if err := setFormValue(fieldByIndex(sv, idx), string(b)); err != nil {
+		msg := fmt.Sprintf("Request body contains an invalid value for the '%s' field", name)
+		return errors.E(errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err))
+	}
+
+	return nil
+}
+
+// multipartBoundary checks that the Content-Type header of the request
+// is multipart/form-data and returns the boundary.
+func multipartBoundary(r *http.Request) (string, error) {
+	ct := r.Header.Get("Content-Type")
+	mt, params, err := mime.ParseMediaType(ct)
+	if err != nil || mt != "multipart/form-data" || params["boundary"] == "" {
+		return "", errors.E(
+			ErrKindUnsupportedMediaType,
+			errors.WithTextf("Content-Type header '%s' is not multipart/form-data", ct),
+		)
+	}
+
+	return params["boundary"], nil
+}
+
+// multipartErr translates the error encountered while reading the
+// multipart request body into an *errors.Error.
+func multipartErr(err error) error {
+	switch {
+	case errors.WhatKind(err) != errors.Unknown:
+		return err
+
+	// There is an open issue regarding turning this into a sentinel
+	// error at https://github.com/golang/go/issues/30715.
+	case err.Error() == "http: request body too large":
+		return errors.E(ErrKindRequestEntityTooLarge, errors.WithErr(err))
+	}
+
+	msg := "Request body contains badly-formed multipart data"
+	return errors.E(errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err))
+}
+
+// fileReader enforces the size limit on the file and records the first
+// error encountered while reading it.
+type fileReader struct {
+	r     io.Reader
+	name  string
+	limit int64
+	n     int64
+	err   error
+}
+
+func (f *fileReader) Read(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+
+	if f.limit > 0 && int64(len(p)) > f.limit-f.n+1 {
+		// Read at most one byte past the limit to detect that it was
+		// exceeded.
+		p = p[:f.limit-f.n+1]
+	}
+
+	n, err := f.r.Read(p)
+	f.n += int64(n)
+	if f.limit > 0 && f.n > f.limit {
+		msg := fmt.Sprintf("File for field '%s' exceeds the limit of %d bytes", f.name, f.limit)
+		f.err = errors.E(ErrKindRequestEntityTooLarge, errors.WithUserMsg(msg))
+		return n - int(f.n-f.limit), f.err
+	}
+
+	if err != nil && err != io.EOF {
+		f.err = multipartErr(err)
+		return n, f.err
+	}
+
+	return n, err
+}
+
+// formFields returns the index of the struct fields keyed by the form
+// field name.
+func formFields(t reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() || (sf.Anonymous && sf.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		name := sf.Tag.Get("form")
+		if name == "-" {
+			continue
+		}
+		if idx := strings.Index(name, ","); idx != -1 {
+			name = name[:idx]
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		if _, ok := fields[name]; !ok {
+			fields[name] = sf.Index
+		}
+	}
+	return fields
+}
+
+// fieldByIndex returns the nested field of the struct corresponding to
+// index. Unlike reflect.Value.FieldByIndex, nil pointers to embedded
+// structs are allocated.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// setFormValue sets the value parsed from the text s on v.
+func setFormValue(v reflect.Value, s string) error {
+	// Set the value of an Optional directly so that the same parsing
+	// rules apply to it.
+	if reflect.PtrTo(v.Type()).Implements(decodeTargeterType) {
+		target := v.Addr().Interface().(decodeTargeter).DecodeTarget()
+		return setFormValue(reflect.ValueOf(target).Elem(), s)
+	}
+
+	if reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := setFormValue(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+
+	case reflect.Slice:
+		ev := reflect.New(v.Type().Elem()).Elem()
+		if err := setFormValue(ev, s); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, ev))
+
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+
+	return nil
+}
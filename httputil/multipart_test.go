@@ -0,0 +1,323 @@
+package httputil_test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sudo-suhas/xgo"
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+const pngHeader = "\x89PNG\x0D\x0A\x1A\x0A"
+
+type uploadFile struct {
+	FieldName, FileName, ContentType, Content string
+}
+
+type uploadRequest struct {
+	Title    string               `form:"title"`
+	Tags     []string             `form:"tag"`
+	Count    *int                 `form:"count"`
+	Caption  xgo.Optional[string] `form:"caption"`
+	Internal string               `form:"-"`
+
+	files   []uploadFile
+	failErr error
+}
+
+func (u *uploadRequest) HandleMultipartFile(f *httputil.MultipartFile) error {
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	u.files = append(u.files, uploadFile{
+		FieldName:   f.FieldName,
+		FileName:    f.FileName,
+		ContentType: f.ContentType,
+		Content:     string(b),
+	})
+	return u.failErr
+}
+
+type multipartPart struct {
+	name, fileName, content string
+}
+
+func TestMultipartDecoder(t *testing.T) {
+	count := 3
+	cases := []struct {
+		name    string
+		dec     httputil.MultipartDecoder
+		parts   []multipartPart
+		dst     interface{}
+		want    interface{}
+		wantErr error
+	}{
+		{
+			name: "Success",
+			dec:  httputil.MultipartDecoder{AllowedContentTypes: []string{"image/*"}},
+			parts: []multipartPart{
+				{name: "title", content: "Holiday"},
+				{name: "tag", content: "beach"},
+				{name: "tag", content: "sunset"},
+				{name: "count", content: "3"},
+				{name: "caption", content: "At the beach"},
+				{name: "Internal", content: "ignored"},
+				{name: "unknown", content: "ignored"},
+				{name: "photo", fileName: "beach.png", content: pngHeader + "data"},
+			},
+			dst: &uploadRequest{},
+			want: &uploadRequest{
+				Title:   "Holiday",
+				Tags:    []string{"beach", "sunset"},
+				Count:   &count,
+				Caption: xgo.NewOptional("At the beach"),
+				files: []uploadFile{{
+					FieldName:   "photo",
+					FileName:    "beach.png",
+					ContentType: "image/png",
+					Content:     pngHeader + "data",
+				}},
+			},
+		},
+		{
+			name: "LargeFile",
+			dec:  httputil.MultipartDecoder{MaxFileBytes: 4096},
+			parts: []multipartPart{
+				{name: "doc", fileName: "notes.txt", content: strings.Repeat("a", 4096)},
+			},
+			dst: &uploadRequest{},
+			want: &uploadRequest{
+				files: []uploadFile{{
+					FieldName:   "doc",
+					FileName:    "notes.txt",
+					ContentType: "text/plain; charset=utf-8",
+					Content:     strings.Repeat("a", 4096),
+				}},
+			},
+		},
+		{
+			name:    "NotPointerToStruct",
+			parts:   []multipartPart{{name: "title", content: "Holiday"}},
+			dst:     map[string]string{},
+			wantErr: errors.E(errors.WithOp("MultipartDecoder.Decode"), errors.Internal),
+		},
+		{
+			name:  "UnknownField",
+			dec:   httputil.MultipartDecoder{DisallowUnknownFields: true},
+			parts: []multipartPart{{name: "unknown", content: "value"}},
+			dst:   &uploadRequest{},
+			wantErr: errors.E(
+				errors.WithOp("MultipartDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains unknown field 'unknown'"),
+			),
+		},
+		{
+			name:  "InvalidValue",
+			parts: []multipartPart{{name: "count", content: "three"}},
+			dst:   &uploadRequest{},
+			wantErr: errors.E(
+				errors.WithOp("MultipartDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains an invalid value for the 'count' field"),
+			),
+		},
+		{
+			name:  "UnexpectedFile",
+			parts: []multipartPart{{name: "photo", fileName: "beach.png", content: pngHeader}},
+			dst:   &Person{},
+			wantErr: errors.E(
+				errors.WithOp("MultipartDecoder.Decode"),
+				errors.InvalidInput,
+				errors.WithUserMsg("Request body contains unexpected file for field 'photo'"),
+			),
+		},
+		{
+			name:  "UnsupportedContentType",
+			dec:   httputil.MultipartDecoder{AllowedContentTypes: []string{"image/png", "image/jpeg"}},
+			parts: []multipartPart{{name: "photo", fileName: "beach.png", content: "<html></html>"}},
+			dst:   &uploadRequest{},
+			wantErr: errors.E(
+				errors.WithOp("MultipartDecoder.Decode"),
+				httputil.ErrKindUnsupportedMediaType,
+				errors.WithUserMsg("File for field 'photo' has unsupported content type 'text/html; charset=utf-8'"),
+			),
+		},
+		{
+			name:  "FileTooLarge",
+			dec:   httputil.MultipartDecoder{MaxFileBytes: 1024},
+			parts: []multipartPart{{name: "doc", fileName: "notes.txt", content: strings.Repeat("a", 1025)}},
+			dst:   &uploadRequest{},
+			wantErr: errors.E(
+				errors.WithOp("MultipartDecoder.Decode"),
+				httputil.ErrKindRequestEntityTooLarge,
+				errors.WithUserMsg("File for field 'doc' exceeds the limit of 1024 bytes"),
+			),
+		},
+		{
+			name:  "FileTooLargeIgnoredByHandler",
+			dec:   httputil.MultipartDecoder{MaxFileBytes: 100},
+			parts: []multipartPart{{name: "doc", fileName: "notes.txt", content: strings.Repeat("a", 1024)}},
+			dst:   &uploadRequest{failErr: errors.E(errors.Internal)},
+			wantErr: errors.E(
+				errors.WithOp("MultipartDecoder.Decode"),
+				httputil.ErrKindRequestEntityTooLarge,
+				errors.WithUserMsg("File for field 'doc' exceeds the limit of 100 bytes"),
+			),
+		},
+		{
+			name:    "BodyTooLarge",
+			dec:     httputil.MultipartDecoder{MaxBytes: 1024},
+			parts:   []multipartPart{{name: "doc", fileName: "notes.txt", content: strings.Repeat("a", 2048)}},
+			dst:     &uploadRequest{},
+			wantErr: errors.E(errors.WithOp("MultipartDecoder.Decode"), httputil.ErrKindRequestEntityTooLarge),
+		},
+		{
+			name:    "HandlerError",
+			parts:   []multipartPart{{name: "doc", fileName: "notes.txt", content: "notes"}},
+			dst:     &uploadRequest{failErr: errors.E(errors.Unavailable, errors.WithText("store file"))},
+			wantErr: errors.E(errors.WithOp("MultipartDecoder.Decode"), errors.Unavailable, errors.WithText("store file")),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newMultipartRequest(t, tc.parts)
+
+			err := tc.dec.Decode(r, tc.dst)
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("MultipartDecoder.Decode() error diff: %s", errorDiff(tc.wantErr, err))
+				return
+			}
+
+			if err == nil && !reflect.DeepEqual(tc.dst, tc.want) {
+				t.Errorf("\nMultipartDecoder.Decode()=%#v \nwant %#v", tc.dst, tc.want)
+			}
+		})
+	}
+
+	t.Run("UnsupportedMediaType", func(t *testing.T) {
+		r := newJSONRequest(t, `{ "title": "Holiday" }`)
+
+		err := httputil.MultipartDecoder{}.Decode(r, &uploadRequest{})
+		want := errors.E(
+			errors.WithOp("MultipartDecoder.Decode"),
+			httputil.ErrKindUnsupportedMediaType,
+			errors.WithText("Content-Type header 'application/json; charset=utf-8' is not multipart/form-data"),
+		)
+		if !errors.Match(want, err) {
+			t.Errorf("MultipartDecoder.Decode() error diff: %s", errorDiff(want, err))
+		}
+	})
+
+	t.Run("MalformedBody", func(t *testing.T) {
+		r, err := request{
+			method:  http.MethodPost,
+			url:     "http://host.com/route",
+			headers: map[string]string{"Content-Type": "multipart/form-data; boundary=xyz"},
+			body:    "--xyz\r\nContent-Disposition: form-data; name=\"title\"\r\n\r\nHoliday",
+		}.build()
+		if err != nil {
+			t.Fatalf("http.NewRequest: %s", err)
+		}
+
+		err = httputil.MultipartDecoder{}.Decode(r, &uploadRequest{})
+		want := errors.E(
+			errors.WithOp("MultipartDecoder.Decode"),
+			errors.InvalidInput,
+			errors.WithUserMsg("Request body contains badly-formed multipart data"),
+		)
+		if !errors.Match(want, err) {
+			t.Errorf("MultipartDecoder.Decode() error diff: %s", errorDiff(want, err))
+		}
+	})
+}
+
+func TestMultipartDecoderDrain(t *testing.T) {
+	// The file in the request body is far larger than MaxBytes. The body
+	// must not be drained beyond the limit after the decoding fails.
+	src := &countingReader{r: io.MultiReader(
+		strings.NewReader("--xyz\r\nContent-Disposition: form-data; name=\"doc\"; filename=\"notes.txt\"\r\n\r\n"),
+		io.LimitReader(fillReader{}, 10<<20),
+	)}
+	r, err := http.NewRequest(http.MethodPost, "http://host.com/route", src)
+	if err != nil {
+		t.Fatalf("http.NewRequest: %s", err)
+	}
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=xyz")
+
+	err = httputil.MultipartDecoder{MaxBytes: 1024}.Decode(r, &uploadRequest{})
+	if want := errors.E(errors.WithOp("MultipartDecoder.Decode"), httputil.ErrKindRequestEntityTooLarge); !matchErrors(want, err) {
+		t.Errorf("MultipartDecoder.Decode() error diff: %s", errorDiff(want, err))
+	}
+	if src.n > 64<<10 {
+		t.Errorf("Bytes read from request body=%d; want at most %d", src.n, 64<<10)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// fillReader is an endless reader of the byte 'a'.
+type fillReader struct{}
+
+func (fillReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'a'
+	}
+	return len(p), nil
+}
+
+func newMultipartRequest(t *testing.T, parts []multipartPart) *http.Request {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		var (
+			w   io.Writer
+			err error
+		)
+		if p.fileName != "" {
+			w, err = mw.CreateFormFile(p.name, p.fileName)
+		} else {
+			w, err = mw.CreateFormField(p.name)
+		}
+		if err != nil {
+			t.Fatalf("multipart.Writer.CreatePart: %s", err)
+		}
+
+		if _, err := io.WriteString(w, p.content); err != nil {
+			t.Fatalf("multipart part Write: %s", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("multipart.Writer.Close: %s", err)
+	}
+
+	r, err := request{
+		method:  http.MethodPost,
+		url:     "http://host.com/route",
+		headers: map[string]string{"Content-Type": mw.FormDataContentType()},
+		body:    buf.String(),
+	}.build()
+	if err != nil {
+		t.Fatalf("http.NewRequest: %s", err)
+	}
+	return r
+}
@@ -0,0 +1,105 @@
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// checkMediaType checks that the Content-Type header of the request has
+// the given media type.
+func checkMediaType(r *http.Request, want string) error {
+	ct := r.Header.Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != want {
+		return errors.E(
+			ErrKindUnsupportedMediaType,
+			errors.WithTextf("Content-Type header '%s' is not %s", ct, want),
+		)
+	}
+
+	return nil
+}
+
+// patchValue applies the patch function to the JSON representation of
+// the value pointed to by v. The result is then decoded into a new value
+// of the same type which replaces the value pointed to by v.
+func patchValue(v interface{}, disallowUnknownFields bool, patch func(doc interface{}) (interface{}, error)) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.E(errors.Internal, errors.WithTextf("patch target must be a non-nil pointer, got %T", v))
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.E(errors.Internal, errors.WithText("marshal patch target"), errors.WithErr(err))
+	}
+
+	doc, err := unmarshalJSONDoc(b)
+	if err != nil {
+		return errors.E(errors.Internal, errors.WithText("unmarshal patch target"), errors.WithErr(err))
+	}
+
+	if doc, err = patch(doc); err != nil {
+		return err
+	}
+
+	if b, err = json.Marshal(doc); err != nil {
+		return errors.E(errors.Internal, errors.WithText("marshal patched document"), errors.WithErr(err))
+	}
+
+	dec := StdJSONCodec{}.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	nv := reflect.New(rv.Elem().Type())
+	if err := dec.Decode(nv.Interface()); err != nil {
+		return patchedDocErr(b, err)
+	}
+
+	rv.Elem().Set(nv.Elem())
+	return nil
+}
+
+// patchedDocErr translates the error from decoding the patched document
+// into an *errors.Error.
+func patchedDocErr(b []byte, err error) error {
+	var decErr *JSONDecodeError
+	errors.As(err, &decErr)
+	switch {
+	case decErr != nil && decErr.Kind == JSONErrType:
+		path, _ := jsonValueAt(b, decErr.Offset)
+		msg := fmt.Sprintf("Patch results in an invalid value for the '%s' field", path)
+		if path == "" {
+			msg = "Patch results in an invalid value"
+		}
+		return errors.E(
+			errors.InvalidInput, errors.WithUserMsg(msg), errors.WithData(JSONErrorLocation{Path: path}), errors.WithErr(err),
+		)
+
+	case decErr != nil && decErr.Kind == JSONErrUnknownField:
+		msg := fmt.Sprintf("Patch results in unknown field '%s'", decErr.Field)
+		return errors.E(errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err))
+	}
+
+	return errors.E(errors.Internal, errors.WithErr(err))
+}
+
+// unmarshalJSONDoc unmarshals the JSON into a generic document. Numbers
+// are preserved as json.Number.
+func unmarshalJSONDoc(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
@@ -0,0 +1,546 @@
+package httputil
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// EncodeQuery encodes the struct v, or a pointer to it, into query
+// parameters. It is the inverse of QueryDecoder.
+//
+// Fields are mapped to query parameters using the "query" struct tag.
+// The field name is used if the tag is absent and fields with the tag
+// "-" are ignored. The following options are supported in the tag:
+//
+//	omitempty  Skip the field if it has the zero value.
+//	comma      Encode a slice as a single comma separated value.
+//	brackets   Suffix the key with "[]" for a slice and use the
+//	           "parent[child]" keys for a nested struct.
+//	unix       Encode a time.Time as the Unix time in seconds.
+//
+// By default, a slice is encoded as repeated values and a nested struct
+// uses the "parent.child" keys. Fields of embedded structs, or pointers to
+// them, are promoted unless the embedded field has a "query" tag.
+// Strings, booleans, numbers, types implementing encoding.TextMarshaler
+// and pointers, slices and arrays of these are supported. A time.Time
+// is formatted using the layout in the "layout" struct tag if present,
+// time.RFC3339Nano otherwise. Nil pointers and empty slices are always
+// skipped.
+//
+//	type SearchRequest struct {
+//		Query  string    `query:"q"`
+//		Tags   []string  `query:"tags,comma,omitempty"`
+//		Since  time.Time `query:"since,omitempty" layout:"2006-01-02"`
+//		Page   *int      `query:"page"`
+//		Filter struct {
+//			Owner string `query:"owner,omitempty"`
+//		} `query:"filter"`
+//	}
+//
+//	// q=shoes&tags=red,blue&since=2021-03-01&filter.owner=donald
+//
+// It returns an error of kind errors.InvalidInput if v is not a struct
+// or a field has an unsupported type.
+func EncodeQuery(v interface{}) (url.Values, error) {
+	const op = "httputil.EncodeQuery"
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithTextf("value must be a struct, got %T", v))
+	}
+
+	vals := make(url.Values)
+	if err := encodeQueryStruct(vals, queryKeyPrefix{}, rv); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	return vals, nil
+}
+
+// QueryStruct sets the query parameters encoded from the struct v using
+// EncodeQuery. If a value was previously set for any of the encoded
+// parameters, it is replaced.
+//
+// If v cannot be encoded, the error is reported by Build and the query
+// parameters are left unchanged for URL.
+func (u *URLBuilder) QueryStruct(v interface{}) *URLBuilder {
+	vals, err := EncodeQuery(v)
+	if err != nil {
+		u.err = err
+		return u
+	}
+
+	return u.QueryParams(vals)
+}
+
+// QueryStruct sets the query parameters encoded from the struct v. See
+// URLBuilder.QueryStruct.
+func (rb *RequestBuilder) QueryStruct(v interface{}) *RequestBuilder {
+	rb.url.QueryStruct(v)
+	return rb
+}
+
+// QueryDecoder decodes the query parameters of the request into the
+// given value which must be a pointer to a struct. The struct tags
+// described in EncodeQuery are used for mapping the query parameters
+// to the fields so that the same type can be shared by the client and
+// the server.
+//
+//	var dec httputil.Decoder
+//	{
+//		dec = httputil.QueryDecoder{}
+//		dec = httputil.ValidatingDecoderMiddleware(vd)(dec)
+//	}
+//
+//	var req SearchRequest
+//	if err := dec.Decode(r, &req); err != nil {
+//		// handle error
+//	}
+type QueryDecoder struct {
+	// DisallowUnknownFields causes the Decoder to return an error when
+	// the query contains parameters which do not match any non-ignored,
+	// exported fields in the destination.
+	DisallowUnknownFields bool
+}
+
+// Decode decodes the HTTP request into the given value.
+func (q QueryDecoder) Decode(r *http.Request, v interface{}) error {
+	const op = "QueryDecoder.Decode"
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.E(
+			errors.WithOp(op), errors.Internal, errors.WithTextf("destination must be a non-nil pointer to a struct, got %T", v),
+		)
+	}
+
+	d := queryDecodeState{vals: r.URL.Query(), used: make(map[string]bool)}
+	if err := d.decodeStruct(queryKeyPrefix{}, rv.Elem()); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	if q.DisallowUnknownFields {
+		for key := range d.vals {
+			if !d.used[key] {
+				msg := fmt.Sprintf("Query string contains unknown parameter '%s'", key)
+				return errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithUserMsg(msg))
+			}
+		}
+	}
+
+	return nil
+}
+
+// queryField is a struct field mapped to a query parameter.
+type queryField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	comma     bool
+	brackets  bool
+	unix      bool
+	layout    string
+}
+
+// queryFields returns the struct fields mapped to query parameters.
+//
+// The fields of an embedded struct, or pointer to one, are promoted and
+// the embedded field itself is skipped. If the embedded field has a
+// "query" tag, it is treated like a named nested struct instead.
+func queryFields(t reflect.Type) []queryField {
+	var (
+		fields []queryField
+		seen   = make(map[string]bool)
+		// nested is the index of the embedded fields which are treated
+		// as named nested structs.
+		nested [][]int
+	)
+	for _, sf := range reflect.VisibleFields(t) {
+		if withinQueryField(sf.Index, nested) {
+			continue
+		}
+
+		tag, tagged := sf.Tag.Lookup("query")
+		if sf.Anonymous && derefType(sf.Type).Kind() == reflect.Struct {
+			if !tagged || tag == "-" {
+				continue
+			}
+			nested = append(nested, sf.Index)
+		}
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+
+		opts := strings.Split(tag, ",")
+		f := queryField{name: opts[0], index: sf.Index, layout: sf.Tag.Get("layout")}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		for _, opt := range opts[1:] {
+			switch opt {
+			case "omitempty":
+				f.omitEmpty = true
+			case "comma":
+				f.comma = true
+			case "brackets":
+				f.brackets = true
+			case "unix":
+				f.unix = true
+			}
+		}
+
+		if !seen[f.name] {
+			seen[f.name] = true
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// withinQueryField reports whether the field with the given index is
+// promoted from one of the fields with the indices in parents.
+func withinQueryField(index []int, parents [][]int) bool {
+	for _, p := range parents {
+		if len(index) > len(p) && reflect.DeepEqual(index[:len(p)], p) {
+			return true
+		}
+	}
+	return false
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// queryKeyPrefix is the key of the parent struct for the parameters of
+// a nested struct.
+type queryKeyPrefix struct {
+	key      string
+	brackets bool
+}
+
+func (p queryKeyPrefix) child(name string) string {
+	switch {
+	case p.key == "":
+		return name
+	case p.brackets:
+		return p.key + "[" + name + "]"
+	default:
+		return p.key + "." + name
+	}
+}
+
+// isQueryScalar reports whether the type is encoded as a single query
+// parameter value.
+func isQueryScalar(t reflect.Type) bool {
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) ||
+		t.Implements(textUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func encodeQueryStruct(vals url.Values, prefix queryKeyPrefix, v reflect.Value) error {
+	for _, f := range queryFields(v.Type()) {
+		fv, ok := queryFieldValue(v, f.index)
+		if !ok || (f.omitEmpty && fv.IsZero()) {
+			continue
+		}
+		for fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				break
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Ptr {
+			continue
+		}
+
+		key := prefix.child(f.name)
+		switch {
+		case isQueryScalar(fv.Type()):
+			s, err := f.format(fv)
+			if err != nil {
+				return queryValueErr(key, err)
+			}
+			vals.Add(key, s)
+
+		case fv.Kind() == reflect.Struct:
+			if err := encodeQueryStruct(vals, queryKeyPrefix{key: key, brackets: f.brackets}, fv); err != nil {
+				return err
+			}
+
+		case fv.Kind() == reflect.Slice, fv.Kind() == reflect.Array:
+			if err := f.encodeList(vals, key, fv); err != nil {
+				return err
+			}
+
+		default:
+			return errors.E(errors.InvalidInput, errors.WithTextf("query parameter %q: unsupported type %s", key, fv.Type()))
+		}
+	}
+	return nil
+}
+
+func (f queryField) encodeList(vals url.Values, key string, v reflect.Value) error {
+	items := make([]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		ev := v.Index(i)
+		if ev.Kind() == reflect.Ptr {
+			if ev.IsNil() {
+				continue
+			}
+			ev = ev.Elem()
+		}
+		if !isQueryScalar(ev.Type()) {
+			return errors.E(errors.InvalidInput, errors.WithTextf("query parameter %q: unsupported element type %s", key, ev.Type()))
+		}
+
+		s, err := f.format(ev)
+		if err != nil {
+			return queryValueErr(key, err)
+		}
+		items = append(items, s)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	switch {
+	case f.comma:
+		vals.Add(key, strings.Join(items, ","))
+	case f.brackets:
+		vals[key+"[]"] = append(vals[key+"[]"], items...)
+	default:
+		vals[key] = append(vals[key], items...)
+	}
+	return nil
+}
+
+// format formats the scalar value as a query parameter value.
+func (f queryField) format(v reflect.Value) (string, error) {
+	if v.Type() == timeType && (f.unix || f.layout != "") {
+		t := v.Interface().(time.Time)
+		if f.unix {
+			return strconv.FormatInt(t.Unix(), 10), nil
+		}
+		return t.Format(f.layout), nil
+	}
+
+	if v.Type().Implements(textMarshalerType) || reflect.PtrTo(v.Type()).Implements(textMarshalerType) {
+		tm, ok := v.Interface().(encoding.TextMarshaler)
+		if !ok {
+			// Only the pointer implements TextMarshaler.
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			tm = p.Interface().(encoding.TextMarshaler)
+		}
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
+
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func queryValueErr(key string, err error) error {
+	return errors.E(errors.InvalidInput, errors.WithTextf("query parameter %q: %s", key, err), errors.WithErr(err))
+}
+
+// queryFieldValue returns the nested field of the struct corresponding
+// to index. It reports false if an embedded struct pointer is nil.
+func queryFieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// queryDecodeState holds the query being decoded and the parameters
+// which were mapped to a field.
+type queryDecodeState struct {
+	vals url.Values
+	used map[string]bool
+}
+
+func (d queryDecodeState) decodeStruct(prefix queryKeyPrefix, v reflect.Value) error {
+	for _, f := range queryFields(v.Type()) {
+		key := prefix.child(f.name)
+
+		ft := v.Type().FieldByIndex(f.index).Type
+		et := derefType(ft)
+
+		var err error
+		switch {
+		case isQueryScalar(et):
+			if vv, ok := d.vals[key]; ok {
+				d.used[key] = true
+				err = f.parse(fieldByIndex(v, f.index), vv[0])
+			}
+
+		case et.Kind() == reflect.Struct:
+			child := queryKeyPrefix{key: key, brackets: f.brackets}
+			if d.hasPrefix(child) {
+				err = d.decodeStruct(child, indirect(fieldByIndex(v, f.index)))
+			}
+
+		case et.Kind() == reflect.Slice, et.Kind() == reflect.Array:
+			if f.brackets {
+				key += "[]"
+			}
+			err = d.decodeList(f, key, v)
+
+		default:
+			// The destination, not the query, is at fault.
+			err = errors.E(errors.Internal, errors.WithTextf("query parameter %q: unsupported field type %s", key, ft))
+		}
+		if err != nil {
+			if errors.WhatKind(err) != errors.Unknown {
+				return err
+			}
+
+			msg := fmt.Sprintf("Query string contains an invalid value for the '%s' parameter", key)
+			return errors.E(errors.InvalidInput, errors.WithUserMsg(msg), errors.WithErr(err))
+		}
+	}
+	return nil
+}
+
+func (d queryDecodeState) decodeList(f queryField, key string, sv reflect.Value) error {
+	vv, ok := d.vals[key]
+	if !ok {
+		return nil
+	}
+	d.used[key] = true
+
+	if f.comma {
+		var items []string
+		for _, s := range vv {
+			items = append(items, strings.Split(s, ",")...)
+		}
+		vv = items
+	}
+
+	v := indirect(fieldByIndex(sv, f.index))
+	if v.Kind() == reflect.Array {
+		if len(vv) > v.Len() {
+			return fmt.Errorf("too many values for array of length %d", v.Len())
+		}
+		for i, s := range vv {
+			if err := f.parse(v.Index(i), s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	list := reflect.MakeSlice(v.Type(), len(vv), len(vv))
+	for i, s := range vv {
+		if err := f.parse(list.Index(i), s); err != nil {
+			return err
+		}
+	}
+	v.Set(list)
+	return nil
+}
+
+// hasPrefix reports whether the query has a parameter for a field of
+// the nested struct.
+func (d queryDecodeState) hasPrefix(p queryKeyPrefix) bool {
+	prefix := p.key + "."
+	if p.brackets {
+		prefix = p.key + "["
+	}
+	for key := range d.vals {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parse sets the value parsed from the query parameter value s on v.
+func (f queryField) parse(v reflect.Value, s string) error {
+	v = indirect(v)
+	if v.Type() != timeType || (!f.unix && f.layout == "") {
+		return setFormValue(v, s)
+	}
+
+	var (
+		t   time.Time
+		err error
+	)
+	if f.unix {
+		var sec int64
+		sec, err = strconv.ParseInt(s, 10, 64)
+		t = time.Unix(sec, 0).UTC()
+	} else {
+		t, err = time.Parse(f.layout, s)
+	}
+	if err != nil {
+		return err
+	}
+
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// indirect allocates the nil pointers and returns the value pointed to
+// by v.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
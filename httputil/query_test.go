@@ -0,0 +1,339 @@
+package httputil_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+type Paging struct {
+	Page  int `query:"page,omitempty"`
+	Limit int `query:"limit,omitempty"`
+}
+
+type SearchFilter struct {
+	Owner  string   `query:"owner,omitempty"`
+	States []string `query:"state,omitempty"`
+}
+
+type SearchRequest struct {
+	Paging
+
+	Query    string         `query:"q"`
+	Tags     []string       `query:"tags,comma,omitempty"`
+	IDs      []int64        `query:"id,brackets,omitempty"`
+	Ratios   [2]float64     `query:"ratio,omitempty"`
+	Exact    *bool          `query:"exact"`
+	Since    time.Time      `query:"since,omitempty" layout:"2006-01-02"`
+	Until    *time.Time     `query:"until,unix"`
+	Updated  time.Time      `query:"updated,omitempty"`
+	IP       net.IP         `query:"ip,omitempty"`
+	Filter   SearchFilter   `query:"filter"`
+	Sort     *SearchFilter  `query:"sort,brackets"`
+	Internal string         `query:"-"`
+	Extra    map[string]int `query:"-"`
+	Default  string
+}
+
+type QueryBase struct {
+	ID string `query:"id"`
+}
+
+type QueryEmbeddedPtr struct {
+	*QueryBase
+	Q string `query:"q"`
+}
+
+type QueryEmbeddedTagged struct {
+	QueryBase `query:"base"`
+	Q         string `query:"q"`
+}
+
+func TestEncodeQuery(t *testing.T) {
+	exact := true
+	until := time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	const op = "httputil.EncodeQuery"
+	cases := []struct {
+		name    string
+		v       interface{}
+		want    url.Values
+		wantErr error
+	}{
+		{
+			name: "Zero",
+			v:    SearchRequest{},
+			want: url.Values{"q": {""}, "Default": {""}},
+		},
+		{
+			name: "All",
+			v: &SearchRequest{
+				Paging:   Paging{Page: 2},
+				Query:    "red shoes",
+				Tags:     []string{"sale", "new"},
+				IDs:      []int64{4, 2},
+				Ratios:   [2]float64{0.5, 1.25},
+				Exact:    &exact,
+				Since:    time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+				Until:    &until,
+				Updated:  time.Date(2021, 3, 2, 10, 30, 0, 0, time.UTC),
+				IP:       net.IPv4(10, 0, 0, 1),
+				Filter:   SearchFilter{Owner: "donald", States: []string{"open", "draft"}},
+				Sort:     &SearchFilter{Owner: "asc"},
+				Internal: "secret",
+				Default:  "value",
+			},
+			want: url.Values{
+				"page":         {"2"},
+				"q":            {"red shoes"},
+				"tags":         {"sale,new"},
+				"id[]":         {"4", "2"},
+				"ratio":        {"0.5", "1.25"},
+				"exact":        {"true"},
+				"since":        {"2021-03-01"},
+				"until":        {"1617148800"},
+				"updated":      {"2021-03-02T10:30:00Z"},
+				"ip":           {"10.0.0.1"},
+				"filter.owner": {"donald"},
+				"filter.state": {"open", "draft"},
+				"sort[owner]":  {"asc"},
+				"Default":      {"value"},
+			},
+		},
+		{
+			name: "EmbeddedPointer",
+			v:    QueryEmbeddedPtr{QueryBase: &QueryBase{ID: "x"}, Q: "y"},
+			want: url.Values{"id": {"x"}, "q": {"y"}},
+		},
+		{
+			name: "EmbeddedNilPointer",
+			v:    QueryEmbeddedPtr{Q: "y"},
+			want: url.Values{"q": {"y"}},
+		},
+		{
+			name: "EmbeddedTagged",
+			v:    QueryEmbeddedTagged{QueryBase: QueryBase{ID: "x"}, Q: "y"},
+			want: url.Values{"base.id": {"x"}, "q": {"y"}},
+		},
+		{
+			name:    "NotStruct",
+			v:       []string{"a"},
+			wantErr: errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("value must be a struct, got []string")),
+		},
+		{
+			name: "UnsupportedType",
+			v: struct {
+				Meta map[string]string `query:"meta"`
+			}{Meta: map[string]string{"a": "b"}},
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.WithErr(errors.E(
+					errors.InvalidInput,
+					errors.WithText(`query parameter "meta": unsupported type map[string]string`),
+				)),
+			),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := httputil.EncodeQuery(tc.v)
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("EncodeQuery() error diff: %s", errorDiff(tc.wantErr, err))
+			}
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("EncodeQuery()=%v; want=%v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestQueryDecoderDecode(t *testing.T) {
+	exact := false
+	until := time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	const op = "QueryDecoder.Decode"
+	cases := []struct {
+		name    string
+		dec     httputil.QueryDecoder
+		query   string
+		want    SearchRequest
+		wantErr error
+	}{
+		{
+			name: "RoundTrip",
+			want: SearchRequest{
+				Paging:  Paging{Page: 2, Limit: 10},
+				Query:   "red shoes",
+				Tags:    []string{"sale", "new"},
+				IDs:     []int64{4, 2},
+				Ratios:  [2]float64{0.5, 1.25},
+				Exact:   &exact,
+				Since:   time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+				Until:   &until,
+				Updated: time.Date(2021, 3, 2, 10, 30, 0, 0, time.UTC),
+				IP:      net.IPv4(10, 0, 0, 1),
+				Filter:  SearchFilter{Owner: "donald", States: []string{"open", "draft"}},
+				Sort:    &SearchFilter{Owner: "asc"},
+				Default: "value",
+			},
+		},
+		{
+			name:  "Missing",
+			query: "q=shoes&unknown=1",
+			want:  SearchRequest{Query: "shoes"},
+		},
+		{
+			name:  "InvalidValue",
+			query: "q=shoes&filter.owner=donald&id[]=4&id[]=x",
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithUserMsg("Query string contains an invalid value for the 'id[]' parameter"),
+			),
+		},
+		{
+			name:  "InvalidLayout",
+			query: "since=01/03/2021",
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithUserMsg("Query string contains an invalid value for the 'since' parameter"),
+			),
+		},
+		{
+			name:  "TooManyArrayValues",
+			query: "ratio=1&ratio=2&ratio=3",
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithUserMsg("Query string contains an invalid value for the 'ratio' parameter"),
+			),
+		},
+		{
+			name:  "DisallowUnknownFields",
+			dec:   httputil.QueryDecoder{DisallowUnknownFields: true},
+			query: "q=shoes&unknown=1",
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithUserMsg("Query string contains unknown parameter 'unknown'"),
+			),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := tc.query
+			if query == "" {
+				vals, err := httputil.EncodeQuery(tc.want)
+				if err != nil {
+					t.Fatalf("EncodeQuery() error=%v", err)
+				}
+				query = vals.Encode()
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/search?"+query, nil)
+
+			var got SearchRequest
+			err := tc.dec.Decode(r, &got)
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("QueryDecoder.Decode() error diff: %s", errorDiff(tc.wantErr, err))
+			}
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("QueryDecoder.Decode()=%+v; want=%+v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("EmbeddedPointer", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/search?id=x&q=y", nil)
+		var got QueryEmbeddedPtr
+		if err := (httputil.QueryDecoder{DisallowUnknownFields: true}).Decode(r, &got); err != nil {
+			t.Fatalf("QueryDecoder.Decode() error=%v", err)
+		}
+
+		want := QueryEmbeddedPtr{QueryBase: &QueryBase{ID: "x"}, Q: "y"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("QueryDecoder.Decode()=%+v; want=%+v", got, want)
+		}
+	})
+
+	t.Run("EmbeddedTagged", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/search?base.id=x&q=y", nil)
+		var got QueryEmbeddedTagged
+		if err := (httputil.QueryDecoder{DisallowUnknownFields: true}).Decode(r, &got); err != nil {
+			t.Fatalf("QueryDecoder.Decode() error=%v", err)
+		}
+
+		want := QueryEmbeddedTagged{QueryBase: QueryBase{ID: "x"}, Q: "y"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("QueryDecoder.Decode()=%+v; want=%+v", got, want)
+		}
+	})
+
+	t.Run("UnsupportedFieldType", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/search?meta=1", nil)
+		var got struct {
+			Meta map[string]string `query:"meta"`
+		}
+		err := httputil.QueryDecoder{}.Decode(r, &got)
+		wantErr := errors.E(
+			errors.WithOp("QueryDecoder.Decode"),
+			errors.Internal,
+			errors.WithText(`query parameter "meta": unsupported field type map[string]string`),
+		)
+		if !matchErrors(wantErr, err) {
+			t.Errorf("QueryDecoder.Decode() error diff: %s", errorDiff(wantErr, err))
+		}
+	})
+
+	t.Run("InvalidDestination", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/search?q=shoes", nil)
+		var got SearchRequest
+		err := httputil.QueryDecoder{}.Decode(r, got)
+		if errors.WhatKind(err) != errors.Internal {
+			t.Errorf("QueryDecoder.Decode() error=%v; want error of kind Internal", err)
+		}
+	})
+}
+
+func TestURLBuilderQueryStruct(t *testing.T) {
+	b, err := httputil.NewURLBuilderSource("https://api.example.com/v1?api_key=secret")
+	if err != nil {
+		t.Fatalf("NewURLBuilderSource(): %s", err)
+	}
+
+	u, err := b.NewURLBuilder().
+		Path("/search").
+		QueryParam("q", "replaced").
+		QueryStruct(SearchRequest{Query: "shoes", Tags: []string{"a", "b"}}).
+		Build()
+	if err != nil {
+		t.Fatalf("URLBuilder.Build() error=%v", err)
+	}
+
+	want := "https://api.example.com/v1/search?Default=&api_key=secret&q=shoes&tags=a%2Cb"
+	if u.String() != want {
+		t.Errorf("URLBuilder.Build()=%s; want=%s", u, want)
+	}
+
+	_, err = b.NewURLBuilder().Path("/search").QueryStruct("shoes").Build()
+	wantErr := errors.E(errors.WithOp("URLBuilder.Build"), errors.InvalidInput)
+	if !matchErrors(wantErr, err) {
+		t.Errorf("URLBuilder.Build() error diff: %s", errorDiff(wantErr, err))
+	}
+}
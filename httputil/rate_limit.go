@@ -0,0 +1,360 @@
+package httputil
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// DefaultRateLimitMaxHosts is used by RateLimitingTransport for the zero
+// value of RateLimitOptions.MaxHosts.
+const DefaultRateLimitMaxHosts = 1000
+
+// RateLimit is the limit for the requests sent to a host.
+type RateLimit struct {
+	// Rate is the number of requests allowed per second. The requests
+	// are not rate limited if Rate is zero.
+	Rate float64
+
+	// Burst is the number of requests which can be sent at once, after a
+	// period of inactivity, without being rate limited. Defaults to 1 if
+	// zero.
+	Burst int
+
+	// MaxInFlight is the maximum number of requests which can be in
+	// flight at once. A request is in flight until the response body is
+	// closed. The concurrency is not limited if MaxInFlight is zero.
+	MaxInFlight int
+}
+
+// RateLimitOptions configures the middleware returned by
+// RateLimitingTransport.
+type RateLimitOptions struct {
+	// Default is the limit for the hosts not present in Hosts.
+	Default RateLimit
+
+	// Hosts is the limit for each host, keyed by the host of the request
+	// URL. The port must be included if present in the URL. Optional.
+	Hosts map[string]RateLimit
+
+	// Adaptive pauses the requests to a host when the response asks the
+	// client to slow down. The pause lasts for the wait specified in the
+	// Retry-After header or until the time specified in the
+	// X-RateLimit-Reset header if X-RateLimit-Remaining is 0.
+	Adaptive bool
+
+	// MaxHosts is the number of hosts for which the state of the limits
+	// is kept before the state of idle hosts is discarded. A host is
+	// idle if it has no requests in flight or waiting, its token bucket
+	// is full and it is not paused, making the discarded state
+	// equivalent to a new one. DefaultRateLimitMaxHosts is used if zero.
+	MaxHosts int
+
+	// Clock is used for refilling the token buckets and for waiting. The
+	// system clock is used if nil.
+	Clock Clock
+}
+
+// RateLimitingTransport returns a middleware for http.RoundTripper which
+// limits the rate and the concurrency of the requests to each host. The
+// rate is limited using a token bucket.
+//
+// A request waits for its turn as long as its context allows. If the
+// wait for the rate limit would exceed the deadline of the context, the
+// request fails immediately with an error of kind
+// errors.ResourceExhausted. If the deadline is exceeded while waiting
+// for a request in flight to complete, the error is of kind
+// errors.DeadlineExceeded.
+//
+//	client := &http.Client{
+//		Transport: httputil.RateLimitingTransport(httputil.RateLimitOptions{
+//			Default: httputil.RateLimit{Rate: 10, Burst: 5, MaxInFlight: 4},
+//			Hosts: map[string]httputil.RateLimit{
+//				"api.github.com": {Rate: 1.3, MaxInFlight: 2},
+//			},
+//			Adaptive: true,
+//		})(http.DefaultTransport),
+//	}
+func RateLimitingTransport(opts RateLimitOptions) func(http.RoundTripper) http.RoundTripper {
+	if opts.MaxHosts == 0 {
+		opts.MaxHosts = DefaultRateLimitMaxHosts
+	}
+	opts.Clock = clockOrDefault(opts.Clock)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &rateLimitTransport{
+			next:     next,
+			opts:     opts,
+			limiters: make(map[string]*hostLimiter),
+		}
+	}
+}
+
+type rateLimitTransport struct {
+	next http.RoundTripper
+	opts RateLimitOptions
+
+	mu       sync.Mutex
+	limiters map[string]*hostLimiter
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	const op = "httputil.RateLimitingTransport"
+
+	l := t.limiter(req.URL.Host)
+
+	release, err := l.acquire(req.Context())
+	if err != nil {
+		l.unref()
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	if err := l.wait(req.Context()); err != nil {
+		release()
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	if t.opts.Adaptive {
+		l.adapt(resp.Header)
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// limiter returns the limiter for the host with a reference taken on
+// it. The reference is released by the function returned by acquire or
+// by unref.
+func (t *rateLimitTransport) limiter(host string) *hostLimiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	l, ok := t.limiters[host]
+	if !ok {
+		if len(t.limiters) >= t.opts.MaxHosts {
+			t.evictIdle()
+		}
+
+		limit, ok := t.opts.Hosts[host]
+		if !ok {
+			limit = t.opts.Default
+		}
+		l = newHostLimiter(limit, t.opts.Clock)
+		t.limiters[host] = l
+	}
+	atomic.AddInt32(&l.refs, 1)
+	return l
+}
+
+// evictIdle discards the limiters of the idle hosts. It must be called
+// with t.mu held, which prevents new references from being taken.
+func (t *rateLimitTransport) evictIdle() {
+	for host, l := range t.limiters {
+		if l.idle() {
+			delete(t.limiters, host)
+		}
+	}
+}
+
+// hostLimiter limits the requests to a single host.
+type hostLimiter struct {
+	clock    Clock
+	inFlight chan struct{}
+
+	// refs is the number of requests using the limiter.
+	refs int32
+
+	mu          sync.Mutex
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newHostLimiter(limit RateLimit, clock Clock) *hostLimiter {
+	l := hostLimiter{
+		clock:  clock,
+		rate:   limit.Rate,
+		burst:  float64(limit.Burst),
+		last:   clock.Now(),
+		tokens: float64(limit.Burst),
+	}
+	if l.burst < 1 {
+		l.burst, l.tokens = 1, 1
+	}
+	if limit.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, limit.MaxInFlight)
+	}
+	return &l
+}
+
+// acquire waits for a slot for the request in flight. The returned
+// function must be called to release the slot along with the reference
+// on the limiter.
+func (l *hostLimiter) acquire(ctx context.Context) (release func(), err error) {
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+
+		case <-ctx.Done():
+			kind := errors.DeadlineExceeded
+			if errors.Is(ctx.Err(), context.Canceled) {
+				kind = errors.Canceled
+			}
+			return nil, errors.E(kind, errors.WithText("wait for request in flight"), errors.WithErr(ctx.Err()))
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if l.inFlight != nil {
+				<-l.inFlight
+			}
+			l.unref()
+		})
+	}, nil
+}
+
+func (l *hostLimiter) unref() {
+	atomic.AddInt32(&l.refs, -1)
+}
+
+// idle reports whether the limiter is not in use and has no state which
+// a new limiter would not.
+func (l *hostLimiter) idle() bool {
+	if atomic.LoadInt32(&l.refs) != 0 {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	if l.pausedUntil.After(now) {
+		return false
+	}
+	return l.rate == 0 || l.tokens+now.Sub(l.last).Seconds()*l.rate >= l.burst
+}
+
+// wait waits until the request can be sent as per the rate limit and
+// the pause requested by the host.
+func (l *hostLimiter) wait(ctx context.Context) error {
+	d := l.reserve()
+	if d <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && deadline.Sub(l.clock.Now()) < d {
+		l.cancel()
+		return errors.E(errors.ResourceExhausted, errors.WithTextf("rate limit wait of %s exceeds the deadline", d))
+	}
+
+	select {
+	case <-l.clock.After(d):
+		return nil
+
+	case <-ctx.Done():
+		l.cancel()
+		kind := errors.DeadlineExceeded
+		if errors.Is(ctx.Err(), context.Canceled) {
+			kind = errors.Canceled
+		}
+		return errors.E(kind, errors.WithText("wait for rate limit"), errors.WithErr(ctx.Err()))
+	}
+}
+
+// reserve takes a token and returns the wait before the request can be
+// sent.
+func (l *hostLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	var d time.Duration
+	if l.rate > 0 {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+
+		l.tokens--
+		if l.tokens < 0 {
+			d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+		}
+	}
+
+	if pause := l.pausedUntil.Sub(now); pause > d {
+		d = pause
+	}
+	return d
+}
+
+// cancel returns the token taken by reserve.
+func (l *hostLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate > 0 {
+		l.tokens++
+	}
+}
+
+// adapt pauses the requests if the response headers ask the client to
+// slow down.
+func (l *hostLimiter) adapt(h http.Header) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	var until time.Time
+	if d, ok := retryAfter(h.Get("Retry-After"), now); ok {
+		until = now.Add(d)
+	} else if h.Get("X-RateLimit-Remaining") == "0" {
+		until, _ = rateLimitReset(h.Get("X-RateLimit-Reset"), now)
+	}
+
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// rateLimitReset parses the value of the X-RateLimit-Reset header which
+// is either the Unix time in seconds or, if it is too small for one, the
+// number of seconds until the reset.
+func rateLimitReset(v string, now time.Time) (time.Time, bool) {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return time.Time{}, false
+	}
+
+	// 1e9 seconds is over 31 years, which is too long for a delay.
+	if n >= 1e9 {
+		return time.Unix(n, 0), true
+	}
+	return now.Add(time.Duration(n) * time.Second), true
+}
+
+// releasingBody releases the slot of the request in flight when the
+// response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,295 @@
+package httputil_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestRateLimitingTransport(t *testing.T) {
+	ok := scriptedResponse{status: http.StatusOK}
+
+	get := func(t *testing.T, hc *http.Client, ctx context.Context, u string) (*http.Response, error) {
+		t.Helper()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			t.Fatalf("NewRequest() error=%v", err)
+		}
+		return hc.Do(req)
+	}
+
+	checkWaits := func(t *testing.T, clock *fakeClock, want []time.Duration) {
+		t.Helper()
+
+		waits := clock.Waits()
+		if len(waits) != len(want) {
+			t.Fatalf("Waits=%v; want=%v", waits, want)
+		}
+		for i := range waits {
+			if waits[i] != want[i] {
+				t.Errorf("Wait %d=%s; want=%s", i+1, waits[i], want[i])
+			}
+		}
+	}
+
+	t.Run("Rate", func(t *testing.T) {
+		srv := newScriptedServer(t, ok)
+
+		clock := newFakeClock()
+		opts := httputil.RateLimitOptions{Default: httputil.RateLimit{Rate: 2, Burst: 2}, Clock: clock}
+		hc := &http.Client{Transport: httputil.RateLimitingTransport(opts)(http.DefaultTransport)}
+
+		for i := 0; i < 4; i++ {
+			resp, err := get(t, hc, context.Background(), srv.URL)
+			if err != nil {
+				t.Fatalf("Client.Do() error=%v", err)
+			}
+			resp.Body.Close()
+		}
+
+		// The burst of 2 requests is sent right away and the following
+		// requests wait for a token to be added every 500ms.
+		checkWaits(t, clock, []time.Duration{500 * time.Millisecond, 500 * time.Millisecond})
+	})
+
+	t.Run("HostOverride", func(t *testing.T) {
+		srv := newScriptedServer(t, ok)
+		u, err := url.Parse(srv.URL)
+		if err != nil {
+			t.Fatalf("url.Parse() error=%v", err)
+		}
+
+		clock := newFakeClock()
+		opts := httputil.RateLimitOptions{
+			Default: httputil.RateLimit{Rate: 1},
+			Hosts:   map[string]httputil.RateLimit{u.Host: {Rate: 10, Burst: 3}},
+			Clock:   clock,
+		}
+		hc := &http.Client{Transport: httputil.RateLimitingTransport(opts)(http.DefaultTransport)}
+
+		for i := 0; i < 3; i++ {
+			resp, err := get(t, hc, context.Background(), srv.URL)
+			if err != nil {
+				t.Fatalf("Client.Do() error=%v", err)
+			}
+			resp.Body.Close()
+		}
+
+		checkWaits(t, clock, nil)
+	})
+
+	t.Run("WaitExceedsDeadline", func(t *testing.T) {
+		srv := newScriptedServer(t, ok)
+
+		clock := newFakeClock()
+		opts := httputil.RateLimitOptions{Default: httputil.RateLimit{Rate: 1}, Clock: clock}
+		hc := &http.Client{Transport: httputil.RateLimitingTransport(opts)(http.DefaultTransport)}
+
+		resp, err := get(t, hc, context.Background(), srv.URL)
+		if err != nil {
+			t.Fatalf("Client.Do() error=%v", err)
+		}
+		resp.Body.Close()
+
+		// The deadline is compared with the time of the Clock.
+		ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(100*time.Millisecond))
+		defer cancel()
+
+		if _, err := get(t, hc, ctx, srv.URL); errors.WhatKind(err) != errors.ResourceExhausted {
+			t.Errorf("Client.Do() error=%v; want kind %s", err, errors.ResourceExhausted)
+		}
+		checkWaits(t, clock, nil)
+
+		// The token reserved by the failed request is returned.
+		resp, err = get(t, hc, context.Background(), srv.URL)
+		if err != nil {
+			t.Fatalf("Client.Do() error=%v", err)
+		}
+		resp.Body.Close()
+		checkWaits(t, clock, []time.Duration{time.Second})
+
+		if got := len(srv.Bodies()); got != 2 {
+			t.Errorf("Requests=%d; want=%d", got, 2)
+		}
+	})
+
+	t.Run("MaxInFlight", func(t *testing.T) {
+		srv := newScriptedServer(t, ok)
+
+		opts := httputil.RateLimitOptions{Default: httputil.RateLimit{MaxInFlight: 1}, Clock: newFakeClock()}
+		hc := &http.Client{Transport: httputil.RateLimitingTransport(opts)(http.DefaultTransport)}
+
+		first, err := get(t, hc, context.Background(), srv.URL)
+		if err != nil {
+			t.Fatalf("Client.Do() error=%v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		if _, err := get(t, hc, ctx, srv.URL); errors.WhatKind(err) != errors.DeadlineExceeded {
+			t.Errorf("Client.Do() error=%v; want kind %s", err, errors.DeadlineExceeded)
+		}
+
+		first.Body.Close()
+
+		resp, err := get(t, hc, context.Background(), srv.URL)
+		if err != nil {
+			t.Fatalf("Client.Do() error=%v", err)
+		}
+		resp.Body.Close()
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		srv := newScriptedServer(t, ok)
+
+		opts := httputil.RateLimitOptions{Default: httputil.RateLimit{MaxInFlight: 1}, Clock: newFakeClock()}
+		hc := &http.Client{Transport: httputil.RateLimitingTransport(opts)(http.DefaultTransport)}
+
+		first, err := get(t, hc, context.Background(), srv.URL)
+		if err != nil {
+			t.Fatalf("Client.Do() error=%v", err)
+		}
+		defer first.Body.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := get(t, hc, ctx, srv.URL); !errors.Is(err, context.Canceled) {
+			t.Errorf("Client.Do() error=%v; want context.Canceled", err)
+		}
+	})
+
+	t.Run("MaxHosts", func(t *testing.T) {
+		next := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader("")),
+				Request:    r,
+			}, nil
+		})
+
+		clock := newFakeClock()
+		opts := httputil.RateLimitOptions{
+			Default: httputil.RateLimit{Rate: 1},
+			Hosts:   map[string]httputil.RateLimit{"a.example.com": {MaxInFlight: 1}},
+			// Every new host triggers the eviction of the idle hosts.
+			MaxHosts: 1,
+			Clock:    clock,
+		}
+		hc := &http.Client{Transport: httputil.RateLimitingTransport(opts)(next)}
+
+		held, err := get(t, hc, context.Background(), "https://a.example.com")
+		if err != nil {
+			t.Fatalf("Client.Do() error=%v", err)
+		}
+		for _, u := range []string{"https://b.example.com", "https://c.example.com"} {
+			resp, err := get(t, hc, context.Background(), u)
+			if err != nil {
+				t.Fatalf("Client.Do() error=%v", err)
+			}
+			resp.Body.Close()
+		}
+
+		// The request in flight to a.example.com must not be forgotten.
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := get(t, hc, ctx, "https://a.example.com"); errors.WhatKind(err) != errors.DeadlineExceeded {
+			t.Errorf("Client.Do() error=%v; want kind %s", err, errors.DeadlineExceeded)
+		}
+		held.Body.Close()
+
+		// Neither must the token taken from the bucket of b.example.com
+		// when c.example.com evicts the idle hosts.
+		resp, err := get(t, hc, context.Background(), "https://b.example.com")
+		if err != nil {
+			t.Fatalf("Client.Do() error=%v", err)
+		}
+		resp.Body.Close()
+		checkWaits(t, clock, []time.Duration{time.Second})
+	})
+
+	adaptiveCases := []struct {
+		name   string
+		header http.Header
+		want   []time.Duration
+	}{
+		{
+			name:   "RetryAfter",
+			header: http.Header{"Retry-After": {"2"}},
+			want:   []time.Duration{2 * time.Second},
+		},
+		{
+			name:   "RateLimitResetSeconds",
+			header: http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"3"}},
+			want:   []time.Duration{3 * time.Second},
+		},
+		{
+			name:   "RateLimitResetUnixTime",
+			header: http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1617184805"}},
+			want:   []time.Duration{5 * time.Second},
+		},
+		{
+			name:   "RateLimitRemaining",
+			header: http.Header{"X-Ratelimit-Remaining": {"12"}, "X-Ratelimit-Reset": {"3"}},
+		},
+	}
+	for _, tc := range adaptiveCases {
+		t.Run("Adaptive"+tc.name, func(t *testing.T) {
+			var requests int
+			next := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				requests++
+				h := http.Header{}
+				if requests == 1 {
+					h = tc.header
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     h,
+					Body:       io.NopCloser(strings.NewReader("")),
+					Request:    r,
+				}, nil
+			})
+
+			clock := newFakeClock()
+			opts := httputil.RateLimitOptions{Adaptive: true, Clock: clock}
+			hc := &http.Client{Transport: httputil.RateLimitingTransport(opts)(next)}
+
+			for i := 0; i < 3; i++ {
+				resp, err := get(t, hc, context.Background(), "https://api.example.com/users")
+				if err != nil {
+					t.Fatalf("Client.Do() error=%v", err)
+				}
+				resp.Body.Close()
+			}
+
+			checkWaits(t, clock, tc.want)
+		})
+	}
+
+	t.Run("AdaptiveDisabled", func(t *testing.T) {
+		srv := newScriptedServer(t, scriptedResponse{status: http.StatusTooManyRequests, retryAfter: "2"}, ok)
+
+		clock := newFakeClock()
+		hc := &http.Client{Transport: httputil.RateLimitingTransport(httputil.RateLimitOptions{Clock: clock})(http.DefaultTransport)}
+
+		for i := 0; i < 2; i++ {
+			resp, err := get(t, hc, context.Background(), srv.URL)
+			if err != nil {
+				t.Fatalf("Client.Do() error=%v", err)
+			}
+			resp.Body.Close()
+		}
+
+		checkWaits(t, clock, nil)
+	})
+}
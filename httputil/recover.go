@@ -0,0 +1,80 @@
+package httputil
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// PanicData is set as the Data on the error constructed from a
+// recovered panic.
+type PanicData struct {
+	// Value is the value passed to panic.
+	Value interface{} `json:"value"`
+
+	// Stack is the formatted stack trace of the goroutine which
+	// panicked.
+	Stack string `json:"stack"`
+}
+
+// Recoverer returns an http.Handler which recovers from any panic in h.
+// The panic is converted into an error of kind errors.Internal with
+// PanicData set as the Data. If the panic value is an error, it is set
+// as the underlying error.
+//
+// The error response is written using JSONResponder.Error if the
+// response has not been written yet. Otherwise, the ErrObservers are
+// notified of the error.
+//
+// The ResponseWriter passed to h implements http.Flusher and
+// http.Hijacker only if w does. A panic after h hijacks the connection
+// is reported to the ErrObservers.
+//
+// The panic value http.ErrAbortHandler is re-panicked so that the
+// server can abort the response.
+//
+//	http.Handle("/", responder.Recoverer(mux))
+func (jr *JSONResponder) Recoverer(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const op = "JSONResponder.Recoverer"
+
+		tw, rw := newTrackingWriter(w)
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			err := errors.E(
+				errors.WithOp(op),
+				errors.Internal,
+				errors.WithData(PanicData{Value: v, Stack: string(debug.Stack())}),
+				withPanicValue(v),
+			)
+
+			if tw.wroteHeader {
+				jr.observeError(r, err)
+				return
+			}
+
+			jr.Error(r, w, err)
+		}()
+
+		h.ServeHTTP(rw, r)
+	})
+}
+
+// withPanicValue sets the panic value as the underlying error if it is
+// an error. Otherwise, it is interpolated into the Text.
+func withPanicValue(v interface{}) errors.Option {
+	if err, ok := v.(error); ok {
+		return errors.Options(errors.WithText("panic"), errors.WithErr(err))
+	}
+
+	return errors.WithTextf("panic: %v", v)
+}
@@ -0,0 +1,158 @@
+package httputil_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestJSONResponderRecoverer(t *testing.T) {
+	internalErrResp := response{
+		status:  http.StatusInternalServerError,
+		headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+		body:    []byte(`{"success":false,"msg":"","errors":[{"code":"INTERNAL","error":"internal error","msg":""}]}`),
+	}
+	cases := []struct {
+		name        string
+		h           http.HandlerFunc
+		want        response
+		wantObserve error
+	}{
+		{
+			name: "NoPanic",
+			h:    func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			want: response{status: http.StatusNoContent},
+		},
+		{
+			name: "PanicWithValue",
+			h:    func(http.ResponseWriter, *http.Request) { panic("boom") },
+			want: internalErrResp,
+			wantObserve: errors.E(
+				errors.WithOp("JSONResponder.Recoverer"),
+				errors.Internal,
+				errors.WithText("panic: boom"),
+			),
+		},
+		{
+			name: "PanicWithError",
+			h: func(http.ResponseWriter, *http.Request) {
+				panic(errors.E(errors.WithOp("explode"), errors.WithText("boom")))
+			},
+			want: internalErrResp,
+			wantObserve: errors.E(
+				errors.WithOp("JSONResponder.Recoverer"),
+				errors.Internal,
+				errors.WithText("panic"),
+				errors.WithErr(errors.E(errors.WithOp("explode"), errors.WithText("boom"))),
+			),
+		},
+		{
+			name: "PanicAfterWriteHeader",
+			h: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("boom")
+			},
+			want: response{status: http.StatusAccepted},
+			wantObserve: errors.E(
+				errors.WithOp("JSONResponder.Recoverer"),
+				errors.Internal,
+				errors.WithText("panic: boom"),
+			),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var observed error
+			jr := httputil.JSONResponder{
+				ErrObservers: []httputil.ErrorObserverFunc{
+					func(_ *http.Request, err error) { observed = err },
+				},
+			}
+
+			rec := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			jr.Recoverer(tc.h).ServeHTTP(rec, r)
+
+			if !matchErrors(tc.wantObserve, observed) {
+				t.Errorf("ErrorObserver.err diff: %s", errorDiff(tc.wantObserve, observed))
+			}
+			matchResponse(t, rec.Result(), tc.want)
+
+			if tc.wantObserve == nil {
+				return
+			}
+
+			var e *errors.Error
+			if !errors.As(observed, &e) {
+				t.Fatalf("ErrorObserver.err=%T; want *errors.Error", observed)
+			}
+			data, ok := e.Data.(httputil.PanicData)
+			if !ok {
+				t.Fatalf("Error.Data=%T; want httputil.PanicData", e.Data)
+			}
+			if !strings.Contains(data.Stack, "TestJSONResponderRecoverer") {
+				t.Errorf("PanicData.Stack does not include the panicking function:\n%s", data.Stack)
+			}
+		})
+	}
+
+	t.Run("Hijack", func(t *testing.T) {
+		observed := make(chan error, 1)
+		jr := httputil.JSONResponder{
+			ErrObservers: []httputil.ErrorObserverFunc{
+				func(_ *http.Request, err error) { observed <- err },
+			},
+		}
+
+		srv := httptest.NewServer(jr.Recoverer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("Hijack() error=%v", err)
+				return
+			}
+			defer conn.Close()
+
+			io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked") //nolint:errcheck
+			panic("boom")
+		})))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("http.Get() error=%v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("io.ReadAll() error=%v", err)
+		}
+		if resp.StatusCode != http.StatusOK || string(body) != "hijacked" {
+			t.Errorf("Response=%d %q; want=%d %q", resp.StatusCode, body, http.StatusOK, "hijacked")
+		}
+
+		want := errors.E(errors.WithOp("JSONResponder.Recoverer"), errors.Internal, errors.WithText("panic: boom"))
+		if err := <-observed; !errors.Match(want, err) {
+			t.Errorf("ErrorObserver.err diff: %s", errorDiff(want, err))
+		}
+	})
+
+	t.Run("ErrAbortHandler", func(t *testing.T) {
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Errorf("recover()=%v; want http.ErrAbortHandler", v)
+			}
+		}()
+
+		var jr httputil.JSONResponder
+		h := jr.Recoverer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
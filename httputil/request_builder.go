@@ -0,0 +1,253 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// RequestBuilder is used to build an HTTP request. The URL is built
+// using a URLBuilder while the method, headers and body are set on the
+// RequestBuilder.
+//
+// URLBuilderSource or Client should be used to create an instance of
+// RequestBuilder.
+//
+//	req, err := b.NewRequestBuilder().
+//		Method(http.MethodPost).
+//		Path("/users/{id}/posts").
+//		PathParam("id", id).
+//		BearerToken(token).
+//		JSON(post).
+//		Context(ctx).
+//		Build()
+//	if err != nil {
+//		// handle error
+//	}
+type RequestBuilder struct {
+	url    *URLBuilder
+	method string
+	header http.Header
+	ctx    context.Context
+	codec  JSONCodec
+	body   func(buf *bytes.Buffer) (contentType string, err error)
+}
+
+// NewRequestBuilder creates a new instance of RequestBuilder with the
+// base URL and query parameters carried over from URLBuilderSource.
+func (b URLBuilderSource) NewRequestBuilder() *RequestBuilder {
+	return &RequestBuilder{
+		url:    b.NewURLBuilder(),
+		header: make(http.Header),
+	}
+}
+
+// NewRequestBuilder creates a new instance of RequestBuilder for a URL
+// relative to the base URL of the Client. The JSON body is encoded
+// using the Codec of the Client and the default headers are sent when
+// the request is sent using Client.Do.
+func (c *Client) NewRequestBuilder() *RequestBuilder {
+	rb := c.urls.NewRequestBuilder()
+	rb.codec = c.Codec
+	return rb
+}
+
+// Method sets the HTTP method of the request. Defaults to GET.
+func (rb *RequestBuilder) Method(m string) *RequestBuilder {
+	rb.method = m
+	return rb
+}
+
+// Path sets the path template for the URL. See URLBuilder.Path.
+func (rb *RequestBuilder) Path(p string) *RequestBuilder {
+	rb.url.Path(p)
+	return rb
+}
+
+// PathParam sets the path parameter name and value which needs to be
+// substituted in the path template. See URLBuilder.PathParam.
+func (rb *RequestBuilder) PathParam(name, value string) *RequestBuilder {
+	rb.url.PathParam(name, value)
+	return rb
+}
+
+// PathParams sets the path parameter names and values which need to be
+// substituted in the path template. See URLBuilder.PathParams.
+func (rb *RequestBuilder) PathParams(params map[string]string) *RequestBuilder {
+	rb.url.PathParams(params)
+	return rb
+}
+
+// QueryParam sets the query parameter with the given values. If a value
+// was previously set, it is replaced. See URLBuilder.QueryParam.
+func (rb *RequestBuilder) QueryParam(key string, values ...string) *RequestBuilder {
+	rb.url.QueryParam(key, values...)
+	return rb
+}
+
+// QueryParams sets the query parameters. If a value was previously set
+// for any of the given parameters, it is replaced. See
+// URLBuilder.QueryParams.
+func (rb *RequestBuilder) QueryParams(params url.Values) *RequestBuilder {
+	rb.url.QueryParams(params)
+	return rb
+}
+
+// URLBuilder returns the URLBuilder used to build the URL of the
+// request. It can be used for setting the URL parameters not exposed on
+// the RequestBuilder.
+func (rb *RequestBuilder) URLBuilder() *URLBuilder { return rb.url }
+
+// Header sets the request header with the given values. If a value was
+// previously set, it is replaced.
+func (rb *RequestBuilder) Header(key string, values ...string) *RequestBuilder {
+	rb.header.Del(key)
+	for _, v := range values {
+		rb.header.Add(key, v)
+	}
+	return rb
+}
+
+// BasicAuth sets the Authorization header to use HTTP Basic
+// Authentication with the provided username and password.
+func (rb *RequestBuilder) BasicAuth(username, password string) *RequestBuilder {
+	creds := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	rb.header.Set("Authorization", "Basic "+creds)
+	return rb
+}
+
+// BearerToken sets the Authorization header to use the bearer token.
+func (rb *RequestBuilder) BearerToken(token string) *RequestBuilder {
+	rb.header.Set("Authorization", "Bearer "+token)
+	return rb
+}
+
+// Context sets the context of the request. Defaults to
+// context.Background().
+func (rb *RequestBuilder) Context(ctx context.Context) *RequestBuilder {
+	rb.ctx = ctx
+	return rb
+}
+
+// JSON sets the value to be encoded as JSON for the request body.
+// Interface upgrade to xgo.JSONer is supported. The value is encoded
+// when the request is built.
+func (rb *RequestBuilder) JSON(v interface{}) *RequestBuilder {
+	rb.body = func(buf *bytes.Buffer) (string, error) {
+		if err := jsonCodecOrDefault(rb.codec).NewEncoder(buf).Encode(jsonBody(v)); err != nil {
+			return "", errors.E(errors.Internal, errors.WithText("encode request body"), errors.WithErr(err))
+		}
+		return contentTypeJSON, nil
+	}
+	return rb
+}
+
+// Form sets the URL encoded form values as the request body.
+func (rb *RequestBuilder) Form(values url.Values) *RequestBuilder {
+	rb.body = func(buf *bytes.Buffer) (string, error) {
+		buf.WriteString(values.Encode())
+		return "application/x-www-form-urlencoded", nil
+	}
+	return rb
+}
+
+// Multipart sets the multipart/form-data request body. fn is called
+// with the multipart.Writer when the request is built to write the
+// fields and files. The writer is closed after fn returns.
+//
+//	rb.Multipart(func(w *multipart.Writer) error {
+//		if err := w.WriteField("title", title); err != nil {
+//			return err
+//		}
+//
+//		fw, err := w.CreateFormFile("file", "report.pdf")
+//		if err != nil {
+//			return err
+//		}
+//
+//		_, err = io.Copy(fw, f)
+//		return err
+//	})
+func (rb *RequestBuilder) Multipart(fn func(w *multipart.Writer) error) *RequestBuilder {
+	rb.body = func(buf *bytes.Buffer) (string, error) {
+		mw := multipart.NewWriter(buf)
+		if err := fn(mw); err != nil {
+			return "", errors.E(errors.Internal, errors.WithText("write multipart body"), errors.WithErr(err))
+		}
+		if err := mw.Close(); err != nil {
+			return "", errors.E(errors.Internal, errors.WithText("close multipart body"), errors.WithErr(err))
+		}
+		return mw.FormDataContentType(), nil
+	}
+	return rb
+}
+
+// Body sets the request body along with the content type. The reader is
+// read fully when the request is built so that the ContentLength and
+// GetBody of the request can be set.
+func (rb *RequestBuilder) Body(contentType string, r io.Reader) *RequestBuilder {
+	rb.body = func(buf *bytes.Buffer) (string, error) {
+		if _, err := io.Copy(buf, r); err != nil {
+			return "", errors.E(errors.Internal, errors.WithText("read request body"), errors.WithErr(err))
+		}
+		return contentType, nil
+	}
+	return rb
+}
+
+// Build constructs and returns the HTTP request.
+//
+// The URL is built using URLBuilder.Build. If the request body could
+// not be encoded, the error is of kind errors.Internal. If the URL or
+// the request could not be constructed, for example because a path
+// parameter is missing or the method is invalid, the error is of kind
+// errors.InvalidInput.
+func (rb *RequestBuilder) Build() (*http.Request, error) {
+	const op = "RequestBuilder.Build"
+
+	var (
+		body        io.Reader
+		contentType string
+	)
+	if rb.body != nil {
+		var buf bytes.Buffer
+		ct, err := rb.body(&buf)
+		if err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+		body, contentType = bytes.NewReader(buf.Bytes()), ct
+	}
+
+	method := rb.method
+	if method == "" {
+		method = http.MethodGet
+	}
+	ctx := rb.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	u, err := rb.url.Build()
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+	}
+
+	for k, vv := range rb.header {
+		r.Header[k] = append([]string(nil), vv...)
+	}
+	if contentType != "" && r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	return r, nil
+}
@@ -0,0 +1,344 @@
+package httputil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// Defaults used by RetryingTransport for the zero values of
+// RetryOptions.
+const (
+	DefaultMaxRetries    = 3
+	DefaultMinBackoff    = 100 * time.Millisecond
+	DefaultMaxBackoff    = 5 * time.Second
+	DefaultMaxRetryAfter = 30 * time.Second
+)
+
+// RetryOptions configures the middleware returned by
+// RetryingTransport.
+type RetryOptions struct {
+	// MaxRetries is the maximum number of times a request is retried.
+	// DefaultMaxRetries is used if MaxRetries is zero. Requests are not
+	// retried if MaxRetries is negative.
+	MaxRetries int
+
+	// MinBackoff and MaxBackoff bound the wait before a retry. The wait
+	// doubles with each retry starting from MinBackoff, is capped at
+	// MaxBackoff and is randomised by up to half of its value without
+	// going below MinBackoff. DefaultMinBackoff and DefaultMaxBackoff are
+	// used if zero.
+	MinBackoff, MaxBackoff time.Duration
+
+	// MaxRetryAfter is the longest wait requested by the Retry-After
+	// header of a response which is honoured. If the server asks to wait
+	// longer, the response is returned without retrying.
+	// DefaultMaxRetryAfter is used if zero.
+	MaxRetryAfter time.Duration
+
+	// RetryableKinds is the list of error kinds for which the request is
+	// retried. The kind of a response is derived from the status using
+	// errors.KindFromStatus and that of a transient connection error is
+	// errors.Unavailable or errors.DeadlineExceeded if it timed out.
+	// Other errors, such as a failed certificate verification or an
+	// error returned by a wrapped middleware, are never retried.
+	// Defaults to errors.Unavailable, errors.ResourceExhausted and
+	// errors.DeadlineExceeded if empty.
+	RetryableKinds []errors.Kind
+
+	// Budget limits the retries across requests. A RetryBudget created
+	// using NewRetryBudget(10, 0.1) is used if nil. The same RetryBudget
+	// can be shared between transports for the same upstream.
+	Budget *RetryBudget
+
+	// Clock is used to wait between retries and to resolve the
+	// Retry-After header. The system clock is used if nil.
+	Clock Clock
+}
+
+var defaultRetryableKinds = []errors.Kind{errors.Unavailable, errors.ResourceExhausted, errors.DeadlineExceeded}
+
+// RetryingTransport returns a middleware for http.RoundTripper which
+// retries failed requests with exponential backoff and jitter.
+//
+// A request is retried if it failed with a transient connection error,
+// such as a failed dial, a reset connection or a timeout, or if the
+// Kind derived from the response status is one of RetryableKinds. Only
+// requests with an idempotent method, as defined in RFC 9110, or with
+// the Idempotency-Key header are retried. The Retry-After header of the
+// response, if present, takes precedence over the backoff. The request
+// body is replayed using Request.GetBody, which is set by
+// http.NewRequest and RequestBuilder. Requests with a body but without
+// GetBody are not retried.
+//
+// When the retries are exhausted or denied by the budget, the last
+// response or error is returned as is. The same applies if the context
+// of the request is done, in which case the attempt does not count
+// towards the budget.
+//
+//	client := &http.Client{
+//		Transport: httputil.RetryingTransport(httputil.RetryOptions{})(http.DefaultTransport),
+//	}
+func RetryingTransport(opts RetryOptions) func(http.RoundTripper) http.RoundTripper {
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.MaxRetryAfter == 0 {
+		opts.MaxRetryAfter = DefaultMaxRetryAfter
+	}
+	if len(opts.RetryableKinds) == 0 {
+		opts.RetryableKinds = defaultRetryableKinds
+	}
+	if opts.Budget == nil {
+		opts.Budget = NewRetryBudget(10, 0.1)
+	}
+	opts.Clock = clockOrDefault(opts.Clock)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &retryTransport{next: next, opts: opts}
+	}
+}
+
+type retryTransport struct {
+	next http.RoundTripper
+	opts RetryOptions
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !retryableRequest(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(r)
+
+		// The outcome of the attempt says little about the health of the
+		// upstream if the context is done, and there is no point in
+		// retrying.
+		if req.Context().Err() != nil {
+			return resp, err
+		}
+
+		if !t.retryableResult(resp, err) {
+			// An error which is not retried, such as a failed
+			// certificate verification, says little about the health
+			// of the upstream either.
+			if err == nil {
+				t.opts.Budget.success()
+			}
+			return resp, err
+		}
+		t.opts.Budget.failure()
+
+		wait, ok := t.backoff(resp, attempt)
+		if !ok || !t.opts.Budget.allow() {
+			return resp, err
+		}
+
+		if resp != nil {
+			drainBody(resp.Body)
+		}
+
+		select {
+		case <-t.opts.Clock.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryableRequest reports whether the request can be sent again.
+func retryableRequest(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, ok := req.Header["Idempotency-Key"]
+	return ok
+}
+
+func (t *retryTransport) retryableResult(resp *http.Response, err error) bool {
+	var kind errors.Kind
+	if err != nil {
+		var ok bool
+		if kind, ok = transientErrKind(err); !ok {
+			return false
+		}
+	} else {
+		kind = errors.KindFromStatus(resp.StatusCode)
+	}
+
+	for _, k := range t.opts.RetryableKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// transientErrKind returns the Kind of a transport error which may not
+// recur when the request is sent again: a timeout or a connection error
+// such as a failed dial or a reset connection. It reports false for any
+// other error, such as a failed certificate verification, an invalid
+// URL or an error which already has a Kind, set by a wrapped
+// RoundTripper for instance.
+func transientErrKind(err error) (errors.Kind, bool) {
+	var (
+		uaErr x509.UnknownAuthorityError
+		ciErr x509.CertificateInvalidError
+		hErr  x509.HostnameError
+		rhErr tls.RecordHeaderError
+		ne    net.Error
+		opErr *net.OpError
+	)
+	switch {
+	case errors.WhatKind(err) != errors.Unknown,
+		errors.As(err, &uaErr), errors.As(err, &ciErr), errors.As(err, &hErr), errors.As(err, &rhErr):
+		return errors.Unknown, false
+
+	case errors.As(err, &ne) && ne.Timeout():
+		return errors.DeadlineExceeded, true
+
+	case errors.As(err, &opErr),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.Unavailable, true
+	}
+	return errors.Unknown, false
+}
+
+// backoff returns the duration to wait before the next attempt. It
+// reports false if the request should not be retried.
+func (t *retryTransport) backoff(resp *http.Response, attempt int) (time.Duration, bool) {
+	if attempt >= t.opts.MaxRetries {
+		return 0, false
+	}
+
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After"), t.opts.Clock.Now()); ok {
+			return d, d <= t.opts.MaxRetryAfter
+		}
+	}
+
+	d := t.opts.MinBackoff
+	for i := 0; i < attempt && d < t.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > t.opts.MaxBackoff {
+		d = t.opts.MaxBackoff
+	}
+
+	lo := d / 2
+	if lo < t.opts.MinBackoff {
+		lo = t.opts.MinBackoff
+	}
+	if lo >= d {
+		return lo, true
+	}
+	return lo + time.Duration(rand.Int63n(int64(d-lo)+1)), true //nolint:gosec
+}
+
+// retryAfter parses the value of the Retry-After header which is either
+// the delay in seconds or an HTTP-date.
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// RetryBudget limits the retries across requests to avoid overloading
+// an upstream which is already failing, which is also known as a retry
+// storm. It follows the retry throttling scheme used by gRPC.
+//
+// The budget starts with maxTokens tokens. Each failed attempt takes
+// away a token and each successful attempt adds tokenRatio tokens, up
+// to maxTokens. Retries are allowed only while more than half of
+// maxTokens remain. With a tokenRatio of 0.1, once throttled, about one
+// retry is allowed for every 10 successful requests.
+//
+// RetryBudget is safe for concurrent use.
+type RetryBudget struct {
+	mu         sync.Mutex
+	tokens     float64
+	maxTokens  float64
+	tokenRatio float64
+}
+
+// NewRetryBudget creates a RetryBudget with the maximum number of tokens
+// and the number of tokens added for each successful attempt.
+func NewRetryBudget(maxTokens, tokenRatio float64) *RetryBudget {
+	return &RetryBudget{tokens: maxTokens, maxTokens: maxTokens, tokenRatio: tokenRatio}
+}
+
+func (b *RetryBudget) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.tokens > b.maxTokens/2
+}
+
+func (b *RetryBudget) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens += b.tokenRatio
+	if b.tokens > b.maxTokens {
+		b.tokens = b.maxTokens
+	}
+}
+
+func (b *RetryBudget) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens--
+	if b.tokens < 0 {
+		b.tokens = 0
+	}
+}
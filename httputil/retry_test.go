@@ -0,0 +1,480 @@
+package httputil_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+// fakeClock is a Clock which advances the time instantly when waiting.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2021, 3, 31, 10, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// Advance moves the time forward without recording a wait.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func (c *fakeClock) Waits() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]time.Duration(nil), c.waits...)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// scriptedResponse is a response sent by the test server.
+type scriptedResponse struct {
+	status     int
+	retryAfter string
+}
+
+// scriptedServer responds with the scripted responses in order,
+// repeating the last one, and records the request bodies.
+type scriptedServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	script []scriptedResponse
+	bodies []string
+}
+
+func newScriptedServer(t *testing.T, script ...scriptedResponse) *scriptedServer {
+	s := scriptedServer{script: script}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		res := s.script[len(s.script)-1]
+		if len(s.bodies) < len(s.script) {
+			res = s.script[len(s.bodies)]
+		}
+		s.bodies = append(s.bodies, string(b))
+		s.mu.Unlock()
+
+		if res.retryAfter != "" {
+			w.Header().Set("Retry-After", res.retryAfter)
+		}
+		w.WriteHeader(res.status)
+		io.WriteString(w, http.StatusText(res.status)) //nolint:errcheck
+	}))
+	t.Cleanup(s.Close)
+	return &s
+}
+
+func (s *scriptedServer) Bodies() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.bodies...)
+}
+
+func TestRetryingTransport(t *testing.T) {
+	unavailable := scriptedResponse{status: http.StatusServiceUnavailable}
+	ok := scriptedResponse{status: http.StatusOK}
+
+	cases := []struct {
+		name       string
+		opts       httputil.RetryOptions
+		script     []scriptedResponse
+		method     string
+		header     http.Header
+		body       string
+		wantStatus int
+		wantWaits  [][2]time.Duration // lower and upper bounds
+	}{
+		{
+			name:       "Success",
+			script:     []scriptedResponse{ok},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Unavailable",
+			script:     []scriptedResponse{unavailable, unavailable, ok},
+			wantStatus: http.StatusOK,
+			wantWaits:  [][2]time.Duration{{100 * time.Millisecond, 100 * time.Millisecond}, {100 * time.Millisecond, 200 * time.Millisecond}},
+		},
+		{
+			name:       "BackoffCapped",
+			opts:       httputil.RetryOptions{MaxRetries: 4, MinBackoff: time.Second, MaxBackoff: 2 * time.Second},
+			script:     []scriptedResponse{unavailable, unavailable, unavailable, ok},
+			wantStatus: http.StatusOK,
+			wantWaits:  [][2]time.Duration{{time.Second, time.Second}, {time.Second, 2 * time.Second}, {time.Second, 2 * time.Second}},
+		},
+		{
+			name:       "RetryAfterSeconds",
+			script:     []scriptedResponse{{status: http.StatusTooManyRequests, retryAfter: "2"}, ok},
+			wantStatus: http.StatusOK,
+			wantWaits:  [][2]time.Duration{{2 * time.Second, 2 * time.Second}},
+		},
+		{
+			name:       "RetryAfterDate",
+			script:     []scriptedResponse{{status: http.StatusServiceUnavailable, retryAfter: "Wed, 31 Mar 2021 10:00:03 GMT"}, ok},
+			wantStatus: http.StatusOK,
+			wantWaits:  [][2]time.Duration{{3 * time.Second, 3 * time.Second}},
+		},
+		{
+			name:       "RetryAfterTooLong",
+			script:     []scriptedResponse{{status: http.StatusServiceUnavailable, retryAfter: "120"}, ok},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "NotRetryableKind",
+			script:     []scriptedResponse{{status: http.StatusInternalServerError}, ok},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "CustomKinds",
+			opts:       httputil.RetryOptions{RetryableKinds: []errors.Kind{errors.Internal}},
+			script:     []scriptedResponse{{status: http.StatusInternalServerError}, ok},
+			wantStatus: http.StatusOK,
+			wantWaits:  [][2]time.Duration{{100 * time.Millisecond, 100 * time.Millisecond}},
+		},
+		{
+			name:       "Exhausted",
+			opts:       httputil.RetryOptions{MaxRetries: 2},
+			script:     []scriptedResponse{unavailable},
+			wantStatus: http.StatusServiceUnavailable,
+			wantWaits:  [][2]time.Duration{{100 * time.Millisecond, 100 * time.Millisecond}, {100 * time.Millisecond, 200 * time.Millisecond}},
+		},
+		{
+			name:       "Disabled",
+			opts:       httputil.RetryOptions{MaxRetries: -1},
+			script:     []scriptedResponse{unavailable, ok},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "NonIdempotent",
+			method:     http.MethodPost,
+			body:       `{"name":"Donald"}`,
+			script:     []scriptedResponse{unavailable, ok},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "IdempotencyKey",
+			method:     http.MethodPost,
+			header:     http.Header{"Idempotency-Key": {"8e03978e"}},
+			body:       `{"name":"Donald"}`,
+			script:     []scriptedResponse{unavailable, ok},
+			wantStatus: http.StatusOK,
+			wantWaits:  [][2]time.Duration{{100 * time.Millisecond, 100 * time.Millisecond}},
+		},
+		{
+			name:       "Budget",
+			opts:       httputil.RetryOptions{MaxRetries: 10, Budget: httputil.NewRetryBudget(4, 1)},
+			script:     []scriptedResponse{unavailable},
+			wantStatus: http.StatusServiceUnavailable,
+			wantWaits:  [][2]time.Duration{{100 * time.Millisecond, 100 * time.Millisecond}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newScriptedServer(t, tc.script...)
+
+			clock := newFakeClock()
+			tc.opts.Clock = clock
+			hc := &http.Client{Transport: httputil.RetryingTransport(tc.opts)(http.DefaultTransport)}
+
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req, err := http.NewRequest(method, srv.URL, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("NewRequest() error=%v", err)
+			}
+			for k, vv := range tc.header {
+				req.Header[k] = vv
+			}
+
+			resp, err := hc.Do(req)
+			if err != nil {
+				t.Fatalf("Client.Do() error=%v", err)
+			}
+			defer resp.Body.Close()
+
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("ReadAll() error=%v", err)
+			}
+			if resp.StatusCode != tc.wantStatus || string(b) != http.StatusText(tc.wantStatus) {
+				t.Errorf("Response=%d %q; want=%d %q", resp.StatusCode, b, tc.wantStatus, http.StatusText(tc.wantStatus))
+			}
+
+			waits := clock.Waits()
+			if len(waits) != len(tc.wantWaits) {
+				t.Fatalf("Waits=%v; want %d waits", waits, len(tc.wantWaits))
+			}
+			for i, w := range waits {
+				if w < tc.wantWaits[i][0] || w > tc.wantWaits[i][1] {
+					t.Errorf("Wait %d=%s; want between %s and %s", i+1, w, tc.wantWaits[i][0], tc.wantWaits[i][1])
+				}
+			}
+
+			bodies := srv.Bodies()
+			if len(bodies) != len(waits)+1 {
+				t.Errorf("Attempts=%d; want=%d", len(bodies), len(waits)+1)
+			}
+			for i, body := range bodies {
+				if body != tc.body {
+					t.Errorf("Attempt %d body=%q; want=%q", i+1, body, tc.body)
+				}
+			}
+		})
+	}
+
+	t.Run("ConnectionError", func(t *testing.T) {
+		srv := newScriptedServer(t, ok)
+
+		var attempts int
+		next := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts == 1 {
+				return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+			}
+			return http.DefaultTransport.RoundTrip(r)
+		})
+
+		clock := newFakeClock()
+		hc := &http.Client{Transport: httputil.RetryingTransport(httputil.RetryOptions{Clock: clock})(next)}
+		resp, err := hc.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Client.Get() error=%v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK || attempts != 2 {
+			t.Errorf("Status=%d, attempts=%d; want=%d, %d", resp.StatusCode, attempts, http.StatusOK, 2)
+		}
+	})
+
+	t.Run("NotTransientError", func(t *testing.T) {
+		srv := newScriptedServer(t, ok)
+		tlsSrv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		tlsSrv.Config.ErrorLog = log.New(io.Discard, "", 0)
+		tlsSrv.StartTLS()
+		t.Cleanup(tlsSrv.Close)
+
+		cases := []struct {
+			name string
+			url  string
+			next http.RoundTripper
+		}{
+			{
+				name: "UnknownAuthority",
+				url:  tlsSrv.URL,
+				next: http.DefaultTransport,
+			},
+			{
+				name: "UnsupportedScheme",
+				url:  "ftp://example.com",
+				next: http.DefaultTransport,
+			},
+			{
+				name: "KindSet",
+				url:  srv.URL,
+				next: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+					return nil, errors.E(errors.ResourceExhausted, errors.WithText("wait for rate limit"))
+				}),
+			},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				var attempts int
+				next := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+					attempts++
+					return tc.next.RoundTrip(r)
+				})
+
+				// The budget allows a retry only if no attempt failed before.
+				budget := httputil.NewRetryBudget(3, 0)
+				clock := newFakeClock()
+				hc := &http.Client{Transport: httputil.RetryingTransport(httputil.RetryOptions{Budget: budget, Clock: clock})(next)}
+				if _, err := hc.Get(tc.url); err == nil {
+					t.Fatal("Client.Get() error=nil; want non-nil")
+				}
+				if attempts != 1 {
+					t.Errorf("Attempts=%d; want=1", attempts)
+				}
+
+				// The budget is untouched and the next request is retried.
+				attempts = 0
+				next = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+					attempts++
+					if attempts == 1 {
+						return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+					}
+					return http.DefaultTransport.RoundTrip(r)
+				})
+				hc.Transport = httputil.RetryingTransport(httputil.RetryOptions{Budget: budget, Clock: clock})(next)
+				resp, err := hc.Get(srv.URL)
+				if err != nil {
+					t.Fatalf("Client.Get() error=%v", err)
+				}
+				resp.Body.Close()
+
+				if attempts != 2 {
+					t.Errorf("Attempts=%d; want=2", attempts)
+				}
+			})
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		srv := newScriptedServer(t, unavailable)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		clock := newFakeClock()
+		hc := &http.Client{Transport: httputil.RetryingTransport(httputil.RetryOptions{Clock: clock})(http.DefaultTransport)}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatalf("NewRequest() error=%v", err)
+		}
+
+		if _, err := hc.Do(req); !errors.Is(err, context.Canceled) {
+			t.Errorf("Client.Do() error=%v; want context.Canceled", err)
+		}
+		if waits := clock.Waits(); len(waits) != 0 {
+			t.Errorf("Waits=%v; want none", waits)
+		}
+	})
+
+	t.Run("ContextDoneDuringAttempt", func(t *testing.T) {
+		var attempts int
+		next := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			attempts++
+			if cancel, ok := r.Context().Value(cancelKey{}).(context.CancelFunc); ok {
+				cancel()
+			}
+			status := http.StatusServiceUnavailable
+			if attempts == 3 {
+				status = http.StatusOK
+			}
+			return &http.Response{StatusCode: status, Body: http.NoBody, Request: r}, nil
+		})
+
+		// Two failed attempts exhaust the budget.
+		opts := httputil.RetryOptions{Clock: newFakeClock(), Budget: httputil.NewRetryBudget(4, 0)}
+		hc := &http.Client{Transport: httputil.RetryingTransport(opts)(next)}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		req, err := http.NewRequestWithContext(context.WithValue(ctx, cancelKey{}, cancel), http.MethodGet, "https://api.example.com", nil)
+		if err != nil {
+			t.Fatalf("NewRequest() error=%v", err)
+		}
+		resp, err := hc.Do(req)
+		if err != nil {
+			t.Fatalf("Client.Do() error=%v", err)
+		}
+		resp.Body.Close()
+		if attempts != 1 {
+			t.Errorf("Attempts=%d; want=%d", attempts, 1)
+		}
+
+		// The budget is intact for the next request.
+		resp, err = hc.Get("https://api.example.com")
+		if err != nil {
+			t.Fatalf("Client.Get() error=%v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || attempts != 3 {
+			t.Errorf("Status=%d, attempts=%d; want=%d, %d", resp.StatusCode, attempts, http.StatusOK, 3)
+		}
+	})
+
+	t.Run("LargeBodyDrained", func(t *testing.T) {
+		var bodies []*countingReader
+		next := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			body := &countingReader{r: io.LimitReader(fillReader{}, 10<<20)}
+			bodies = append(bodies, body)
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(body), Request: r}, nil
+		})
+
+		opts := httputil.RetryOptions{MaxRetries: 1, Clock: newFakeClock()}
+		hc := &http.Client{Transport: httputil.RetryingTransport(opts)(next)}
+		resp, err := hc.Get("https://api.example.com")
+		if err != nil {
+			t.Fatalf("Client.Get() error=%v", err)
+		}
+		resp.Body.Close()
+
+		// The body of the retried response is discarded only up to a
+		// limit rather than read in full.
+		if len(bodies) != 2 || bodies[0].n >= 1<<20 {
+			t.Errorf("Attempts=%d, drained=%d bytes; want 2 attempts, less than 1 MiB drained", len(bodies), bodies[0].n)
+		}
+	})
+
+	t.Run("SharedBudget", func(t *testing.T) {
+		srv := newScriptedServer(t, unavailable)
+
+		clock := newFakeClock()
+		opts := httputil.RetryOptions{Clock: clock, Budget: httputil.NewRetryBudget(10, 0.1)}
+		hc := &http.Client{Transport: httputil.RetryingTransport(opts)(http.DefaultTransport)}
+
+		for i := 0; i < 4; i++ {
+			resp, err := hc.Get(srv.URL)
+			if err != nil {
+				t.Fatalf("Client.Get() error=%v", err)
+			}
+			resp.Body.Close()
+		}
+
+		// The budget allows retries while more than 5 of the 10 tokens
+		// remain and each failed attempt takes away a token. The first
+		// request exhausts its 3 retries, leaving 6 tokens, and the
+		// following requests are not retried.
+		if got := len(srv.Bodies()); got != 7 {
+			t.Errorf("Attempts=%d; want=%d", got, 7)
+		}
+	})
+}
+
+// cancelKey is the context key for the function which cancels the
+// context of the request.
+type cancelKey struct{}
@@ -0,0 +1,188 @@
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestURLVerifierVerify(t *testing.T) {
+	b, err := httputil.NewURLBuilderSource("https://api.example.com/v1")
+	if err != nil {
+		t.Fatalf("NewURLBuilderSource(): %s", err)
+	}
+
+	oldKey := httputil.URLSigningKey{ID: "2021-02", Secret: []byte("old-secret")}
+	newKey := httputil.URLSigningKey{ID: "2021-03", Secret: []byte("new-secret")}
+	v := httputil.URLVerifier{Keys: []httputil.URLSigningKey{newKey, oldKey}}
+
+	sign := func(t *testing.T, key httputil.URLSigningKey, expiresAt time.Time) *url.URL {
+		t.Helper()
+
+		u, err := b.NewURLBuilder().
+			Path("/downloads/{id}").
+			PathParam("id", "report 1.pdf").
+			QueryParam("disposition", "attachment").
+			Sign(key, expiresAt)
+		if err != nil {
+			t.Fatalf("URLBuilder.Sign() error=%v", err)
+		}
+		return u
+	}
+
+	valid := sign(t, newKey, time.Now().Add(time.Hour))
+
+	const op = "URLVerifier.Verify"
+	cases := []struct {
+		name     string
+		url      func() string
+		wantErr  error
+		wantCode int
+	}{
+		{
+			name:     "Valid",
+			url:      valid.String,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "RotatedKey",
+			url:      sign(t, oldKey, time.Now().Add(time.Hour)).String,
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "Unsigned",
+			url: func() string {
+				return b.NewURLBuilder().Path("/downloads/{id}").PathParam("id", "report 1.pdf").URL().String()
+			},
+			wantErr:  errors.E(errors.WithOp(op), errors.Unauthenticated, errors.WithUserMsg("The URL is not signed")),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "TamperedPath",
+			url: func() string {
+				u := *valid
+				u.Path = "/v1/downloads/report 2.pdf"
+				return u.String()
+			},
+			wantErr:  errors.E(errors.WithOp(op), errors.PermissionDenied, errors.WithUserMsg("The URL signature is invalid")),
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name: "TamperedQuery",
+			url: func() string {
+				u := *valid
+				qry := u.Query()
+				qry.Set("disposition", "inline")
+				u.RawQuery = qry.Encode()
+				return u.String()
+			},
+			wantErr:  errors.E(errors.WithOp(op), errors.PermissionDenied, errors.WithUserMsg("The URL signature is invalid")),
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name: "ExtendedExpiry",
+			url: func() string {
+				u := *valid
+				qry := u.Query()
+				qry.Set("expires", "4102444800")
+				u.RawQuery = qry.Encode()
+				return u.String()
+			},
+			wantErr:  errors.E(errors.WithOp(op), errors.PermissionDenied, errors.WithUserMsg("The URL signature is invalid")),
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Expired",
+			url:      sign(t, newKey, time.Now().Add(-time.Minute)).String,
+			wantErr:  errors.E(errors.WithOp(op), errors.PermissionDenied, errors.WithUserMsg("The URL has expired")),
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name: "UnknownKey",
+			url:  sign(t, httputil.URLSigningKey{ID: "2020-12", Secret: []byte("retired")}, time.Now().Add(time.Hour)).String,
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.PermissionDenied,
+				errors.WithText(`unknown signing key "2020-12"`),
+				errors.WithUserMsg("The URL signature is invalid"),
+			),
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.url(), nil)
+
+			err := v.Verify(r)
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("URLVerifier.Verify() error diff: %s", errorDiff(tc.wantErr, err))
+			}
+
+			rec := httptest.NewRecorder()
+			v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rec, r)
+			if rec.Code != tc.wantCode {
+				t.Errorf("URLVerifier.Middleware() status=%d; want=%d", rec.Code, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestURLBuilderSign(t *testing.T) {
+	b, err := httputil.NewURLBuilderSource("https://api.example.com/v1")
+	if err != nil {
+		t.Fatalf("NewURLBuilderSource(): %s", err)
+	}
+
+	const op = "URLBuilder.Sign"
+	key := httputil.URLSigningKey{ID: "2021-03", Secret: []byte("secret")}
+	expiresAt := time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name    string
+		u       *httputil.URLBuilder
+		key     httputil.URLSigningKey
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Signed",
+			u:    b.NewURLBuilder().Path("/downloads/{id}").PathParam("id", "42"),
+			key:  key,
+			want: "https://api.example.com/v1/downloads/42?expires=1617148800&key_id=2021-03&signature=S1ijRrBl4OhcPJxe4VMIvLa0EPwnOrkKnr95IxblGp4",
+		},
+		{
+			name:    "InvalidKey",
+			u:       b.NewURLBuilder().Path("/downloads/42"),
+			key:     httputil.URLSigningKey{ID: "2021-03"},
+			wantErr: errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("signing key must have an ID and a secret")),
+		},
+		{
+			name:    "MissingPathParam",
+			u:       b.NewURLBuilder().Path("/downloads/{id}"),
+			key:     key,
+			wantErr: errors.E(errors.WithOp(op), errors.InvalidInput),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := tc.u.Sign(tc.key, expiresAt)
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("URLBuilder.Sign() error diff: %s", errorDiff(tc.wantErr, err))
+			}
+			if err != nil {
+				return
+			}
+
+			if u.String() != tc.want {
+				t.Errorf("URLBuilder.Sign()=%s; want=%s", u, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// SSEEvent is an event sent using Server-Sent Events.
+type SSEEvent struct {
+	// ID is the event id. The client sends the id of the last event
+	// received in the Last-Event-ID header when reconnecting. Optional.
+	ID string
+
+	// Event is the event type. The client treats the event as a
+	// "message" if empty. Optional.
+	Event string
+
+	// Retry is the reconnection time to be used by the client. It is
+	// sent in milliseconds. Optional.
+	Retry time.Duration
+
+	// Data is encoded as JSON and sent as the event data. Interface
+	// upgrade to xgo.JSONer is supported. The data field is omitted if
+	// Data is nil.
+	Data interface{}
+}
+
+// SSEResponder streams events to the client using Server-Sent Events
+// (text/event-stream).
+type SSEResponder struct {
+	// Responder is used to encode the event data using its Codec and to
+	// notify its ErrObservers of errors. Optional.
+	Responder *JSONResponder
+
+	// HeartbeatInterval is the interval at which a comment is sent to
+	// keep the connection alive when no events are sent. The interval is
+	// restarted with each event. Heartbeats are not sent if
+	// HeartbeatInterval is zero.
+	HeartbeatInterval time.Duration
+}
+
+// Respond starts a Server-Sent Events stream and calls fn to send the
+// events. The stream ends when fn returns and SSEStream.Send fails
+// after that.
+//
+// The status line and headers are written, and the heartbeats are
+// started, before fn is called. So the error returned by fn cannot be
+// sent as the error response. The ErrObservers are notified of it
+// instead. Errors which should be sent to the client, such as for an
+// unknown resource, must be handled before calling Respond.
+//
+// SSEStream.LastEventID can be used to resume the stream from where the
+// client left off. When the client disconnects, the context of the
+// stream is done and SSEStream.Send fails with an error of kind
+// errors.Canceled.
+//
+//	updates, err := svc.Progress(r.Context(), jobID, r.Header.Get("Last-Event-ID"))
+//	if err != nil {
+//		responder.Error(r, w, err)
+//		return
+//	}
+//
+//	sse.Respond(r, w, func(s *httputil.SSEStream) error {
+//		for u := range updates {
+//			if err := s.Send(httputil.SSEEvent{ID: u.ID, Event: "progress", Data: u}); err != nil {
+//				return err
+//			}
+//		}
+//		return nil
+//	})
+func (sr *SSEResponder) Respond(r *http.Request, w http.ResponseWriter, fn func(s *SSEStream) error) {
+	const op = "SSEResponder.Respond"
+
+	jr := sr.Responder
+	if jr == nil {
+		jr = &JSONResponder{}
+	}
+
+	s := &SSEStream{
+		ctx:       r.Context(),
+		r:         r,
+		w:         w,
+		flusher:   flusherOf(w),
+		codec:     jsonCodecOrDefault(jr.Codec),
+		heartbeat: sr.HeartbeatInterval,
+		done:      make(chan struct{}),
+	}
+	s.start()
+	err := fn(s)
+	s.stop()
+
+	if err != nil {
+		jr.observeError(r, errors.E(
+			errors.WithOp(op),
+			errors.WithText("response already written, stream cut short"),
+			errors.WithErr(err),
+		))
+	}
+}
+
+// SSEStream sends events to the client. It is safe for concurrent use.
+type SSEStream struct {
+	ctx       context.Context
+	r         *http.Request
+	w         http.ResponseWriter
+	flusher   http.Flusher
+	codec     JSONCodec
+	heartbeat time.Duration
+
+	mu     sync.Mutex
+	ticker *time.Ticker
+	closed bool
+	err    error
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// Context returns the request context. It is done when the client
+// disconnects.
+func (s *SSEStream) Context() context.Context { return s.ctx }
+
+// LastEventID returns the value of the Last-Event-ID header sent by the
+// client when reconnecting. It is empty for a new connection.
+func (s *SSEStream) LastEventID() string { return s.r.Header.Get("Last-Event-ID") }
+
+// Send writes the event and flushes the response. It fails once the
+// stream has ended.
+func (s *SSEStream) Send(ev SSEEvent) error {
+	if strings.ContainsAny(ev.ID, "\r\n\x00") || strings.ContainsAny(ev.Event, "\r\n") {
+		return errors.E(errors.Internal, errors.WithTextf("invalid event id %q or type %q", ev.ID, ev.Event))
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if ev.ID != "" {
+		buf.WriteString("id: " + ev.ID + "\n")
+	}
+	if ev.Event != "" {
+		buf.WriteString("event: " + ev.Event + "\n")
+	}
+	if ev.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+	if ev.Data != nil {
+		if err := s.writeData(buf, ev.Data); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.E(errors.Internal, errors.WithText("send event on closed stream"))
+	}
+	if err := s.write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	// A heartbeat is only required if no event is sent for the interval.
+	if s.ticker != nil {
+		s.ticker.Reset(s.heartbeat)
+	}
+	return nil
+}
+
+func (s *SSEStream) writeData(buf *bytes.Buffer, v interface{}) error {
+	data := getBuffer()
+	defer putBuffer(data)
+
+	if err := s.codec.NewEncoder(data).Encode(jsonBody(v)); err != nil {
+		return errors.E(errors.Internal, errors.WithText("encode event data"), errors.WithErr(err))
+	}
+
+	// Each line of the data, which can span multiple lines if it is
+	// indented, must be sent as a separate data field.
+	for _, line := range bytes.Split(bytes.TrimRight(data.Bytes(), "\n"), []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return nil
+}
+
+// start writes the headers and starts the heartbeats.
+func (s *SSEStream) start() {
+	h := s.w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	// Disable response buffering in proxies such as nginx.
+	h.Set("X-Accel-Buffering", "no")
+	s.w.WriteHeader(http.StatusOK)
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+
+	if s.heartbeat > 0 {
+		s.ticker = time.NewTicker(s.heartbeat)
+		s.wg.Add(1)
+		go s.heartbeats()
+	}
+}
+
+func (s *SSEStream) heartbeats() {
+	defer s.wg.Done()
+	defer s.ticker.Stop()
+
+	for {
+		select {
+		case <-s.ticker.C:
+			s.mu.Lock()
+			err := s.write([]byte(":\n\n"))
+			s.mu.Unlock()
+			if err != nil {
+				return
+			}
+
+		case <-s.ctx.Done():
+			return
+
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// write writes b to the response and flushes it. It must be called with
+// the mutex held.
+func (s *SSEStream) write(b []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	if err := s.ctx.Err(); err != nil {
+		s.err = ctxDoneErr(err)
+		return s.err
+	}
+
+	if _, err := s.w.Write(b); err != nil {
+		s.err = errors.E(errors.WithText("write event"), errors.WithErr(err))
+		return s.err
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+
+	return nil
+}
+
+// stop stops the heartbeats and waits for them to finish so that the
+// response is not written to after the handler returns. Send fails after
+// the stream is stopped.
+func (s *SSEStream) stop() {
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+}
@@ -0,0 +1,263 @@
+package httputil_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestSSEResponderRespond(t *testing.T) {
+	sseHeaders := map[string]string{
+		"Content-Type":      "text/event-stream",
+		"Cache-Control":     "no-cache",
+		"X-Accel-Buffering": "no",
+	}
+	sendErr := errors.E(errors.Unavailable, errors.WithText("fetch progress"))
+
+	cases := []struct {
+		name        string
+		responder   *httputil.JSONResponder
+		headers     map[string]string
+		fn          func(s *httputil.SSEStream) error
+		wantStatus  int
+		wantHeaders map[string]string
+		wantBody    string
+		wantErr     error
+	}{
+		{
+			name: "Events",
+			fn: func(s *httputil.SSEStream) error {
+				events := []httputil.SSEEvent{
+					{ID: "1", Event: "progress", Retry: 3 * time.Second, Data: Person{Name: "Donald", Age: 33}},
+					{ID: "2", Data: personJSONer{Name: "Kramer", Age: 41}},
+					{Event: "done"},
+				}
+				for _, ev := range events {
+					if err := s.Send(ev); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			wantStatus:  http.StatusOK,
+			wantHeaders: sseHeaders,
+			wantBody: "id: 1\nevent: progress\nretry: 3000\ndata: {\"Name\":\"Donald\",\"Age\":33,\"V\":null}\n\n" +
+				"id: 2\ndata: {\"age\":41,\"name\":\"Kramer\"}\n\n" +
+				"event: done\n\n",
+		},
+		{
+			name:      "MultilineData",
+			responder: &httputil.JSONResponder{Codec: httputil.StdJSONCodec{Indent: " "}},
+			fn: func(s *httputil.SSEStream) error {
+				return s.Send(httputil.SSEEvent{Data: map[string]int{"done": 1}})
+			},
+			wantStatus:  http.StatusOK,
+			wantHeaders: sseHeaders,
+			wantBody:    "data: {\ndata:  \"done\": 1\ndata: }\n\n",
+		},
+		{
+			name:    "LastEventID",
+			headers: map[string]string{"Last-Event-ID": "41"},
+			fn: func(s *httputil.SSEStream) error {
+				return s.Send(httputil.SSEEvent{ID: "42", Data: "resumed after " + s.LastEventID()})
+			},
+			wantStatus:  http.StatusOK,
+			wantHeaders: sseHeaders,
+			wantBody:    "id: 42\ndata: \"resumed after 41\"\n\n",
+		},
+		{
+			name:        "NoEvents",
+			fn:          func(*httputil.SSEStream) error { return nil },
+			wantStatus:  http.StatusOK,
+			wantHeaders: sseHeaders,
+		},
+		{
+			name:        "ErrorBeforeFirstEvent",
+			fn:          func(*httputil.SSEStream) error { return sendErr },
+			wantStatus:  http.StatusOK,
+			wantHeaders: sseHeaders,
+			wantErr: errors.E(
+				errors.WithOp("SSEResponder.Respond"),
+				errors.Unavailable,
+				errors.WithText("response already written, stream cut short"),
+			),
+		},
+		{
+			name: "ErrorAfterFirstEvent",
+			fn: func(s *httputil.SSEStream) error {
+				if err := s.Send(httputil.SSEEvent{ID: "1"}); err != nil {
+					return err
+				}
+				return sendErr
+			},
+			wantStatus:  http.StatusOK,
+			wantHeaders: sseHeaders,
+			wantBody:    "id: 1\n\n",
+			wantErr: errors.E(
+				errors.WithOp("SSEResponder.Respond"),
+				errors.Unavailable,
+				errors.WithText("response already written, stream cut short"),
+			),
+		},
+		{
+			name: "InvalidEventID",
+			fn: func(s *httputil.SSEStream) error {
+				return s.Send(httputil.SSEEvent{ID: "1\ndata: injected"})
+			},
+			wantStatus:  http.StatusOK,
+			wantHeaders: sseHeaders,
+			wantErr:     errors.E(errors.WithOp("SSEResponder.Respond"), errors.Internal),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var errs []error
+			jr := tc.responder
+			if jr == nil {
+				jr = &httputil.JSONResponder{}
+			}
+			jr.ErrObservers = []httputil.ErrorObserverFunc{
+				func(_ *http.Request, err error) { errs = append(errs, err) },
+			}
+			sr := httputil.SSEResponder{Responder: jr}
+
+			r := httptest.NewRequest(http.MethodGet, "http://host.com/jobs/1/progress", nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			sr.Respond(r, rec, tc.fn)
+
+			matchStreamResponse(t, rec, tc.wantStatus, tc.wantHeaders, tc.wantBody)
+			matchObservedErr(t, errs, tc.wantErr)
+		})
+	}
+
+	t.Run("HeadersBeforeFirstEvent", func(t *testing.T) {
+		var sr httputil.SSEResponder
+
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "http://host.com/jobs/1/progress", nil)
+		sr.Respond(r, rec, func(*httputil.SSEStream) error {
+			if !rec.Flushed || rec.Result().Header.Get("Content-Type") != "text/event-stream" {
+				t.Error("Headers not flushed before fn is called")
+			}
+			return nil
+		})
+	})
+
+	t.Run("HeartbeatBeforeFirstEvent", func(t *testing.T) {
+		sr := httputil.SSEResponder{HeartbeatInterval: 5 * time.Millisecond}
+
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "http://host.com/jobs/1/progress", nil)
+		sr.Respond(r, rec, func(s *httputil.SSEStream) error {
+			time.Sleep(50 * time.Millisecond)
+			return s.Send(httputil.SSEEvent{ID: "1"})
+		})
+
+		body := rec.Body.String()
+		if !strings.HasPrefix(body, ":\n\n") || !strings.Contains(body, "\n\nid: 1\n\n") {
+			t.Errorf("Body=%q; want heartbeats before the first event", body)
+		}
+	})
+
+	t.Run("HeartbeatReset", func(t *testing.T) {
+		sr := httputil.SSEResponder{HeartbeatInterval: 100 * time.Millisecond}
+
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "http://host.com/jobs/1/progress", nil)
+		sr.Respond(r, rec, func(s *httputil.SSEStream) error {
+			for i := 0; i < 10; i++ {
+				if err := s.Send(httputil.SSEEvent{Event: "tick"}); err != nil {
+					return err
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+			return nil
+		})
+
+		if body := rec.Body.String(); strings.Contains(body, ":\n\n") {
+			t.Errorf("Body=%q; want no heartbeats while events are sent", body)
+		}
+	})
+
+	t.Run("SendAfterRespond", func(t *testing.T) {
+		var sr httputil.SSEResponder
+
+		var stream *httputil.SSEStream
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "http://host.com/jobs/1/progress", nil)
+		sr.Respond(r, rec, func(s *httputil.SSEStream) error {
+			stream = s
+			return nil
+		})
+
+		if err := stream.Send(httputil.SSEEvent{ID: "1"}); err == nil {
+			t.Error("SSEStream.Send() error=nil; want error after Respond returns")
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("Body=%q; want=<empty>", rec.Body)
+		}
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		sr := httputil.SSEResponder{HeartbeatInterval: 5 * time.Millisecond}
+
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "http://host.com/jobs/1/progress", nil)
+		sr.Respond(r, rec, func(s *httputil.SSEStream) error {
+			if err := s.Send(httputil.SSEEvent{ID: "1"}); err != nil {
+				return err
+			}
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		})
+
+		body := rec.Body.String()
+		if !strings.HasPrefix(body, "id: 1\n\n:\n\n") {
+			t.Errorf("Body=%q; want heartbeats after the first event", body)
+		}
+	})
+
+	t.Run("ClientDisconnect", func(t *testing.T) {
+		var errs []error
+		sr := httputil.SSEResponder{
+			Responder: &httputil.JSONResponder{
+				ErrObservers: []httputil.ErrorObserverFunc{
+					func(_ *http.Request, err error) { errs = append(errs, err) },
+				},
+			},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := httptest.NewRequest(http.MethodGet, "http://host.com/jobs/1/progress", nil).WithContext(ctx)
+
+		var sendErr error
+		rec := httptest.NewRecorder()
+		sr.Respond(r, rec, func(s *httputil.SSEStream) error {
+			if err := s.Send(httputil.SSEEvent{ID: "1"}); err != nil {
+				return err
+			}
+
+			cancel()
+			<-s.Context().Done()
+
+			sendErr = s.Send(httputil.SSEEvent{ID: "2"})
+			return sendErr
+		})
+
+		if errors.WhatKind(sendErr) != errors.Canceled {
+			t.Errorf("SSEStream.Send() error=%v; want error of kind Canceled", sendErr)
+		}
+		matchStreamResponse(t, rec, http.StatusOK, sseHeaders, "id: 1\n\n")
+		matchObservedErr(t, errs, errors.E(errors.WithOp("SSEResponder.Respond"), errors.Canceled))
+	})
+}
@@ -0,0 +1,329 @@
+package httputil
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// StreamFormat is the format of a streaming JSON response.
+type StreamFormat int
+
+// Streaming response formats.
+const (
+	// NDJSON writes each value as JSON on a separate line with the
+	// Content-Type "application/x-ndjson".
+	NDJSON StreamFormat = iota
+
+	// JSONArray writes the values as the elements of a JSON array.
+	JSONArray
+)
+
+// StreamOptions configures a streaming response.
+type StreamOptions struct {
+	// Format is the format of the response body. Defaults to NDJSON.
+	Format StreamFormat
+
+	// Status is the status code of the response. Defaults to
+	// '200: OK'.
+	Status int
+
+	// FlushInterval is the maximum delay before a value written to the
+	// response is flushed. The values written within the interval are
+	// flushed together, even if the next value is slow to be produced.
+	// The response is flushed after every value if FlushInterval is
+	// zero.
+	FlushInterval time.Duration
+}
+
+// StreamFunc is an iterator which calls yield for each value in the
+// stream. It must stop and return nil as soon as yield returns false.
+// The error returned by it, if any, is reported to the responder.
+type StreamFunc func(yield func(v interface{}) bool) error
+
+// Stream writes the values produced by next as a streaming JSON
+// response. Each value is written as soon as it is produced which
+// avoids building the whole response in memory. Interface upgrade to
+// xgo.JSONer is supported for each value.
+//
+// The status line and headers are only written along with the first
+// value. If next fails before producing a value, the error response is
+// written using JSONResponder.Error. Once the status line has been sent,
+// the stream is cut short on error and the ErrObservers are notified
+// instead. In the JSONArray format, the array is left unterminated in
+// this case so that the client can detect the incomplete response.
+//
+// The stream is stopped if the request context is done, for example
+// because the client disconnected. The response body is omitted for
+// HEAD requests.
+//
+//	responder.Stream(r, w, httputil.StreamOptions{Format: httputil.JSONArray},
+//		func(yield func(v interface{}) bool) error {
+//			rows, err := db.QueryContext(r.Context(), query)
+//			if err != nil {
+//				return err
+//			}
+//			defer rows.Close()
+//
+//			for rows.Next() {
+//				var o myapp.Order
+//				if err := rows.Scan(&o.ID, &o.Total); err != nil {
+//					return err
+//				}
+//				if !yield(o) {
+//					return nil
+//				}
+//			}
+//			return rows.Err()
+//		},
+//	)
+func (jr *JSONResponder) Stream(r *http.Request, w http.ResponseWriter, opts StreamOptions, next StreamFunc) {
+	const op = "JSONResponder.Stream"
+
+	s := streamWriter{jr: jr, r: r, w: w, opts: opts, flusher: flusherOf(w)}
+	// The pending flush must not write to the response after the handler
+	// returns.
+	defer s.stop()
+
+	if isHeadRequest(r) {
+		s.writeHeader()
+		return
+	}
+
+	err := next(s.write)
+	if err == nil {
+		err = s.err
+	}
+
+	switch {
+	case err != nil && !s.wroteHeader:
+		jr.Error(r, w, errors.E(errors.WithOp(op), errors.WithErr(err)))
+
+	case err != nil:
+		jr.observeError(r, errors.E(
+			errors.WithOp(op),
+			errors.WithText("response already written, stream cut short"),
+			errors.WithErr(err),
+		))
+
+	default:
+		s.end()
+	}
+}
+
+// StreamChan writes the values received from ch as a streaming JSON
+// response until ch is closed. See JSONResponder.Stream for details.
+//
+// If the stream is stopped early, for example because the client
+// disconnected, the remaining values are received from ch and discarded
+// in the background until ch is closed so that the producer is not
+// blocked. The producer should still stop when the request context is
+// done to avoid doing wasted work.
+func StreamChan[T any](jr *JSONResponder, r *http.Request, w http.ResponseWriter, opts StreamOptions, ch <-chan T) {
+	jr.Stream(r, w, opts, func(yield func(v interface{}) bool) error {
+		ctx := r.Context()
+		for {
+			select {
+			case v, ok := <-ch:
+				if !ok {
+					return nil
+				}
+				if !yield(v) {
+					go drainChan(ch)
+					return nil
+				}
+
+			case <-ctx.Done():
+				go drainChan(ch)
+				return ctxDoneErr(ctx.Err())
+			}
+		}
+	})
+}
+
+// drainChan receives and discards the values from ch until it is closed.
+func drainChan[T any](ch <-chan T) {
+	for range ch {
+	}
+}
+
+// streamWriter writes the values of the stream to the ResponseWriter.
+type streamWriter struct {
+	jr      *JSONResponder
+	r       *http.Request
+	w       http.ResponseWriter
+	opts    StreamOptions
+	flusher http.Flusher
+
+	wroteHeader bool
+	count       int
+	err         error
+
+	// mu guards the writes to the response against the flush by the
+	// timer for the FlushInterval.
+	mu           sync.Mutex
+	flushTimer   *time.Timer
+	flushPending bool
+	stopped      bool
+}
+
+func (s *streamWriter) writeHeader() {
+	contentType := "application/x-ndjson"
+	if s.opts.Format == JSONArray {
+		contentType = contentTypeJSON
+	}
+	status := s.opts.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	s.w.Header().Set("Content-Type", contentType)
+	s.w.WriteHeader(status)
+	s.wroteHeader = true
+}
+
+// write writes the value to the response. It reports whether the stream
+// should continue.
+func (s *streamWriter) write(v interface{}) bool {
+	if s.err != nil {
+		return false
+	}
+
+	if err := s.r.Context().Err(); err != nil {
+		s.err = ctxDoneErr(err)
+		return false
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if s.opts.Format == JSONArray {
+		sep := byte(',')
+		if s.count == 0 {
+			sep = '['
+		}
+		buf.WriteByte(sep)
+	}
+
+	if err := jsonCodecOrDefault(s.jr.Codec).NewEncoder(buf).Encode(jsonBody(v)); err != nil {
+		s.err = errors.E(errors.Internal, errors.WithText("encode stream value"), errors.WithErr(err))
+		return false
+	}
+
+	b := buf.Bytes()
+	if s.opts.Format == JSONArray {
+		b = bytes.TrimRight(b, "\n")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.wroteHeader {
+		s.writeHeader()
+	}
+
+	if _, err := s.w.Write(b); err != nil {
+		s.err = errors.E(errors.WithText("write stream value"), errors.WithErr(err))
+		return false
+	}
+	s.count++
+
+	s.scheduleFlush()
+	return true
+}
+
+// scheduleFlush flushes the response right away if FlushInterval is
+// zero. Otherwise, it starts the timer to flush the response after
+// FlushInterval unless a flush is already pending. It must be called
+// with the mutex held.
+func (s *streamWriter) scheduleFlush() {
+	switch {
+	case s.flusher == nil || s.flushPending:
+
+	case s.opts.FlushInterval <= 0:
+		s.flusher.Flush()
+
+	case s.flushTimer == nil:
+		s.flushPending = true
+		s.flushTimer = time.AfterFunc(s.opts.FlushInterval, s.timedFlush)
+
+	default:
+		s.flushPending = true
+		s.flushTimer.Reset(s.opts.FlushInterval)
+	}
+}
+
+// timedFlush is called by the timer to flush the pending values.
+func (s *streamWriter) timedFlush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped || !s.flushPending {
+		return
+	}
+	s.flushPending = false
+	s.flusher.Flush()
+}
+
+// stop stops the timer for the pending flush, if any.
+func (s *streamWriter) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+	}
+}
+
+// end terminates the stream after all the values have been written.
+func (s *streamWriter) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.wroteHeader {
+		s.writeHeader()
+	}
+
+	if s.opts.Format == JSONArray {
+		end := "]\n"
+		if s.count == 0 {
+			end = "[]\n"
+		}
+		if _, err := s.w.Write([]byte(end)); err != nil {
+			s.jr.observeError(s.r, errors.E(
+				errors.WithOp("JSONResponder.Stream"), errors.WithText("write stream end"), errors.WithErr(err),
+			))
+			return
+		}
+	}
+
+	if s.flusher != nil {
+		s.flushPending = false
+		s.flusher.Flush()
+	}
+}
+
+func ctxDoneErr(err error) error {
+	return errors.E(errors.Canceled, errors.WithText("request context done"), errors.WithErr(err))
+}
+
+// flusherOf returns the http.Flusher for the ResponseWriter, unwrapping
+// it if required. It returns nil if flushing is not supported.
+func flusherOf(w http.ResponseWriter) http.Flusher {
+	for {
+		switch t := w.(type) {
+		case http.Flusher:
+			return t
+
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+
+		default:
+			return nil
+		}
+	}
+}
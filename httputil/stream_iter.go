@@ -0,0 +1,36 @@
+//go:build go1.23
+
+package httputil
+
+import (
+	"iter"
+	"net/http"
+)
+
+// StreamSeq writes the values of seq as a streaming JSON response. See
+// JSONResponder.Stream for details.
+//
+//	httputil.StreamSeq(&responder, r, w, httputil.StreamOptions{}, maps.Values(m))
+func StreamSeq[T any](jr *JSONResponder, r *http.Request, w http.ResponseWriter, opts StreamOptions, seq iter.Seq[T]) {
+	jr.Stream(r, w, opts, func(yield func(v interface{}) bool) error {
+		seq(func(v T) bool { return yield(v) })
+		return nil
+	})
+}
+
+// StreamSeq2 writes the values of seq as a streaming JSON response. The
+// stream is stopped at the first non-nil error which is reported to the
+// responder. See JSONResponder.Stream for details.
+func StreamSeq2[T any](jr *JSONResponder, r *http.Request, w http.ResponseWriter, opts StreamOptions, seq iter.Seq2[T, error]) {
+	jr.Stream(r, w, opts, func(yield func(v interface{}) bool) error {
+		var err error
+		seq(func(v T, e error) bool {
+			if e != nil {
+				err = e
+				return false
+			}
+			return yield(v)
+		})
+		return err
+	})
+}
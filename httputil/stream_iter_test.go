@@ -0,0 +1,41 @@
+//go:build go1.23
+
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestStreamSeq(t *testing.T) {
+	var jr httputil.JSONResponder
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://host.com/orders", nil)
+	httputil.StreamSeq(&jr, r, rec, httputil.StreamOptions{}, slices.Values([]int{1, 2, 3}))
+
+	matchStreamResponse(t, rec, http.StatusOK, map[string]string{"Content-Type": "application/x-ndjson"}, "1\n2\n3\n")
+}
+
+func TestStreamSeq2(t *testing.T) {
+	var errs []error
+	jr := httputil.JSONResponder{
+		ErrObservers: []httputil.ErrorObserverFunc{
+			func(_ *http.Request, err error) { errs = append(errs, err) },
+		},
+	}
+	seq := func(yield func(int, error) bool) {
+		_ = yield(1, nil) && yield(0, errors.E(errors.Unavailable)) && yield(3, nil)
+	}
+
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://host.com/orders", nil)
+	httputil.StreamSeq2(&jr, r, rec, httputil.StreamOptions{Format: httputil.JSONArray}, seq)
+
+	matchStreamResponse(t, rec, http.StatusOK, map[string]string{"Content-Type": "application/json; charset=utf-8"}, "[1")
+	matchObservedErr(t, errs, errors.E(errors.WithOp("JSONResponder.Stream"), errors.Unavailable))
+}
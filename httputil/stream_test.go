@@ -0,0 +1,309 @@
+package httputil_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+func TestJSONResponderStream(t *testing.T) {
+	people := []interface{}{
+		Person{Name: "Donald", Age: 33},
+		personJSONer{Name: "Kramer", Age: 41},
+	}
+	yieldAll := func(vals []interface{}, err error) httputil.StreamFunc {
+		return func(yield func(v interface{}) bool) error {
+			for _, v := range vals {
+				if !yield(v) {
+					return nil
+				}
+			}
+			return err
+		}
+	}
+	streamErr := errors.E(errors.Unavailable, errors.WithText("query orders"))
+
+	cases := []struct {
+		name        string
+		opts        httputil.StreamOptions
+		method      string
+		next        httputil.StreamFunc
+		wantStatus  int
+		wantHeaders map[string]string
+		wantBody    string
+		wantErr     error
+	}{
+		{
+			name:        "NDJSON",
+			next:        yieldAll(people, nil),
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Content-Type": "application/x-ndjson"},
+			wantBody:    `{"Name":"Donald","Age":33,"V":null}` + "\n" + `{"age":41,"name":"Kramer"}` + "\n",
+		},
+		{
+			name:        "JSONArray",
+			opts:        httputil.StreamOptions{Format: httputil.JSONArray, Status: http.StatusPartialContent},
+			next:        yieldAll(people, nil),
+			wantStatus:  http.StatusPartialContent,
+			wantHeaders: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+			wantBody:    `[{"Name":"Donald","Age":33,"V":null},{"age":41,"name":"Kramer"}]` + "\n",
+		},
+		{
+			name:        "EmptyJSONArray",
+			opts:        httputil.StreamOptions{Format: httputil.JSONArray},
+			next:        yieldAll(nil, nil),
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+			wantBody:    "[]\n",
+		},
+		{
+			name:        "HeadRequest",
+			method:      http.MethodHead,
+			next:        yieldAll(people, nil),
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Content-Type": "application/x-ndjson"},
+		},
+		{
+			name:        "ErrorBeforeFirstValue",
+			opts:        httputil.StreamOptions{Format: httputil.JSONArray},
+			next:        yieldAll(nil, streamErr),
+			wantStatus:  http.StatusServiceUnavailable,
+			wantHeaders: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+			wantBody:    `{"success":false,"msg":"","errors":[{"code":"UNAVAILABLE","error":"unavailable","msg":""}]}` + "\n",
+			wantErr:     errors.E(errors.WithOp("JSONResponder.Stream"), errors.Unavailable, errors.WithText("query orders")),
+		},
+		{
+			name:        "ErrorAfterFirstValue",
+			opts:        httputil.StreamOptions{Format: httputil.JSONArray},
+			next:        yieldAll(people[:1], streamErr),
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+			wantBody:    `[{"Name":"Donald","Age":33,"V":null}`,
+			wantErr: errors.E(
+				errors.WithOp("JSONResponder.Stream"),
+				errors.Unavailable,
+				errors.WithText("response already written, stream cut short"),
+			),
+		},
+		{
+			name:        "EncodeError",
+			next:        yieldAll([]interface{}{people[0], make(chan int), people[1]}, nil),
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Content-Type": "application/x-ndjson"},
+			wantBody:    `{"Name":"Donald","Age":33,"V":null}` + "\n",
+			wantErr: errors.E(
+				errors.WithOp("JSONResponder.Stream"),
+				errors.Internal,
+				errors.WithText("response already written, stream cut short"),
+			),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var errs []error
+			jr := httputil.JSONResponder{
+				ErrObservers: []httputil.ErrorObserverFunc{
+					func(_ *http.Request, err error) { errs = append(errs, err) },
+				},
+			}
+
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			rec := httptest.NewRecorder()
+			jr.Stream(httptest.NewRequest(method, "http://host.com/orders", nil), rec, tc.opts, tc.next)
+
+			matchStreamResponse(t, rec, tc.wantStatus, tc.wantHeaders, tc.wantBody)
+			matchObservedErr(t, errs, tc.wantErr)
+		})
+	}
+
+	t.Run("ContextCanceled", func(t *testing.T) {
+		var errs []error
+		jr := httputil.JSONResponder{
+			ErrObservers: []httputil.ErrorObserverFunc{
+				func(_ *http.Request, err error) { errs = append(errs, err) },
+			},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := httptest.NewRequest(http.MethodGet, "http://host.com/orders", nil).WithContext(ctx)
+
+		var yieldCnt int
+		rec := httptest.NewRecorder()
+		jr.Stream(r, rec, httputil.StreamOptions{}, func(yield func(v interface{}) bool) error {
+			for {
+				yieldCnt++
+				if !yield(people[0]) {
+					return nil
+				}
+				cancel()
+			}
+		})
+
+		if yieldCnt != 2 {
+			t.Errorf("yield call count=%d; want 2", yieldCnt)
+		}
+		matchStreamResponse(t, rec, http.StatusOK, map[string]string{"Content-Type": "application/x-ndjson"},
+			`{"Name":"Donald","Age":33,"V":null}`+"\n")
+		matchObservedErr(t, errs, errors.E(
+			errors.WithOp("JSONResponder.Stream"),
+			errors.Canceled,
+			errors.WithText("response already written, stream cut short"),
+		))
+	})
+
+	t.Run("FlushInterval", func(t *testing.T) {
+		var jr httputil.JSONResponder
+		fr := newFlushRecorder()
+		r := httptest.NewRequest(http.MethodGet, "http://host.com/orders", nil)
+		jr.Stream(r, fr, httputil.StreamOptions{FlushInterval: 20 * time.Millisecond}, func(yield func(v interface{}) bool) error {
+			if !yield(people[0]) || !yield(people[1]) {
+				return nil
+			}
+
+			// The values written so far are flushed after the interval even
+			// though the next value is slow to be produced.
+			deadline := time.Now().Add(time.Second)
+			for len(fr.Flushed()) == 0 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			return nil
+		})
+
+		want := []string{
+			`{"Name":"Donald","Age":33,"V":null}` + "\n" + `{"age":41,"name":"Kramer"}` + "\n",
+			`{"Name":"Donald","Age":33,"V":null}` + "\n" + `{"age":41,"name":"Kramer"}` + "\n",
+		}
+		if got := fr.Flushed(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Flushed=%q; want=%q", got, want)
+		}
+	})
+}
+
+func TestStreamChan(t *testing.T) {
+	ch := make(chan Person, 2)
+	ch <- Person{Name: "Donald", Age: 33}
+	ch <- Person{Name: "Kramer", Age: 41}
+	close(ch)
+
+	var jr httputil.JSONResponder
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://host.com/orders", nil)
+	httputil.StreamChan(&jr, r, rec, httputil.StreamOptions{Format: httputil.JSONArray}, ch)
+
+	matchStreamResponse(t, rec, http.StatusOK, map[string]string{"Content-Type": "application/json; charset=utf-8"},
+		`[{"Name":"Donald","Age":33,"V":null},{"Name":"Kramer","Age":41,"V":null}]`+"\n")
+	if !rec.Flushed {
+		t.Error("ResponseRecorder.Flushed=false; want true")
+	}
+}
+
+func TestStreamChanStoppedEarly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := httptest.NewRequest(http.MethodGet, "http://host.com/orders", nil).WithContext(ctx)
+
+	ch := make(chan Person)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(ch)
+
+		ch <- Person{Name: "Donald"}
+		cancel()
+		// The producer does not stop when the context is done.
+		ch <- Person{Name: "Kramer"}
+		ch <- Person{Name: "Elaine"}
+	}()
+
+	var jr httputil.JSONResponder
+	httputil.StreamChan(&jr, r, httptest.NewRecorder(), httputil.StreamOptions{}, ch)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Producer blocked after the stream was stopped")
+	}
+}
+
+func matchStreamResponse(t *testing.T, rec *httptest.ResponseRecorder, status int, hdrs map[string]string, body string) {
+	t.Helper()
+
+	res := rec.Result()
+	if res.StatusCode != status {
+		t.Errorf("StatusCode=%d; want=%d", res.StatusCode, status)
+	}
+	if got := headers(res); !reflect.DeepEqual(got, hdrs) {
+		t.Errorf("Headers=%q; want=%q", got, hdrs)
+	}
+	if got := rec.Body.String(); got != body {
+		t.Errorf("Body=%q; want=%q", got, body)
+	}
+}
+
+func matchObservedErr(t *testing.T, errs []error, want error) {
+	t.Helper()
+
+	if want == nil {
+		if len(errs) != 0 {
+			t.Errorf("ErrObserver errs=%v; want none", errs)
+		}
+		return
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("ErrObserver call count=%d; want 1", len(errs))
+	}
+	if !errors.Match(want, errs[0]) {
+		t.Errorf("ErrorObserver.err diff= %s", errorDiff(want, errs[0]))
+	}
+}
+
+// flushRecorder records the response body at each flush. It is safe for
+// concurrent use.
+type flushRecorder struct {
+	mu      sync.Mutex
+	rec     *httptest.ResponseRecorder
+	flushed []string
+}
+
+func newFlushRecorder() *flushRecorder {
+	return &flushRecorder{rec: httptest.NewRecorder()}
+}
+
+func (f *flushRecorder) Header() http.Header { return f.rec.Header() }
+
+func (f *flushRecorder) WriteHeader(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rec.WriteHeader(status)
+}
+
+func (f *flushRecorder) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rec.Write(b)
+}
+
+func (f *flushRecorder) Flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flushed = append(f.flushed, f.rec.Body.String())
+}
+
+// Flushed returns the response body at each flush.
+func (f *flushRecorder) Flushed() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.flushed...)
+}
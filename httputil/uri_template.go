@@ -0,0 +1,488 @@
+package httputil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// URITemplate is a URI Template as defined in RFC 6570. All four levels
+// of the specification are supported:
+//
+//	Level 1: {var}
+//	Level 2: {+var}, {#var}
+//	Level 3: {x,y}, {.var}, {/var}, {;var}, {?var}, {&var}
+//	Level 4: {var:3}, {list*}
+//
+// A URITemplate can be expanded on its own using URITemplate.Expand or
+// used to build the URL using URLBuilder.URITemplate.
+type URITemplate struct {
+	raw   string
+	parts []tmplPart
+}
+
+// tmplPart is either a literal or an expression of the template.
+type tmplPart struct {
+	literal string
+	op      tmplOp
+	specs   []varSpec
+}
+
+type varSpec struct {
+	name    string
+	explode bool
+	prefix  int
+}
+
+// tmplOp describes the expansion behaviour of an operator as per
+// RFC 6570 Appendix A.
+type tmplOp struct {
+	first    string
+	sep      string
+	named    bool
+	ifEmpty  string
+	reserved bool
+}
+
+var tmplOps = map[byte]tmplOp{
+	'+': {sep: ",", reserved: true},
+	'#': {first: "#", sep: ",", reserved: true},
+	'.': {first: ".", sep: "."},
+	'/': {first: "/", sep: "/"},
+	';': {first: ";", sep: ";", named: true},
+	'?': {first: "?", sep: "&", named: true, ifEmpty: "="},
+	'&': {first: "&", sep: "&", named: true, ifEmpty: "="},
+}
+
+// ParseURITemplate parses the URI Template. It returns an error of kind
+// errors.InvalidInput if the template is malformed.
+func ParseURITemplate(tmpl string) (*URITemplate, error) {
+	const op = "httputil.ParseURITemplate"
+
+	t := URITemplate{raw: tmpl}
+	for s, offset := tmpl, 0; s != ""; {
+		start := strings.IndexAny(s, "{}")
+		if start == -1 {
+			t.parts = append(t.parts, tmplPart{literal: s})
+			break
+		}
+		if s[start] == '}' {
+			return nil, uriTemplateErr(op, tmpl, "unexpected '}' at offset %d", offset+start)
+		}
+		if start > 0 {
+			t.parts = append(t.parts, tmplPart{literal: s[:start]})
+		}
+
+		end := strings.IndexAny(s[start+1:], "{}")
+		if end == -1 || s[start+1+end] == '{' {
+			return nil, uriTemplateErr(op, tmpl, "unterminated expression at offset %d", offset+start)
+		}
+		end += start + 1
+
+		part, err := parseExpression(s[start+1 : end])
+		if err != nil {
+			return nil, uriTemplateErr(op, tmpl, "expression at offset %d: %s", offset+start, err)
+		}
+		t.parts = append(t.parts, part)
+
+		s, offset = s[end+1:], offset+end+1
+	}
+
+	return &t, nil
+}
+
+// MustParseURITemplate is like ParseURITemplate but panics if the
+// template cannot be parsed. It simplifies the initialisation of global
+// variables holding templates.
+func MustParseURITemplate(tmpl string) *URITemplate {
+	t, err := ParseURITemplate(tmpl)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func uriTemplateErr(op, tmpl, format string, args ...interface{}) error {
+	return errors.E(
+		errors.WithOp(op),
+		errors.InvalidInput,
+		errors.WithTextf("malformed URI template %q: %s", tmpl, fmt.Sprintf(format, args...)),
+	)
+}
+
+func parseExpression(expr string) (tmplPart, error) {
+	if expr == "" {
+		return tmplPart{}, errors.E(errors.WithText("empty expression"))
+	}
+
+	var part tmplPart
+	if op, ok := tmplOps[expr[0]]; ok {
+		part.op, expr = op, expr[1:]
+	} else if strings.IndexByte("=,!@|", expr[0]) != -1 {
+		return tmplPart{}, errors.E(errors.WithTextf("reserved operator %q", expr[0]))
+	} else {
+		part.op = tmplOp{sep: ","}
+	}
+
+	for _, s := range strings.Split(expr, ",") {
+		spec, err := parseVarSpec(s)
+		if err != nil {
+			return tmplPart{}, err
+		}
+		part.specs = append(part.specs, spec)
+	}
+	return part, nil
+}
+
+func parseVarSpec(s string) (varSpec, error) {
+	var spec varSpec
+	switch i := strings.IndexByte(s, ':'); {
+	case strings.HasSuffix(s, "*"):
+		spec.explode, s = true, s[:len(s)-1]
+
+	case i != -1:
+		n, err := strconv.Atoi(s[i+1:])
+		if err != nil || n < 1 || n > 9999 || s[i+1] == '+' || s[i+1] == '0' {
+			return varSpec{}, errors.E(errors.WithTextf("invalid prefix modifier %q", s[i:]))
+		}
+		spec.prefix, s = n, s[:i]
+	}
+
+	if !validVarName(s) {
+		return varSpec{}, errors.E(errors.WithTextf("invalid variable name %q", s))
+	}
+	spec.name = s
+	return spec, nil
+}
+
+// validVarName reports whether s is a valid varname:
+//
+//	varname  = varchar *( ["."] varchar )
+//	varchar  = ALPHA / DIGIT / "_" / pct-encoded
+func validVarName(s string) bool {
+	if s == "" || s[0] == '.' || s[len(s)-1] == '.' {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isAlphaNum(c) || c == '_':
+
+		case c == '.':
+			if s[i-1] == '.' {
+				return false
+			}
+
+		case c == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return false
+			}
+			i += 2
+
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the template as it was parsed.
+func (t *URITemplate) String() string { return t.raw }
+
+// Vars returns the names of the variables in the template, without
+// duplicates, in the order of appearance.
+func (t *URITemplate) Vars() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, part := range t.parts {
+		for _, spec := range part.specs {
+			if !seen[spec.name] {
+				seen[spec.name] = true
+				names = append(names, spec.name)
+			}
+		}
+	}
+	return names
+}
+
+// Expand expands the template using the variables. A variable value can
+// be a string, a bool, a number, a fmt.Stringer, a slice of these for a
+// list or a map with string keys for an associative array. The keys of
+// an associative array are expanded in sorted order. A variable which is
+// missing or nil, or an empty list or map, is undefined and is omitted
+// from the expansion.
+//
+// It returns an error of kind errors.InvalidInput if a value has an
+// unsupported type or if a prefix modifier is applied to a list or map.
+//
+//	t := httputil.MustParseURITemplate("/search{?q,tags*}")
+//	s, err := t.Expand(map[string]interface{}{
+//		"q":    "uri templates",
+//		"tags": []string{"go", "rfc"},
+//	})
+//	// s: /search?q=uri%20templates&tags=go&tags=rfc
+func (t *URITemplate) Expand(vars map[string]interface{}) (string, error) {
+	const op = "URITemplate.Expand"
+
+	var sb strings.Builder
+	for _, part := range t.parts {
+		if part.specs == nil {
+			encodeTo(&sb, part.literal, true)
+			continue
+		}
+
+		if err := part.expand(&sb, vars); err != nil {
+			return "", errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithTextf("expand URI template %q: %s", t.raw, err),
+			)
+		}
+	}
+	return sb.String(), nil
+}
+
+func (p tmplPart) expand(sb *strings.Builder, vars map[string]interface{}) error {
+	first := true
+	for _, spec := range p.specs {
+		v, err := tmplValueOf(vars[spec.name])
+		if err != nil {
+			return errors.E(errors.WithTextf("variable %q: %s", spec.name, err))
+		}
+		if v.undefined() {
+			continue
+		}
+		if spec.prefix > 0 && v.str == nil {
+			return errors.E(errors.WithTextf("variable %q: prefix modifier applied to a composite value", spec.name))
+		}
+
+		if first {
+			sb.WriteString(p.op.first)
+			first = false
+		} else {
+			sb.WriteString(p.op.sep)
+		}
+
+		switch {
+		case v.str != nil:
+			s := *v.str
+			p.writeName(sb, spec.name, s == "")
+			if spec.prefix > 0 {
+				s = truncateRunes(s, spec.prefix)
+			}
+			encodeTo(sb, s, p.op.reserved)
+
+		case !spec.explode:
+			p.writeName(sb, spec.name, false)
+			for i, item := range v.items() {
+				if i > 0 {
+					sb.WriteByte(',')
+				}
+				encodeTo(sb, item, p.op.reserved)
+			}
+
+		case v.keys == nil:
+			for i, item := range v.list {
+				if i > 0 {
+					sb.WriteString(p.op.sep)
+				}
+				p.writeName(sb, spec.name, item == "")
+				encodeTo(sb, item, p.op.reserved)
+			}
+
+		default:
+			for i, k := range v.keys {
+				if i > 0 {
+					sb.WriteString(p.op.sep)
+				}
+				encodeTo(sb, k, p.op.reserved)
+				if v.vals[i] == "" && p.op.named {
+					sb.WriteString(p.op.ifEmpty)
+					continue
+				}
+				sb.WriteByte('=')
+				encodeTo(sb, v.vals[i], p.op.reserved)
+			}
+		}
+	}
+	return nil
+}
+
+// writeName writes the name of the variable for the named operators
+// followed by '=' or, if the value is empty, the ifEmpty string.
+func (p tmplPart) writeName(sb *strings.Builder, name string, empty bool) {
+	if !p.op.named {
+		return
+	}
+
+	sb.WriteString(name)
+	if empty {
+		sb.WriteString(p.op.ifEmpty)
+		return
+	}
+	sb.WriteByte('=')
+}
+
+// tmplValue is the value of a template variable. It is a string if str
+// is set, an associative array if keys is set and a list otherwise.
+type tmplValue struct {
+	str  *string
+	list []string
+	keys []string
+	vals []string
+}
+
+func (v tmplValue) undefined() bool {
+	return v.str == nil && len(v.list) == 0 && len(v.keys) == 0
+}
+
+// items returns the elements of a list or the interleaved keys and
+// values of an associative array.
+func (v tmplValue) items() []string {
+	if v.keys == nil {
+		return v.list
+	}
+
+	items := make([]string, 0, 2*len(v.keys))
+	for i, k := range v.keys {
+		items = append(items, k, v.vals[i])
+	}
+	return items
+}
+
+func tmplValueOf(v interface{}) (tmplValue, error) {
+	if v == nil {
+		return tmplValue{}, nil
+	}
+	if s, ok := tmplString(v); ok {
+		return tmplValue{str: &s}, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		list := make([]string, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			s, ok := tmplString(rv.Index(i).Interface())
+			if !ok {
+				return tmplValue{}, errors.E(errors.WithTextf("unsupported list element type %T", rv.Index(i).Interface()))
+			}
+			list = append(list, s)
+		}
+		return tmplValue{list: list}, nil
+
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return tmplValue{}, errors.E(errors.WithTextf("unsupported map key type %s", rv.Type().Key()))
+		}
+
+		keys := make([]string, 0, rv.Len())
+		for _, k := range rv.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+
+		vals := make([]string, 0, len(keys))
+		for _, k := range keys {
+			ev := rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key())).Interface()
+			s, ok := tmplString(ev)
+			if !ok {
+				return tmplValue{}, errors.E(errors.WithTextf("unsupported map value type %T", ev))
+			}
+			vals = append(vals, s)
+		}
+		return tmplValue{keys: keys, vals: vals}, nil
+	}
+
+	return tmplValue{}, errors.E(errors.WithTextf("unsupported type %T", v))
+}
+
+// tmplString formats a scalar value. It reports false if v is not a
+// scalar.
+func tmplString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+
+	case fmt.Stringer:
+		return v.String(), true
+
+	case bool:
+		return strconv.FormatBool(v), true
+
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), true
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), true
+	}
+	return "", false
+}
+
+// truncateRunes truncates s to at most n characters.
+func truncateRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+const upperHex = "0123456789ABCDEF"
+
+// encodeTo writes s percent-encoding all characters other than the
+// unreserved characters. If reserved is true, the reserved characters
+// and percent-encoded triplets are also written as is.
+func encodeTo(sb *strings.Builder, s string, reserved bool) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isUnreserved(c), reserved && isReserved(c):
+			sb.WriteByte(c)
+
+		case reserved && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			sb.WriteString(s[i : i+3])
+			i += 2
+
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(upperHex[c>>4])
+			sb.WriteByte(upperHex[c&15])
+		}
+	}
+}
+
+func isUnreserved(c byte) bool {
+	return isAlphaNum(c) || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isReserved(c byte) bool {
+	return strings.IndexByte(":/?#[]@!$&'()*+,;=", c) != -1
+}
+
+func isAlphaNum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
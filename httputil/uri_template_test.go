@@ -0,0 +1,219 @@
+package httputil_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"github.com/sudo-suhas/xgo/httputil"
+)
+
+// uriTemplateGroup is a group of test cases in the format of the
+// uritemplate-test corpus (https://github.com/uri-templates/uritemplate-test).
+type uriTemplateGroup struct {
+	Level     int                    `json:"level"`
+	Variables map[string]interface{} `json:"variables"`
+	Testcases [][2]interface{}       `json:"testcases"`
+}
+
+func TestURITemplateCorpus(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "uritemplate", "*.json"))
+	if err != nil {
+		t.Fatalf("Glob() error=%v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test files found in testdata/uritemplate")
+	}
+
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("ReadFile() error=%v", err)
+		}
+
+		var groups map[string]uriTemplateGroup
+		if err := json.Unmarshal(b, &groups); err != nil {
+			t.Fatalf("Unmarshal(%s) error=%v", file, err)
+		}
+
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			for name, g := range groups {
+				g := g
+				t.Run(name, func(t *testing.T) {
+					for _, tc := range g.Testcases {
+						checkURITemplateCase(t, g.Variables, tc[0].(string), tc[1])
+					}
+				})
+			}
+		})
+	}
+}
+
+func checkURITemplateCase(t *testing.T, vars map[string]interface{}, tmpl string, want interface{}) {
+	t.Helper()
+
+	var got string
+	tpl, err := httputil.ParseURITemplate(tmpl)
+	if err == nil {
+		got, err = tpl.Expand(vars)
+	}
+
+	switch want := want.(type) {
+	case bool:
+		if err == nil {
+			t.Errorf("%s: Expand()=%q; want error", tmpl, got)
+		} else if errors.WhatKind(err) != errors.InvalidInput {
+			t.Errorf("%s: error kind=%v; want=%v", tmpl, errors.WhatKind(err), errors.InvalidInput)
+		}
+
+	case string:
+		if err != nil {
+			t.Errorf("%s: Expand() error=%v", tmpl, err)
+		} else if got != want {
+			t.Errorf("%s: Expand()=%q; want=%q", tmpl, got, want)
+		}
+
+	case []interface{}:
+		if err != nil {
+			t.Errorf("%s: Expand() error=%v", tmpl, err)
+			return
+		}
+		for _, w := range want {
+			if got == w {
+				return
+			}
+		}
+		t.Errorf("%s: Expand()=%q; want one of %q", tmpl, got, want)
+
+	default:
+		t.Fatalf("%s: unexpected expected value %v", tmpl, want)
+	}
+}
+
+func TestURITemplateExpand(t *testing.T) {
+	const op = "URITemplate.Expand"
+	cases := []struct {
+		name    string
+		tmpl    string
+		vars    map[string]interface{}
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Scalars",
+			tmpl: "/items{/id}{?active,ratio,page}",
+			vars: map[string]interface{}{"id": 42, "active": true, "ratio": 0.5, "page": uint8(3)},
+			want: "/items/42?active=true&ratio=0.5&page=3",
+		},
+		{
+			name: "TypedCollections",
+			tmpl: "/search{?tags*,filter*}",
+			vars: map[string]interface{}{
+				"tags":   [2]int{1, 2},
+				"filter": map[string]int{"min": 10, "max": 20},
+			},
+			want: "/search?tags=1&tags=2&max=20&min=10",
+		},
+		{
+			name: "Unicode",
+			tmpl: "{var:2}{?q}",
+			vars: map[string]interface{}{"var": "ééé", "q": "gö"},
+			want: "%C3%A9%C3%A9?q=g%C3%B6",
+		},
+		{
+			name: "UnsupportedType",
+			tmpl: "/items/{id}",
+			vars: map[string]interface{}{"id": struct{}{}},
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithText(`expand URI template "/items/{id}": variable "id": unsupported type struct {}`),
+			),
+		},
+		{
+			name: "PrefixOnList",
+			tmpl: "{list:3}",
+			vars: map[string]interface{}{"list": []string{"a"}},
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithText(`expand URI template "{list:3}": variable "list": prefix modifier applied to a composite value`),
+			),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := httputil.MustParseURITemplate(tc.tmpl).Expand(tc.vars)
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("URITemplate.Expand() error diff: %s", errorDiff(tc.wantErr, err))
+			}
+			if got != tc.want {
+				t.Errorf("URITemplate.Expand()=%q; want=%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseURITemplate(t *testing.T) {
+	const op = "httputil.ParseURITemplate"
+	cases := []struct {
+		name     string
+		tmpl     string
+		wantVars []string
+		wantErr  error
+	}{
+		{
+			name:     "Vars",
+			tmpl:     "/users/{id}{/path*}{?fields,id}",
+			wantVars: []string{"id", "path", "fields"},
+		},
+		{
+			name: "UnexpectedClosingBrace",
+			tmpl: "/users/id}",
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithText(`malformed URI template "/users/id}": unexpected '}' at offset 9`),
+			),
+		},
+		{
+			name: "Unterminated",
+			tmpl: "/users/{id",
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithText(`malformed URI template "/users/{id": unterminated expression at offset 7`),
+			),
+		},
+		{
+			name: "InvalidPrefix",
+			tmpl: "{var:0}",
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithText(`malformed URI template "{var:0}": expression at offset 0: invalid prefix modifier ":0"`),
+			),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tpl, err := httputil.ParseURITemplate(tc.tmpl)
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("ParseURITemplate() error diff: %s", errorDiff(tc.wantErr, err))
+			}
+			if err != nil {
+				return
+			}
+
+			if got := tpl.Vars(); !reflect.DeepEqual(got, tc.wantVars) {
+				t.Errorf("URITemplate.Vars()=%q; want=%q", got, tc.wantVars)
+			}
+			if tpl.String() != tc.tmpl {
+				t.Errorf("URITemplate.String()=%q; want=%q", tpl.String(), tc.tmpl)
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package httputil
 
 import (
+	"fmt"
 	"net/url"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -17,6 +19,12 @@ import (
 // Ideally, URLBuilderSource instance should be created only once for a
 // given base URL.
 type URLBuilderSource struct {
+	// Strict makes URLBuilder.Build also fail if a path parameter used
+	// in the path template has an empty value, which would otherwise
+	// result in an empty path segment such as "/users//posts".
+	// URLBuilder.URL is not affected.
+	Strict bool
+
 	base *url.URL
 	qry  url.Values
 }
@@ -52,6 +60,7 @@ func (b URLBuilderSource) NewURLBuilder() *URLBuilder {
 		url:        &u,
 		pathParams: make(map[string]string),
 		qry:        urlValuesCopy(b.qry),
+		strict:     b.Strict,
 	}
 }
 
@@ -80,11 +89,29 @@ func (b URLBuilderSource) NewURLBuilder() *URLBuilder {
 //	}
 //
 //	// send HTTP request.
+//
+// URL and Build do not modify the URLBuilder and can be called any
+// number of times. A partially configured URLBuilder can serve as a
+// template for many variants using Clone:
+//
+//	posts := b.NewURLBuilder().
+//		Path("/users/{id}/posts").
+//		PathParam("id", id)
+//
+//	page1 := posts.Clone().QueryParamInt("page", 1).URL()
+//	page2 := posts.Clone().QueryParamInt("page", 2).URL()
+//
+// A URLBuilder is not safe for concurrent modification. Clone should be
+// used to derive a URLBuilder for use in another goroutine.
 type URLBuilder struct {
 	url        *url.URL
 	path       string
 	pathParams map[string]string
+	tmpl       *URITemplate
+	tmplVars   map[string]interface{}
 	qry        url.Values
+	strict     bool
+	err        error
 }
 
 // Path sets the path template for the URL.
@@ -122,6 +149,41 @@ func (u *URLBuilder) PathParams(params map[string]string) *URLBuilder {
 	return u
 }
 
+// URITemplate sets the URI Template for the URL. It takes the place of
+// the path template set using Path and can also expand to the query and
+// the fragment of the URL. The variables are set using TemplateVar and
+// PathParam*.
+//
+//	search := httputil.MustParseURITemplate("/repos/{owner}/{repo}/issues{?labels*,state}")
+//
+//	u := b.NewURLBuilder().
+//		URITemplate(search).
+//		PathParam("owner", "golang").
+//		PathParam("repo", "go").
+//		TemplateVar("labels", []string{"NeedsFix", "release-blocker"}).
+//		URL()
+//
+//	// https://api.example.com/repos/golang/go/issues?labels=NeedsFix&labels=release-blocker
+//
+// The expanded path is joined with the base URL and the expanded query
+// is followed by the query parameters. See URITemplate.Expand for the
+// supported variable values.
+func (u *URLBuilder) URITemplate(t *URITemplate) *URLBuilder {
+	u.tmpl = t
+	return u
+}
+
+// TemplateVar sets the variable name and value which needs to be
+// substituted in the URI Template. Substitution happens when the URL is
+// built using URLBuilder.URL()
+func (u *URLBuilder) TemplateVar(name string, value interface{}) *URLBuilder {
+	if u.tmplVars == nil {
+		u.tmplVars = make(map[string]interface{})
+	}
+	u.tmplVars[name] = value
+	return u
+}
+
 // QueryParam sets the query parameter with the given values. If a value
 // was previously set, it is replaced.
 func (u *URLBuilder) QueryParam(key string, values ...string) *URLBuilder {
@@ -176,18 +238,277 @@ func (u *URLBuilder) QueryParams(params url.Values) *URLBuilder {
 // The constructed URL has the complete path and query parameters setup.
 // The path parameters are substituted before being joined with the base
 // URL.
+//
+// Placeholders in the path template without a corresponding path
+// parameter are left as is. If the URI Template cannot be expanded with
+// the variables, for example due to a value of an unsupported type, the
+// template is used as is. Build can be used to detect such mistakes.
 func (u *URLBuilder) URL() *url.URL {
-	urlv := u.url
+	urlv := *u.url // create a copy
+	if u.tmpl != nil {
+		_ = u.expandTemplate(&urlv)
+		return &urlv
+	}
+
+	urlv.Path = path.Join(urlv.Path, u.expandPath())
+	urlv.RawQuery = u.qry.Encode()
+
+	return &urlv
+}
 
+// Clone returns a deep copy of the URLBuilder. Changes made to the
+// clone do not affect the original and vice versa.
+func (u *URLBuilder) Clone() *URLBuilder {
+	urlv := *u.url // create a copy
+	pathParams := make(map[string]string, len(u.pathParams))
+	for name, value := range u.pathParams {
+		pathParams[name] = value
+	}
+	var tmplVars map[string]interface{}
+	if u.tmplVars != nil {
+		tmplVars = make(map[string]interface{}, len(u.tmplVars))
+		for name, value := range u.tmplVars {
+			tmplVars[name] = value
+		}
+	}
+
+	return &URLBuilder{
+		url:        &urlv,
+		path:       u.path,
+		pathParams: pathParams,
+		tmpl:       u.tmpl,
+		tmplVars:   tmplVars,
+		qry:        urlValuesCopy(u.qry),
+		strict:     u.strict,
+		err:        u.err,
+	}
+}
+
+// expandPath substitutes the path parameters in the path template.
+// Placeholders without a corresponding path parameter are left as is.
+func (u *URLBuilder) expandPath() string {
+	if len(u.pathParams) == 0 || !strings.Contains(u.path, "{") {
+		return u.path
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(u.path))
 	p := u.path
+	for {
+		start := strings.IndexByte(p, '{')
+		if start == -1 {
+			break
+		}
+		sb.WriteString(p[:start])
+		p = p[start:]
+
+		end := strings.IndexAny(p[1:], "{}")
+		if end == -1 || p[1+end] == '{' {
+			sb.WriteByte('{')
+			p = p[1:]
+			continue
+		}
+
+		value, ok := u.pathParams[p[1:1+end]]
+		if !ok {
+			sb.WriteString(p[:end+2])
+		} else {
+			sb.WriteString(url.PathEscape(value))
+		}
+		p = p[end+2:]
+	}
+	sb.WriteString(p)
+
+	return sb.String()
+}
+
+// expandTemplate expands the URI Template and sets the path, query and
+// fragment on urlv. If the template cannot be expanded, it is used as
+// is and the error is returned.
+func (u *URLBuilder) expandTemplate(urlv *url.URL) error {
+	s, expErr := u.tmpl.Expand(u.templateVars())
+	if expErr != nil {
+		s = u.tmpl.String()
+	}
+
+	var (
+		rawQuery string
+		err      error
+	)
+	if i := strings.IndexByte(s, '#'); i != -1 {
+		s, urlv.RawFragment = s[:i], s[i+1:]
+		urlv.Fragment, err = url.PathUnescape(urlv.RawFragment)
+		if err != nil {
+			urlv.Fragment = urlv.RawFragment
+		}
+	}
+	if i := strings.IndexByte(s, '?'); i != -1 {
+		s, rawQuery = s[:i], s[i+1:]
+	}
+
+	urlv.RawPath = path.Join(urlv.EscapedPath(), s)
+	urlv.Path, err = url.PathUnescape(urlv.RawPath)
+	if err != nil {
+		urlv.Path = urlv.RawPath
+	}
+
+	if qry := u.qry.Encode(); qry != "" {
+		if rawQuery != "" {
+			rawQuery += "&"
+		}
+		rawQuery += qry
+	}
+	urlv.RawQuery = rawQuery
+
+	return expErr
+}
+
+// templateVars returns the variables for expanding the URI Template.
+// The path parameters are included as string variables.
+func (u *URLBuilder) templateVars() map[string]interface{} {
+	vars := make(map[string]interface{}, len(u.pathParams)+len(u.tmplVars))
 	for name, value := range u.pathParams {
-		p = strings.ReplaceAll(p, "{"+name+"}", url.PathEscape(value))
+		vars[name] = value
+	}
+	for name, value := range u.tmplVars {
+		vars[name] = value
 	}
-	urlv.Path = path.Join(urlv.Path, p)
+	return vars
+}
 
-	urlv.RawQuery = u.qry.Encode()
+// Build constructs and returns an instance of URL like URL but fails
+// with an error of kind errors.InvalidInput if the path template is
+// malformed, for example due to unbalanced braces, if a placeholder in
+// the path template has no corresponding path parameter or if a path
+// parameter is not used in the path template. The error encountered by
+// QueryStruct, if any, is also returned. If the URLBuilderSource is
+// Strict, path parameters with an empty value are reported as well.
+//
+// If a URI Template is set, variables are optional as per RFC 6570 and
+// only the variables not used in the template are reported. It also
+// fails if the template cannot be expanded using the variables.
+//
+//	u, err := b.NewURLBuilder().
+//		Path("/users/{id}/posts").
+//		PathParam("userID", id).
+//		Build()
+//	// err: path template "/users/{id}/posts": unresolved path parameters [id]; unused path parameters [userID]
+func (u *URLBuilder) Build() (*url.URL, error) {
+	const op = "URLBuilder.Build"
+
+	if err := u.validate(); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	if u.tmpl == nil {
+		return u.URL(), nil
+	}
+
+	urlv := *u.url // create a copy
+	if err := u.expandTemplate(&urlv); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	return &urlv, nil
+}
+
+// validate checks the path template and the path parameters against
+// each other.
+func (u *URLBuilder) validate() error {
+	if u.err != nil {
+		return u.err
+	}
+	if u.tmpl != nil {
+		return u.validateTemplate()
+	}
 
-	return urlv
+	names, err := templateParams(u.path)
+	if err != nil {
+		return errors.E(errors.InvalidInput, errors.WithTextf("malformed path template %q: %s", u.path, err))
+	}
+
+	var unresolved, unused, empty []string
+	used := make(map[string]bool, len(names))
+	for _, name := range names {
+		used[name] = true
+		value, ok := u.pathParams[name]
+		switch {
+		case !ok:
+			unresolved = append(unresolved, name)
+		case u.strict && value == "":
+			empty = append(empty, name)
+		}
+	}
+	for name := range u.pathParams {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+
+	var problems []string
+	if len(unresolved) != 0 {
+		problems = append(problems, fmt.Sprintf("unresolved path parameters %v", unresolved))
+	}
+	if len(unused) != 0 {
+		problems = append(problems, fmt.Sprintf("unused path parameters %v", unused))
+	}
+	if len(empty) != 0 {
+		problems = append(problems, fmt.Sprintf("empty path parameters %v", empty))
+	}
+	if len(problems) != 0 {
+		return errors.E(errors.InvalidInput, errors.WithTextf("path template %q: %s", u.path, strings.Join(problems, "; ")))
+	}
+
+	return nil
+}
+
+// validateTemplate checks the variables against the URI Template. The
+// errors from expanding the template are reported by Build.
+func (u *URLBuilder) validateTemplate() error {
+	vars := u.templateVars()
+	for _, name := range u.tmpl.Vars() {
+		delete(vars, name)
+	}
+	if len(vars) == 0 {
+		return nil
+	}
+
+	unused := make([]string, 0, len(vars))
+	for name := range vars {
+		unused = append(unused, name)
+	}
+	sort.Strings(unused)
+	return errors.E(errors.InvalidInput, errors.WithTextf("URI template %q: unused variables %v", u.tmpl, unused))
+}
+
+// templateParams returns the names of the placeholders in the path
+// template, without duplicates, in the order of appearance.
+func templateParams(tmpl string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(tmpl); i++ {
+		switch tmpl[i] {
+		case '{':
+			end := strings.IndexAny(tmpl[i+1:], "{}")
+			if end == -1 || tmpl[i+1+end] == '{' {
+				return nil, errors.E(errors.WithTextf("unterminated placeholder at offset %d", i))
+			}
+
+			name := tmpl[i+1 : i+1+end]
+			if name == "" {
+				return nil, errors.E(errors.WithTextf("empty placeholder at offset %d", i))
+			}
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+			i += end + 1
+
+		case '}':
+			return nil, errors.E(errors.WithTextf("unexpected '}' at offset %d", i))
+		}
+	}
+	return names, nil
 }
 
 func urlValuesCopy(src url.Values) url.Values {
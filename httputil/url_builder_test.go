@@ -201,3 +201,341 @@ func TestURLBuilder(t *testing.T) {
 		})
 	}
 }
+
+func TestURLBuilderBuild(t *testing.T) {
+	b, err := httputil.NewURLBuilderSource("https://api.example.com/v1")
+	if err != nil {
+		t.Fatalf("NewURLBuilderSource(): %s", err)
+	}
+
+	const op = "URLBuilder.Build"
+	cases := []struct {
+		name    string
+		u       *httputil.URLBuilder
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Resolved",
+			u: b.NewURLBuilder().
+				Path("/users/{userID}/posts/{postID}/{userID}").
+				PathParam("userID", "foo").
+				PathParamInt("postID", 42),
+			want: "https://api.example.com/v1/users/foo/posts/42/foo",
+		},
+		{
+			name: "NoPathParams",
+			u:    b.NewURLBuilder().Path("/users"),
+			want: "https://api.example.com/v1/users",
+		},
+		{
+			name: "UnresolvedAndUnused",
+			u: b.NewURLBuilder().
+				Path("/users/{id}/posts/{postID}").
+				PathParam("userID", "foo").
+				PathParam("postID", "42").
+				PathParam("commentID", "7"),
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithText(`path template "/users/{id}/posts/{postID}": unresolved path parameters [id]; unused path parameters [commentID userID]`),
+			),
+		},
+		{
+			name: "Unterminated",
+			u:    b.NewURLBuilder().Path("/users/{id/posts").PathParam("id", "foo"),
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithText(`malformed path template "/users/{id/posts": unterminated placeholder at offset 7`),
+			),
+		},
+		{
+			name: "Nested",
+			u:    b.NewURLBuilder().Path("/users/{{id}}").PathParam("id", "foo"),
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithText(`malformed path template "/users/{{id}}": unterminated placeholder at offset 7`),
+			),
+		},
+		{
+			name: "UnexpectedClosingBrace",
+			u:    b.NewURLBuilder().Path("/users/id}"),
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithText(`malformed path template "/users/id}": unexpected '}' at offset 9`),
+			),
+		},
+		{
+			name: "EmptyPlaceholder",
+			u:    b.NewURLBuilder().Path("/users/{}"),
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithText(`malformed path template "/users/{}": empty placeholder at offset 7`),
+			),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := tc.u.Build()
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("URLBuilder.Build() error diff: %s", errorDiff(tc.wantErr, err))
+			}
+			if err != nil {
+				return
+			}
+
+			if u.String() != tc.want {
+				t.Errorf("URLBuilder.Build()=%s; want=%s", u, tc.want)
+			}
+		})
+	}
+
+	t.Run("Strict", func(t *testing.T) {
+		sb := b
+		sb.Strict = true
+
+		ub := sb.NewURLBuilder().
+			Path("/users/{id}/posts/{postID}").
+			PathParam("id", "").
+			PathParam("postID", "42")
+
+		_, err := ub.Build()
+		wantErr := errors.E(
+			errors.WithOp(op),
+			errors.InvalidInput,
+			errors.WithText(`path template "/users/{id}/posts/{postID}": empty path parameters [id]`),
+		)
+		if !matchErrors(wantErr, err) {
+			t.Errorf("URLBuilder.Build() error diff: %s", errorDiff(wantErr, err))
+		}
+
+		want := "https://api.example.com/v1/users/posts/42"
+		if got := ub.URL().String(); got != want {
+			t.Errorf("URLBuilder.URL()=%s; want=%s", got, want)
+		}
+
+		if _, err := b.NewURLBuilder().Path("/users/{id}").PathParam("id", "").Build(); err != nil {
+			t.Errorf("URLBuilder.Build() error=%v; want nil without Strict", err)
+		}
+	})
+}
+
+func TestURLBuilderReuse(t *testing.T) {
+	b, err := httputil.NewURLBuilderSource("https://api.example.com/v1?limit=10")
+	if err != nil {
+		t.Fatalf("NewURLBuilderSource(): %s", err)
+	}
+
+	t.Run("Idempotent", func(t *testing.T) {
+		ub := b.NewURLBuilder().Path("/users/{id}/posts").PathParam("id", "foo")
+
+		want := "https://api.example.com/v1/users/foo/posts?limit=10"
+		for i := 0; i < 3; i++ {
+			if got := ub.URL().String(); got != want {
+				t.Errorf("URLBuilder.URL() call %d=%s; want=%s", i+1, got, want)
+			}
+		}
+
+		u, err := ub.Build()
+		if err != nil {
+			t.Fatalf("URLBuilder.Build() error=%v", err)
+		}
+		if got := u.String(); got != want {
+			t.Errorf("URLBuilder.Build()=%s; want=%s", got, want)
+		}
+
+		// Modifying the returned URL must not affect the builder.
+		u.Path = "/modified"
+		if got := ub.URL().String(); got != want {
+			t.Errorf("URLBuilder.URL() after modification=%s; want=%s", got, want)
+		}
+	})
+
+	t.Run("Clone", func(t *testing.T) {
+		posts := b.NewURLBuilder().
+			Path("/users/{id}/posts").
+			PathParam("id", "foo").
+			QueryParam("sort", "recent")
+
+		page1 := posts.Clone().QueryParamInt("page", 1)
+		page2 := posts.Clone().QueryParamInt("page", 2).PathParam("id", "bar")
+		posts.QueryParam("sort", "top")
+
+		cases := []struct {
+			name string
+			ub   *httputil.URLBuilder
+			want string
+		}{
+			{name: "Original", ub: posts, want: "https://api.example.com/v1/users/foo/posts?limit=10&sort=top"},
+			{name: "Page1", ub: page1, want: "https://api.example.com/v1/users/foo/posts?limit=10&page=1&sort=recent"},
+			{name: "Page2", ub: page2, want: "https://api.example.com/v1/users/bar/posts?limit=10&page=2&sort=recent"},
+		}
+		for _, tc := range cases {
+			if got := tc.ub.URL().String(); got != tc.want {
+				t.Errorf("%s: URLBuilder.URL()=%s; want=%s", tc.name, got, tc.want)
+			}
+		}
+	})
+}
+
+func TestURLBuilderURITemplate(t *testing.T) {
+	b, err := httputil.NewURLBuilderSource("https://api.example.com/v1?api_key=secret")
+	if err != nil {
+		t.Fatalf("NewURLBuilderSource(): %s", err)
+	}
+
+	issues := httputil.MustParseURITemplate("/repos/{owner}/{repo}/issues{?labels*,state}")
+
+	const op = "URLBuilder.Build"
+	cases := []struct {
+		name     string
+		u        *httputil.URLBuilder
+		want     string
+		wantPath string
+		wantErr  error
+	}{
+		{
+			name: "PathAndQuery",
+			u: b.NewURLBuilder().
+				URITemplate(issues).
+				PathParam("owner", "golang").
+				PathParam("repo", "go").
+				TemplateVar("labels", []string{"NeedsFix", "release-blocker"}).
+				QueryParamInt("page", 2),
+			want:     "https://api.example.com/v1/repos/golang/go/issues?labels=NeedsFix&labels=release-blocker&api_key=secret&page=2",
+			wantPath: "/v1/repos/golang/go/issues",
+		},
+		{
+			name: "EncodedPathSegment",
+			u: b.NewURLBuilder().
+				URITemplate(httputil.MustParseURITemplate("/files{/dir,name}")).
+				TemplateVar("dir", "a/b").
+				TemplateVar("name", "report 1.pdf"),
+			want:     "https://api.example.com/v1/files/a%2Fb/report%201.pdf?api_key=secret",
+			wantPath: "/v1/files/a/b/report 1.pdf",
+		},
+		{
+			name: "Fragment",
+			u: b.NewURLBuilder().
+				URITemplate(httputil.MustParseURITemplate("/docs/{page}{#section}")).
+				TemplateVar("page", "intro").
+				TemplateVar("section", "getting started"),
+			want:     "https://api.example.com/v1/docs/intro?api_key=secret#getting%20started",
+			wantPath: "/v1/docs/intro",
+		},
+		{
+			name: "UndefinedVars",
+			u:    b.NewURLBuilder().URITemplate(issues),
+			want: "https://api.example.com/v1/repos/issues?api_key=secret",
+		},
+		{
+			name: "UnusedVars",
+			u: b.NewURLBuilder().
+				URITemplate(issues).
+				PathParam("owner", "golang").
+				PathParam("userID", "foo").
+				TemplateVar("sort", "created"),
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.InvalidInput,
+				errors.WithText(`URI template "/repos/{owner}/{repo}/issues{?labels*,state}": unused variables [sort userID]`),
+			),
+		},
+		{
+			name: "ExpandError",
+			u:    b.NewURLBuilder().URITemplate(issues).TemplateVar("state", struct{}{}),
+			wantErr: errors.E(
+				errors.WithOp(op),
+				errors.WithErr(errors.E(
+					errors.WithOp("URITemplate.Expand"),
+					errors.InvalidInput,
+					errors.WithText(`expand URI template "/repos/{owner}/{repo}/issues{?labels*,state}": variable "state": unsupported type struct {}`),
+				)),
+			),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := tc.u.Build()
+			if !matchErrors(tc.wantErr, err) {
+				t.Errorf("URLBuilder.Build() error diff: %s", errorDiff(tc.wantErr, err))
+			}
+			if err != nil {
+				return
+			}
+
+			if u.String() != tc.want {
+				t.Errorf("URLBuilder.Build()=%s; want=%s", u, tc.want)
+			}
+			if tc.wantPath != "" && u.Path != tc.wantPath {
+				t.Errorf("URLBuilder.Build().Path=%q; want=%q", u.Path, tc.wantPath)
+			}
+		})
+	}
+
+	t.Run("ExpandErrorFallback", func(t *testing.T) {
+		u := b.NewURLBuilder().
+			URITemplate(httputil.MustParseURITemplate("/users/{id}/posts")).
+			TemplateVar("id", struct{}{}).
+			QueryParamInt("page", 2).
+			URL()
+
+		want := "https://api.example.com/v1/users/%7Bid%7D/posts?api_key=secret&page=2"
+		if u.String() != want {
+			t.Errorf("URLBuilder.URL()=%s; want=%s", u, want)
+		}
+	})
+
+	t.Run("Clone", func(t *testing.T) {
+		repo := b.NewURLBuilder().
+			URITemplate(issues).
+			PathParam("owner", "golang").
+			PathParam("repo", "go")
+
+		open := repo.Clone().TemplateVar("state", "open")
+		repo.TemplateVar("state", "closed")
+
+		want := "https://api.example.com/v1/repos/golang/go/issues?state=open&api_key=secret"
+		if got := open.URL().String(); got != want {
+			t.Errorf("URLBuilder.URL()=%s; want=%s", got, want)
+		}
+	})
+}
+
+func BenchmarkURLBuilderURL(b *testing.B) {
+	src, err := httputil.NewURLBuilderSource("https://api.example.com/v1?api_key=secret")
+	if err != nil {
+		b.Fatalf("NewURLBuilderSource(): %s", err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		src.NewURLBuilder().
+			Path("/users/{userID}/posts/{postID}").
+			PathParam("userID", "foo").
+			PathParamInt("postID", 42).
+			QueryParamInt("limit", 10).
+			URL()
+	}
+}
+
+func BenchmarkURLBuilderClone(b *testing.B) {
+	src, err := httputil.NewURLBuilderSource("https://api.example.com/v1?api_key=secret")
+	if err != nil {
+		b.Fatalf("NewURLBuilderSource(): %s", err)
+	}
+
+	posts := src.NewURLBuilder().
+		Path("/users/{userID}/posts/{postID}").
+		PathParam("userID", "foo").
+		PathParamInt("postID", 42)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		posts.Clone().QueryParamInt("limit", 10).URL()
+	}
+}
@@ -0,0 +1,129 @@
+package xgo
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+)
+
+// Presencer is implemented by any value which tracks whether it was
+// present in the input and whether it was null. Validators can use it
+// to implement rules such as 'required' for partial updates.
+type Presencer interface {
+	// IsPresent reports whether the value was present in the input.
+	IsPresent() bool
+
+	// IsNull reports whether the value was explicitly set to null.
+	IsNull() bool
+}
+
+// Optional is a value of type T which distinguishes between the
+// following tri-state input:
+//
+//   - field missing: IsPresent() == false
+//   - field set to null: IsPresent() == true, IsNull() == true
+//   - field set: IsPresent() == true, IsNull() == false
+//
+// It implements json.Unmarshaler and encoding.TextUnmarshaler to record
+// the presence and nullness. The zero value represents a missing field.
+//
+//	type UpdateUserRequest struct {
+//		Name  xgo.Optional[string] `json:"name"`
+//		Email xgo.Optional[string] `json:"email"`
+//	}
+//
+//	if email, ok := req.Email.Get(); ok {
+//		// update email
+//	} else if req.Email.IsNull() {
+//		// clear email
+//	}
+type Optional[T any] struct {
+	value   T
+	present bool
+	null    bool
+}
+
+// NewOptional returns an Optional which is present and set to v.
+func NewOptional[T any](v T) Optional[T] {
+	return Optional[T]{value: v, present: true}
+}
+
+// NullOptional returns an Optional which is present and null.
+func NullOptional[T any]() Optional[T] {
+	return Optional[T]{present: true, null: true}
+}
+
+// Get returns the value and true if the value is present and not
+// null. Otherwise, it returns the zero value of T and false.
+func (o Optional[T]) Get() (T, bool) {
+	return o.value, o.present && !o.null
+}
+
+// Value returns the value. It is the zero value of T if the value is
+// missing or null.
+func (o Optional[T]) Value() T { return o.value }
+
+// IsPresent reports whether the value was present in the input.
+func (o Optional[T]) IsPresent() bool { return o.present }
+
+// IsNull reports whether the value was explicitly set to null.
+func (o Optional[T]) IsNull() bool { return o.null }
+
+// IsZero reports whether the value is missing. It allows the field to
+// be omitted using the `omitzero` JSON struct tag option.
+func (o Optional[T]) IsZero() bool { return !o.present }
+
+// MarshalJSON implements json.Marshaler. A missing or null value is
+// encoded as null.
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	if !o.present || o.null {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.value)
+}
+
+// DecodeTarget marks the value as present and not null, resets it and
+// returns a pointer to it. It allows a decoder to decode into the value
+// using its own options instead of going through UnmarshalJSON or
+// UnmarshalText. httputil.JSONDecoder and the form decoders in httputil
+// make use of it.
+func (o *Optional[T]) DecodeTarget() interface{} {
+	var zero T
+	o.value, o.present, o.null = zero, true, false
+	return &o.value
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The value is decoded using
+// json.Unmarshal. So the options of the decoder, such as
+// DisallowUnknownFields, do not apply to the fields of T unless the
+// decoder uses DecodeTarget, as httputil.JSONDecoder does.
+func (o *Optional[T]) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		var zero T
+		o.value, o.present, o.null = zero, true, true
+		return nil
+	}
+
+	return json.Unmarshal(b, o.DecodeTarget())
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. This allows
+// Optional to be used with decoders for query parameters and form
+// values. The text is decoded using encoding.TextUnmarshaler if
+// implemented by T. Otherwise, strings are set as is and other values,
+// such as booleans and numbers, are decoded from the text as JSON. Text
+// input cannot represent null.
+func (o *Optional[T]) UnmarshalText(text []byte) error {
+	target := o.DecodeTarget()
+	if tu, ok := target.(encoding.TextUnmarshaler); ok {
+		return tu.UnmarshalText(text)
+	}
+
+	if v := reflect.ValueOf(target).Elem(); v.Kind() == reflect.String {
+		v.SetString(string(text))
+		return nil
+	}
+
+	return json.Unmarshal(text, target)
+}
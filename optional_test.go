@@ -0,0 +1,200 @@
+package xgo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/sudo-suhas/xgo"
+)
+
+// Compile time check to ensure type implements the interfaces.
+var _ xgo.Presencer = xgo.Optional[string]{}
+
+type updateUserRequest struct {
+	Name  xgo.Optional[string]  `json:"name"`
+	Age   xgo.Optional[int]     `json:"age"`
+	Email xgo.Optional[*string] `json:"email"`
+}
+
+func TestOptionalUnmarshalJSON(t *testing.T) {
+	email := "donald@example.com"
+	cases := []struct {
+		name string
+		in   string
+		want updateUserRequest
+	}{
+		{
+			name: "Missing",
+			in:   `{}`,
+			want: updateUserRequest{},
+		},
+		{
+			name: "Null",
+			in:   `{"name": null, "email": null}`,
+			want: updateUserRequest{
+				Name:  xgo.NullOptional[string](),
+				Email: xgo.NullOptional[*string](),
+			},
+		},
+		{
+			name: "Set",
+			in:   `{"name": "Donald", "age": 0, "email": "donald@example.com"}`,
+			want: updateUserRequest{
+				Name:  xgo.NewOptional("Donald"),
+				Age:   xgo.NewOptional(0),
+				Email: xgo.NewOptional(&email),
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := json.NewDecoder(bytes.NewReader([]byte(tc.in)))
+			dec.DisallowUnknownFields()
+
+			var got updateUserRequest
+			if err := dec.Decode(&got); err != nil {
+				t.Fatalf("json.Decoder.Decode() error: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("json.Decoder.Decode()=%#v; want %#v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("InvalidValue", func(t *testing.T) {
+		var got updateUserRequest
+		err := json.Unmarshal([]byte(`{"age": "ten"}`), &got)
+
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			t.Errorf("json.Unmarshal() error=%v; want *json.UnmarshalTypeError", err)
+		}
+	})
+}
+
+func TestOptionalGet(t *testing.T) {
+	cases := []struct {
+		name                  string
+		o                     xgo.Optional[int]
+		want                  int
+		wantOK, present, null bool
+	}{
+		{name: "Missing"},
+		{name: "Null", o: xgo.NullOptional[int](), present: true, null: true},
+		{name: "Set", o: xgo.NewOptional(42), want: 42, wantOK: true, present: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := tc.o.Get()
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("Get()=(%d, %t); want (%d, %t)", got, ok, tc.want, tc.wantOK)
+			}
+			if tc.o.Value() != tc.want {
+				t.Errorf("Value()=%d; want %d", tc.o.Value(), tc.want)
+			}
+			if tc.o.IsPresent() != tc.present {
+				t.Errorf("IsPresent()=%t; want %t", tc.o.IsPresent(), tc.present)
+			}
+			if tc.o.IsNull() != tc.null {
+				t.Errorf("IsNull()=%t; want %t", tc.o.IsNull(), tc.null)
+			}
+			if tc.o.IsZero() == tc.present {
+				t.Errorf("IsZero()=%t; want %t", tc.o.IsZero(), !tc.present)
+			}
+		})
+	}
+}
+
+func TestOptionalMarshalJSON(t *testing.T) {
+	b, err := json.Marshal(updateUserRequest{
+		Name: xgo.NewOptional("Donald"),
+		Age:  xgo.NullOptional[int](),
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal() error: %s", err)
+	}
+
+	want := `{"name":"Donald","age":null,"email":null}`
+	if string(b) != want {
+		t.Errorf("json.Marshal()=%s; want %s", b, want)
+	}
+}
+
+func TestOptionalUnmarshalText(t *testing.T) {
+	t.Run("String", func(t *testing.T) {
+		var o xgo.Optional[string]
+		if err := o.UnmarshalText([]byte("Donald")); err != nil {
+			t.Fatalf("UnmarshalText() error: %s", err)
+		}
+		if o != xgo.NewOptional("Donald") {
+			t.Errorf("UnmarshalText()=%#v; want %#v", o, xgo.NewOptional("Donald"))
+		}
+	})
+
+	t.Run("Number", func(t *testing.T) {
+		var o xgo.Optional[uint8]
+		if err := o.UnmarshalText([]byte("42")); err != nil {
+			t.Fatalf("UnmarshalText() error: %s", err)
+		}
+		if o != xgo.NewOptional[uint8](42) {
+			t.Errorf("UnmarshalText()=%#v; want %#v", o, xgo.NewOptional[uint8](42))
+		}
+
+		if err := o.UnmarshalText([]byte("256")); err == nil {
+			t.Errorf("UnmarshalText() error=nil; want out of range error")
+		}
+	})
+
+	t.Run("Pointer", func(t *testing.T) {
+		var o xgo.Optional[*bool]
+		if err := o.UnmarshalText([]byte("true")); err != nil {
+			t.Fatalf("UnmarshalText() error: %s", err)
+		}
+		if v, ok := o.Get(); !ok || v == nil || !*v {
+			t.Errorf("UnmarshalText()=%#v; want pointer to true", o)
+		}
+	})
+
+	t.Run("TextUnmarshaler", func(t *testing.T) {
+		var o xgo.Optional[net.IP]
+		if err := o.UnmarshalText([]byte("127.0.0.1")); err != nil {
+			t.Fatalf("UnmarshalText() error: %s", err)
+		}
+		if v, _ := o.Get(); !v.Equal(net.IPv4(127, 0, 0, 1)) {
+			t.Errorf("UnmarshalText()=%v; want 127.0.0.1", v)
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		var o xgo.Optional[[]string]
+		if err := o.UnmarshalText([]byte("a,b")); err == nil {
+			t.Errorf("UnmarshalText() error=nil; want error")
+		}
+	})
+}
+
+func ExampleOptional() {
+	// A validator can use the xgo.Presencer interface to check presence.
+	required := xgo.ValidatorFunc(func(v interface{}) error {
+		if p, ok := v.(xgo.Presencer); ok && (!p.IsPresent() || p.IsNull()) {
+			return errors.New("required")
+		}
+		return nil
+	})
+
+	var req updateUserRequest
+	_ = json.Unmarshal([]byte(`{"name": null}`), &req)
+
+	fmt.Println("name:", required.Validate(req.Name))
+	fmt.Println("age:", required.Validate(req.Age))
+
+	// Output:
+	// name: required
+	// age: required
+}